@@ -0,0 +1,125 @@
+package datetime
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// isoLayouts ISO-8601 日期的格式，没有时区时使用本地时区
+var isoLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	time.RFC3339Nano,
+}
+
+// ParseDateMath 解析日期表达式，与 Elasticsearch 的 date math 一致
+//
+//	锚点为 now 或者 ISO-8601 日期，如："2024-01-01"、"2024-01-01T08:00:00+08:00"
+//	now 后面可以跟多个偏移和取整，如："now-15m"、"now/d"、"now-1d/d"
+//	单位：y（年）、M（月）、w（周）、d（天）、h|H（小时）、m（分钟）、s（秒）
+//	roundUp 为 true 时，取整到周期的最后一毫秒，用于 > 和 <=，如：now/d => 今天 23:59:59.999
+func ParseDateMath(expr string, now time.Time, roundUp bool) (time.Time, error) {
+	if !strings.HasPrefix(expr, "now") {
+		for _, layout := range isoLayouts {
+			if t, err := time.ParseInLocation(layout, expr, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("illegal date: %s", expr)
+	}
+	result := now
+	for rest := expr[3:]; len(rest) > 0; {
+		op := rest[0]
+		rest = rest[1:]
+		n := 1
+		if op == '+' || op == '-' {
+			i := 0
+			for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+				i++
+			}
+			var err error
+			if n, err = strconv.Atoi(rest[:i]); err != nil {
+				return time.Time{}, fmt.Errorf("illegal date math: %s", expr)
+			}
+			if op == '-' {
+				n = -n
+			}
+			rest = rest[i:]
+		} else if op != '/' {
+			return time.Time{}, fmt.Errorf("illegal date math: %s", expr)
+		}
+		if len(rest) == 0 {
+			return time.Time{}, fmt.Errorf("illegal date math: %s", expr)
+		}
+		unit := rest[0]
+		rest = rest[1:]
+		var err error
+		if op == '/' {
+			result, err = roundDate(result, unit, roundUp)
+		} else {
+			result, err = addDate(result, unit, n)
+		}
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s: %s", err, expr)
+		}
+	}
+	return result, nil
+}
+
+// addDate 按单位增加偏移
+func addDate(t time.Time, unit byte, n int) (time.Time, error) {
+	switch unit {
+	case 'y':
+		return t.AddDate(n, 0, 0), nil
+	case 'M':
+		return t.AddDate(0, n, 0), nil
+	case 'w':
+		return t.AddDate(0, 0, 7*n), nil
+	case 'd':
+		return t.AddDate(0, 0, n), nil
+	case 'h', 'H':
+		return t.Add(time.Duration(n) * time.Hour), nil
+	case 'm':
+		return t.Add(time.Duration(n) * time.Minute), nil
+	case 's':
+		return t.Add(time.Duration(n) * time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown unit %q", unit)
+}
+
+// roundDate 按单位取整，周从周一开始
+func roundDate(t time.Time, unit byte, roundUp bool) (time.Time, error) {
+	year, month, day := t.Date()
+	var start, end time.Time
+	switch unit {
+	case 'y':
+		start = time.Date(year, 1, 1, 0, 0, 0, 0, t.Location())
+		end = start.AddDate(1, 0, 0)
+	case 'M':
+		start, end = GetMonthRange(t)
+	case 'w':
+		start, end = GetWeekRange(t, time.Monday)
+	case 'd':
+		start = time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+		end = start.AddDate(0, 0, 1)
+	case 'h', 'H':
+		start = time.Date(year, month, day, t.Hour(), 0, 0, 0, t.Location())
+		end = start.Add(time.Hour)
+	case 'm':
+		start = t.Truncate(time.Minute)
+		end = start.Add(time.Minute)
+	case 's':
+		start = t.Truncate(time.Second)
+		end = start.Add(time.Second)
+	default:
+		return time.Time{}, fmt.Errorf("unknown unit %q", unit)
+	}
+	if roundUp {
+		return end.Add(-time.Millisecond), nil
+	}
+	return start, nil
+}
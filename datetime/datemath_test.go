@@ -0,0 +1,43 @@
+package datetime
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateMath(t *testing.T) {
+	now := time.Date(2024, 6, 12, 15, 30, 45, 500, time.Local) // 周三
+	testCases := []struct {
+		Expr    string
+		RoundUp bool
+		Result  time.Time
+	}{
+		{Expr: "now", Result: now},
+		{Expr: "now-15m", Result: now.Add(-15 * time.Minute)},
+		{Expr: "now+1d-2h", Result: now.AddDate(0, 0, 1).Add(-2 * time.Hour)},
+		{Expr: "now/d", Result: time.Date(2024, 6, 12, 0, 0, 0, 0, time.Local)},
+		{Expr: "now/d", RoundUp: true, Result: time.Date(2024, 6, 12, 23, 59, 59, 999000000, time.Local)},
+		{Expr: "now-1M/M", Result: time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)},
+		{Expr: "now/w", Result: time.Date(2024, 6, 10, 0, 0, 0, 0, time.Local)},
+		{Expr: "now/H", RoundUp: true, Result: time.Date(2024, 6, 12, 15, 59, 59, 999000000, time.Local)},
+		{Expr: "now-1y/y", Result: time.Date(2023, 1, 1, 0, 0, 0, 0, time.Local)},
+		{Expr: "2024-01-02", Result: time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)},
+		{Expr: "2024-01-02T08:30", Result: time.Date(2024, 1, 2, 8, 30, 0, 0, time.Local)},
+		{Expr: "2024-01-02T08:30:00.25Z", Result: time.Date(2024, 1, 2, 8, 30, 0, 250000000, time.UTC)},
+		{Expr: "2024-01-02T08:30+08:00", Result: time.Date(2024, 1, 2, 0, 30, 0, 0, time.UTC)},
+	}
+	for _, testCase := range testCases {
+		result, err := ParseDateMath(testCase.Expr, now, testCase.RoundUp)
+		if err != nil {
+			t.Fatalf("testCase %s failure: %s", testCase.Expr, err)
+		}
+		if !result.Equal(testCase.Result) {
+			t.Fatalf("testCase %s need %s but got %s", testCase.Expr, testCase.Result, result)
+		}
+	}
+	for _, expr := range []string{"", "now-", "now-1", "now-1x", "now/", "now*1d", "2024-13-01", "yesterday"} {
+		if _, err := ParseDateMath(expr, now, false); err == nil {
+			t.Fatalf("testCase %q need error but got nil", expr)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package datetime
 
-import "time"
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
 
 const (
 	DatetimeLayout = "2006-01-02 15:04:05"
@@ -57,6 +61,30 @@ func ParseDateWithLayout(val any, layout string) (result time.Time) {
 	return
 }
 
+var regDayDuration = regexp.MustCompile(`^([+-]?\d+(?:\.\d+)?)([dw])$`)
+
+// ParseDuration 解析时间偏移，在 time.ParseDuration 的基础上支持 d（天）、w（周）
+//
+//	例如："-1h"、"+15m"、"-7d"、"1w"；空字符串返回 0
+func ParseDuration(val string) (time.Duration, error) {
+	if val == "" {
+		return 0, nil
+	}
+	matches := regDayDuration.FindStringSubmatch(val)
+	if matches == nil {
+		return time.ParseDuration(val)
+	}
+	n, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	unit := 24 * time.Hour
+	if matches[2] == "w" {
+		unit *= 7
+	}
+	return time.Duration(n * float64(unit)), nil
+}
+
 // GetMonthRange 返回月开始（本月第一天零点）和月结束（下月第一天零点）
 //
 //	firstOfMonth <= date < lastOfMonth
@@ -0,0 +1,282 @@
+package esbuilder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Agg 聚合构建器
+type Agg struct {
+	typ  string
+	body map[string]any
+	subs map[string]*Agg
+}
+
+// NewAgg 创建指定类型的聚合，用于没有封装的聚合类型
+func NewAgg(typ string, body map[string]any) *Agg {
+	if body == nil {
+		body = map[string]any{}
+	}
+	return &Agg{typ: typ, body: body}
+}
+
+// TermsAgg 按字段的值分组计数，size <= 0 时使用 ES 的默认值
+func TermsAgg(field string, size int) *Agg {
+	agg := NewAgg("terms", map[string]any{"field": field})
+	if size > 0 {
+		agg.body["size"] = size
+	}
+	return agg
+}
+
+// calendarIntervals date_histogram 支持的日历间隔，其他间隔使用 fixed_interval
+var calendarIntervals = map[string]bool{
+	"minute": true, "1m": true,
+	"hour": true, "1h": true,
+	"day": true, "1d": true,
+	"week": true, "1w": true,
+	"month": true, "1M": true,
+	"quarter": true, "1q": true,
+	"year": true, "1y": true,
+}
+
+// DateHistogramAgg 按时间间隔分组计数
+//
+//	interval 为日历间隔（如：1d、month）时使用 calendar_interval，否则使用 fixed_interval（如：15m、12h）
+func DateHistogramAgg(field string, interval string) *Agg {
+	key := "fixed_interval"
+	if calendarIntervals[interval] {
+		key = "calendar_interval"
+	}
+	return NewAgg("date_histogram", map[string]any{
+		"field": field,
+		key:     interval,
+	})
+}
+
+// CardinalityAgg 字段去重计数，结果为近似值
+func CardinalityAgg(field string) *Agg {
+	return NewAgg("cardinality", map[string]any{"field": field})
+}
+
+// TopHitsAgg 返回每个分组中的数据，一般作为子聚合使用
+func TopHitsAgg(size int) *Agg {
+	return NewAgg("top_hits", map[string]any{"size": size})
+}
+
+// Set 设置聚合参数，如：min_doc_count、time_zone、order
+func (a *Agg) Set(key string, value any) *Agg {
+	a.body[key] = value
+	return a
+}
+
+// Sort 追加排序字段，用于 top_hits
+func (a *Agg) Sort(field string, desc bool) *Agg {
+	order := "asc"
+	if desc {
+		order = "desc"
+	}
+	sort, _ := a.body["sort"].([]map[string]any)
+	a.body["sort"] = append(sort, map[string]any{
+		field: map[string]any{
+			"order": order,
+		},
+	})
+	return a
+}
+
+// SubAgg 添加子聚合，name 相同时覆盖
+func (a *Agg) SubAgg(name string, sub *Agg) *Agg {
+	if a.subs == nil {
+		a.subs = map[string]*Agg{}
+	}
+	a.subs[name] = sub
+	return a
+}
+
+// Build 生成聚合请求体
+func (a *Agg) Build() map[string]any {
+	result := map[string]any{
+		a.typ: a.body,
+	}
+	if len(a.subs) > 0 {
+		subs := make(map[string]any, len(a.subs))
+		for name, sub := range a.subs {
+			subs[name] = sub.Build()
+		}
+		result["aggs"] = subs
+	}
+	return result
+}
+
+// Aggregations 聚合结果，key 为聚合名称
+type Aggregations map[string]json.RawMessage
+
+// Decode 把指定名称的聚合结果解析到 dest
+func (a Aggregations) Decode(name string, dest any) error {
+	data, ok := a[name]
+	if !ok {
+		return fmt.Errorf("aggregation not found: %s", name)
+	}
+	return json.Unmarshal(data, dest)
+}
+
+// TermsBucket terms 聚合的分组
+//
+//	Key 为 string 或 json.Number
+type TermsBucket struct {
+	Key          any          `json:"key"`
+	KeyAsString  string       `json:"key_as_string,omitempty"`
+	DocCount     int64        `json:"doc_count"`
+	Aggregations Aggregations `json:"aggregations,omitempty"` // 子聚合结果
+}
+
+// UnmarshalJSON 分组中除已知字段外的内容均为子聚合结果
+func (b *TermsBucket) UnmarshalJSON(data []byte) error {
+	fields, err := splitBucket(data, &b.Aggregations, "doc_count_error_upper_bound")
+	if err != nil {
+		return err
+	}
+	if err := decodeNumber(fields["key"], &b.Key); err != nil {
+		return err
+	}
+	if err := decodeField(fields["key_as_string"], &b.KeyAsString); err != nil {
+		return err
+	}
+	return decodeField(fields["doc_count"], &b.DocCount)
+}
+
+// DateHistogramBucket date_histogram 聚合的分组
+//
+//	Key 为毫秒时间戳
+type DateHistogramBucket struct {
+	Key          int64        `json:"key"`
+	KeyAsString  string       `json:"key_as_string,omitempty"`
+	DocCount     int64        `json:"doc_count"`
+	Aggregations Aggregations `json:"aggregations,omitempty"` // 子聚合结果
+}
+
+// Time 分组的开始时间
+func (b *DateHistogramBucket) Time() time.Time {
+	return time.UnixMilli(b.Key)
+}
+
+// UnmarshalJSON 分组中除已知字段外的内容均为子聚合结果
+func (b *DateHistogramBucket) UnmarshalJSON(data []byte) error {
+	fields, err := splitBucket(data, &b.Aggregations)
+	if err != nil {
+		return err
+	}
+	if err := decodeField(fields["key"], &b.Key); err != nil {
+		return err
+	}
+	if err := decodeField(fields["key_as_string"], &b.KeyAsString); err != nil {
+		return err
+	}
+	return decodeField(fields["doc_count"], &b.DocCount)
+}
+
+// Terms 解析 terms 聚合结果
+func (a Aggregations) Terms(name string) (buckets []*TermsBucket, err error) {
+	result := struct {
+		Buckets []*TermsBucket `json:"buckets"`
+	}{}
+	if err := a.Decode(name, &result); err != nil {
+		return nil, err
+	}
+	return result.Buckets, nil
+}
+
+// DateHistogram 解析 date_histogram 聚合结果
+func (a Aggregations) DateHistogram(name string) (buckets []*DateHistogramBucket, err error) {
+	result := struct {
+		Buckets []*DateHistogramBucket `json:"buckets"`
+	}{}
+	if err := a.Decode(name, &result); err != nil {
+		return nil, err
+	}
+	return result.Buckets, nil
+}
+
+// Cardinality 解析 cardinality 聚合结果
+func (a Aggregations) Cardinality(name string) (value int64, err error) {
+	result := struct {
+		Value int64 `json:"value"`
+	}{}
+	if err := a.Decode(name, &result); err != nil {
+		return 0, err
+	}
+	return result.Value, nil
+}
+
+// TopHits 解析 top_hits 聚合结果
+func (a Aggregations) TopHits(name string) (total Total, hits []*Hit, err error) {
+	result := struct {
+		Hits struct {
+			Total Total  `json:"total"`
+			Hits  []*Hit `json:"hits"`
+		} `json:"hits"`
+	}{}
+	if err := a.Decode(name, &result); err != nil {
+		return Total{}, nil, err
+	}
+	return result.Hits.Total, result.Hits.Hits, nil
+}
+
+// DecodeTopHits 把 top_hits 聚合结果中数据的 _source 解析到 dest，dest 为切片的指针
+func (a Aggregations) DecodeTopHits(name string, dest any) error {
+	_, hits, err := a.TopHits(name)
+	if err != nil {
+		return err
+	}
+	return decodeHits(hits, dest)
+}
+
+// bucketFields 分组中的已知字段
+var bucketFields = map[string]bool{
+	"key":           true,
+	"key_as_string": true,
+	"doc_count":     true,
+}
+
+// splitBucket 拆分分组，返回已知字段，其他字段（ignore 除外）写入 aggs
+func splitBucket(data []byte, aggs *Aggregations, ignore ...string) (fields map[string]json.RawMessage, err error) {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	fields = map[string]json.RawMessage{}
+	for name, value := range raw {
+		if bucketFields[name] {
+			fields[name] = value
+			delete(raw, name)
+		}
+	}
+	for _, name := range ignore {
+		delete(raw, name)
+	}
+	if len(raw) > 0 {
+		*aggs = raw
+	}
+	return fields, nil
+}
+
+// decodeField 字段不存在时忽略
+func decodeField(data json.RawMessage, dest any) error {
+	if data == nil {
+		return nil
+	}
+	return json.Unmarshal(data, dest)
+}
+
+// decodeNumber 数字解析为 json.Number，避免 long 类型丢失精度
+func decodeNumber(data json.RawMessage, dest *any) error {
+	if data == nil {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(dest)
+}
@@ -0,0 +1,248 @@
+package esbuilder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jummyliu/pkg/expression/es_expr"
+)
+
+// SearchBuilder 查询构建器，包含查询条件、聚合、排序和 search_after 分页
+//
+//	方法均返回自身，可以链式调用；构建器不是并发安全的
+type SearchBuilder struct {
+	query       map[string]any
+	aggs        map[string]*Agg
+	sort        []map[string]any
+	searchAfter []any
+	source      []string
+	size        int
+	from        int
+	trackTotal  bool
+}
+
+// NewSearchBuilder 使用查询条件创建查询构建器，query 为空时查询全部
+//
+//	默认返回 10 条数据
+func NewSearchBuilder(query map[string]any) *SearchBuilder {
+	return &SearchBuilder{
+		query: query,
+		aggs:  map[string]*Agg{},
+		size:  10,
+	}
+}
+
+// NewSearchBuilderExpr 把表达式转换为查询条件，创建查询构建器
+//
+//	executor 为 nil 时使用 es_expr.StdExecutor；表达式为空时查询全部
+func NewSearchBuilderExpr(executor *es_expr.Executor, expr string, prefix, suffix string) (*SearchBuilder, error) {
+	if executor == nil {
+		executor = es_expr.StdExecutor
+	}
+	query, _, err := executor.DoExpr(expr, prefix, suffix)
+	if err != nil {
+		return nil, err
+	}
+	if query == nil && expr != "" {
+		// 表达式中存在不支持的条件，不能退化为查询全部
+		return nil, fmt.Errorf("unsupported expression: %s", expr)
+	}
+	return NewSearchBuilder(query), nil
+}
+
+// Size 返回的数据条数，只需要聚合结果时设置为 0
+func (b *SearchBuilder) Size(size int) *SearchBuilder {
+	b.size = size
+	return b
+}
+
+// From 返回数据的偏移，不能与 SearchAfter 同时使用
+func (b *SearchBuilder) From(from int) *SearchBuilder {
+	b.from = from
+	return b
+}
+
+// Sort 追加排序字段
+func (b *SearchBuilder) Sort(field string, desc bool) *SearchBuilder {
+	order := "asc"
+	if desc {
+		order = "desc"
+	}
+	b.sort = append(b.sort, map[string]any{
+		field: map[string]any{
+			"order": order,
+		},
+	})
+	return b
+}
+
+// SearchAfter 从指定的排序值之后开始查询，values 一般为上一页 SearchResult.NextSearchAfter 的结果
+//
+//	需要同时设置 Sort，并且排序字段能唯一确定一条数据
+func (b *SearchBuilder) SearchAfter(values ...any) *SearchBuilder {
+	b.searchAfter = values
+	return b
+}
+
+// Source 只返回指定的字段
+func (b *SearchBuilder) Source(fields ...string) *SearchBuilder {
+	b.source = fields
+	return b
+}
+
+// TrackTotalHits 精确统计总数，默认最多统计到 10000
+func (b *SearchBuilder) TrackTotalHits(track bool) *SearchBuilder {
+	b.trackTotal = track
+	return b
+}
+
+// Agg 添加聚合，name 相同时覆盖
+func (b *SearchBuilder) Agg(name string, agg *Agg) *SearchBuilder {
+	b.aggs[name] = agg
+	return b
+}
+
+// Build 生成查询请求体
+func (b *SearchBuilder) Build() (body map[string]any, err error) {
+	if len(b.searchAfter) > 0 {
+		if len(b.sort) == 0 {
+			return nil, errors.New("search_after need sort")
+		}
+		if b.from > 0 {
+			return nil, errors.New("search_after can not be used with from")
+		}
+	}
+	body = map[string]any{
+		"size": b.size,
+	}
+	if b.query != nil {
+		body["query"] = b.query
+	}
+	if b.from > 0 {
+		body["from"] = b.from
+	}
+	if len(b.sort) > 0 {
+		body["sort"] = b.sort
+	}
+	if len(b.searchAfter) > 0 {
+		body["search_after"] = b.searchAfter
+	}
+	if b.source != nil {
+		body["_source"] = b.source
+	}
+	if b.trackTotal {
+		body["track_total_hits"] = true
+	}
+	if len(b.aggs) > 0 {
+		aggs := make(map[string]any, len(b.aggs))
+		for name, agg := range b.aggs {
+			aggs[name] = agg.Build()
+		}
+		body["aggs"] = aggs
+	}
+	return body, nil
+}
+
+// SearchResult 查询结果
+type SearchResult struct {
+	Took     int64 `json:"took"`
+	TimedOut bool  `json:"timed_out"`
+	Hits     struct {
+		Total    Total    `json:"total"`
+		MaxScore *float64 `json:"max_score"`
+		Hits     []*Hit   `json:"hits"`
+	} `json:"hits"`
+	Aggregations Aggregations `json:"aggregations"`
+}
+
+// Total 命中总数，Relation 为 "gte" 时 Value 为下限
+type Total struct {
+	Value    int64  `json:"value"`
+	Relation string `json:"relation"`
+}
+
+// Hit 命中的数据
+type Hit struct {
+	Index  string          `json:"_index"`
+	ID     string          `json:"_id"`
+	Score  *float64        `json:"_score"`
+	Source json.RawMessage `json:"_source"`
+	Sort   []any           `json:"sort"`
+}
+
+// UnmarshalJSON sort 中的数字解析为 json.Number，避免 long 类型丢失精度，作为 search_after 时原样传回
+func (h *Hit) UnmarshalJSON(data []byte) error {
+	type hit Hit
+	raw := struct {
+		*hit
+		Sort json.RawMessage `json:"sort"`
+	}{hit: (*hit)(h)}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	h.Sort = nil
+	if raw.Sort == nil {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw.Sort))
+	decoder.UseNumber()
+	return decoder.Decode(&h.Sort)
+}
+
+// DecodeHits 把命中数据的 _source 解析到 dest，dest 为切片的指针
+func (r *SearchResult) DecodeHits(dest any) error {
+	return decodeHits(r.Hits.Hits, dest)
+}
+
+// NextSearchAfter 下一页的 search_after，没有数据时返回 nil
+func (r *SearchResult) NextSearchAfter() []any {
+	if len(r.Hits.Hits) == 0 {
+		return nil
+	}
+	return r.Hits.Hits[len(r.Hits.Hits)-1].Sort
+}
+
+// decodeHits 把 _source 拼接成数组后一次解析
+func decodeHits(hits []*Hit, dest any) error {
+	sources := make([]json.RawMessage, 0, len(hits))
+	for _, hit := range hits {
+		sources = append(sources, hit.Source)
+	}
+	data, err := json.Marshal(sources)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dest)
+}
+
+// DoSearch 执行查询
+func (db *DBConnect) DoSearch(ctx context.Context, builder *SearchBuilder, index ...string) (result *SearchResult, err error) {
+	body, err := builder.Build()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := db.Search(
+		db.Search.WithContext(ctx),
+		db.Search.WithIndex(index...),
+		db.Search.WithBody(bytes.NewReader(data)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return nil, fmt.Errorf("search failure: %s", resp.String())
+	}
+	result = &SearchResult{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("decode search result failure: %s", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,201 @@
+package esbuilder
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+const testResponse = `{
+	"took": 3,
+	"timed_out": false,
+	"hits": {
+		"total": {"value": 42, "relation": "eq"},
+		"max_score": null,
+		"hits": [
+			{"_index": "events", "_id": "1", "_score": null, "_source": {"user": "admin", "port": 443}, "sort": [1717200000000, "1"]},
+			{"_index": "events", "_id": "2", "_score": null, "_source": {"user": "root", "port": 22}, "sort": [9007199254740993, "2"]}
+		]
+	},
+	"aggregations": {
+		"users": {
+			"doc_count_error_upper_bound": 0,
+			"sum_other_doc_count": 0,
+			"buckets": [
+				{"key": "admin", "doc_count": 30, "ips": {"value": 5}, "latest": {"hits": {"total": {"value": 30, "relation": "eq"}, "hits": [{"_index": "events", "_id": "9", "_source": {"user": "admin", "port": 8080}}]}}},
+				{"key": "root", "doc_count": 12, "ips": {"value": 1}, "latest": {"hits": {"total": {"value": 12, "relation": "eq"}, "hits": []}}}
+			]
+		},
+		"ports": {
+			"buckets": [
+				{"key": 9007199254740993, "doc_count": 1}
+			]
+		},
+		"per_hour": {
+			"buckets": [
+				{"key_as_string": "2024-06-01T00:00:00.000Z", "key": 1717200000000, "doc_count": 40},
+				{"key_as_string": "2024-06-01T01:00:00.000Z", "key": 1717203600000, "doc_count": 2}
+			]
+		}
+	}
+}`
+
+type testEvent struct {
+	User string `json:"user"`
+	Port int    `json:"port"`
+}
+
+func TestSearchBuilder(t *testing.T) {
+	builder, err := NewSearchBuilderExpr(nil, "port >= 400", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := builder.
+		Size(0).
+		Sort("@timestamp", true).
+		Sort("_id", false).
+		SearchAfter(float64(1717200000000), "1").
+		Agg("users", TermsAgg("user.keyword", 5).
+			SubAgg("ips", CardinalityAgg("src_ip")).
+			SubAgg("latest", TopHitsAgg(1).Sort("@timestamp", true))).
+		Agg("per_hour", DateHistogramAgg("@timestamp", "1h").Set("min_doc_count", 0)).
+		Agg("per_15m", DateHistogramAgg("@timestamp", "15m")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	need := map[string]any{
+		"size":  0,
+		"query": map[string]any{"range": map[string]any{"port": map[string]any{"gte": float64(400)}}},
+		"sort": []map[string]any{
+			{"@timestamp": map[string]any{"order": "desc"}},
+			{"_id": map[string]any{"order": "asc"}},
+		},
+		"search_after": []any{float64(1717200000000), "1"},
+		"aggs": map[string]any{
+			"users": map[string]any{
+				"terms": map[string]any{"field": "user.keyword", "size": 5},
+				"aggs": map[string]any{
+					"ips": map[string]any{"cardinality": map[string]any{"field": "src_ip"}},
+					"latest": map[string]any{"top_hits": map[string]any{
+						"size": 1,
+						"sort": []map[string]any{{"@timestamp": map[string]any{"order": "desc"}}},
+					}},
+				},
+			},
+			"per_hour": map[string]any{"date_histogram": map[string]any{"field": "@timestamp", "calendar_interval": "1h", "min_doc_count": 0}},
+			"per_15m":  map[string]any{"date_histogram": map[string]any{"field": "@timestamp", "fixed_interval": "15m"}},
+		},
+	}
+	if !reflect.DeepEqual(body, need) {
+		t.Fatalf("need %#v but got %#v", need, body)
+	}
+
+	if _, err := NewSearchBuilder(nil).SearchAfter("1").Build(); err == nil {
+		t.Fatal("search_after without sort need error but got nil")
+	}
+	if _, err := NewSearchBuilderExpr(nil, "port containsBit 4", "", ""); err == nil {
+		t.Fatal("unsupported expression need error but got nil")
+	}
+}
+
+func TestDoSearch(t *testing.T) {
+	var request map[string]any
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &request)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		io.WriteString(w, testResponse)
+	}))
+	defer server.Close()
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{server.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &DBConnect{Client: client}
+
+	builder := NewSearchBuilder(nil).Size(2).Sort("@timestamp", false).Sort("_id", false).
+		Agg("users", TermsAgg("user.keyword", 0))
+	result, err := db.DoSearch(context.Background(), builder, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/events/_search" {
+		t.Fatalf("need path /events/_search but got %s", path)
+	}
+	if _, ok := request["query"]; ok || request["size"] != float64(2) {
+		t.Fatalf("unexpected request %v", request)
+	}
+
+	if result.Hits.Total.Value != 42 {
+		t.Fatalf("need total 42 but got %d", result.Hits.Total.Value)
+	}
+	events := []testEvent{}
+	if err := result.DecodeHits(&events); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(events, []testEvent{{User: "admin", Port: 443}, {User: "root", Port: 22}}) {
+		t.Fatalf("unexpected hits %v", events)
+	}
+	// 超过 2^53 的 long 排序值需要原样传回
+	next := result.NextSearchAfter()
+	if !reflect.DeepEqual(next, []any{json.Number("9007199254740993"), "2"}) {
+		t.Fatalf("unexpected search_after %v", next)
+	}
+	body, err := NewSearchBuilder(nil).Sort("@timestamp", false).SearchAfter(next...).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := json.Marshal(body["search_after"]); string(data) != `[9007199254740993,"2"]` {
+		t.Fatalf("need exact search_after but got %s", data)
+	}
+
+	users, err := result.Aggregations.Terms("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].Key != "admin" || users[0].DocCount != 30 || users[1].Key != "root" {
+		t.Fatalf("unexpected buckets %v", users)
+	}
+	if ips, err := users[0].Aggregations.Cardinality("ips"); err != nil || ips != 5 {
+		t.Fatalf("need cardinality 5 but got %d %v", ips, err)
+	}
+	latest := []testEvent{}
+	if err := users[0].Aggregations.DecodeTopHits("latest", &latest); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(latest, []testEvent{{User: "admin", Port: 8080}}) {
+		t.Fatalf("unexpected top hits %v", latest)
+	}
+	if _, ok := users[0].Aggregations["doc_count_error_upper_bound"]; ok {
+		t.Fatal("bucket metadata need not be sub aggregation")
+	}
+
+	ports, err := result.Aggregations.Terms("ports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ports[0].Key != json.Number("9007199254740993") {
+		t.Fatalf("need exact long key but got %v", ports[0].Key)
+	}
+
+	hours, err := result.Aggregations.DateHistogram("per_hour")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hours) != 2 || hours[1].DocCount != 2 || hours[1].Time().UnixMilli() != 1717203600000 {
+		t.Fatalf("unexpected buckets %v", hours)
+	}
+	if _, err := result.Aggregations.Cardinality("missing"); err == nil {
+		t.Fatal("missing aggregation need error but got nil")
+	}
+}
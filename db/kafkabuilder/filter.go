@@ -0,0 +1,33 @@
+package kafkabuilder
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jummyliu/pkg/expression/cond_expr"
+	"github.com/segmentio/kafka-go"
+)
+
+// MessageReader 读取消息，*kafka.Reader 实现了该接口
+type MessageReader interface {
+	ReadMessage(ctx context.Context) (kafka.Message, error)
+}
+
+// ReadMatch 读取消息，直到消息的 JSON 内容满足规则
+//
+//	不满足规则、或者内容不是 JSON 对象的消息会被跳过（使用消费者组时同样会提交）
+func ReadMatch(ctx context.Context, reader MessageReader, p *cond_expr.Program) (msg kafka.Message, data map[string]any, err error) {
+	for {
+		msg, err = reader.ReadMessage(ctx)
+		if err != nil {
+			return msg, nil, err
+		}
+		data = map[string]any{}
+		if err := json.Unmarshal(msg.Value, &data); err != nil {
+			continue
+		}
+		if p.Eval(data) {
+			return msg, data, nil
+		}
+	}
+}
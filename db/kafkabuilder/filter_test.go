@@ -0,0 +1,52 @@
+package kafkabuilder
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/jummyliu/pkg/expression/cond_expr"
+	"github.com/segmentio/kafka-go"
+)
+
+type sliceReader []kafka.Message
+
+func (r *sliceReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	if len(*r) == 0 {
+		return kafka.Message{}, io.EOF
+	}
+	msg := (*r)[0]
+	*r = (*r)[1:]
+	return msg, nil
+}
+
+func TestReadMatch(t *testing.T) {
+	reader := &sliceReader{
+		{Offset: 0, Value: []byte(`{"level": "info", "code": 200}`)},
+		{Offset: 1, Value: []byte(`not json`)},
+		{Offset: 2, Value: []byte(`{"level": "error", "code": 500}`)},
+		{Offset: 3, Value: []byte(`{"level": "error", "code": 404}`)},
+		{Offset: 4, Value: []byte(`{"level": "warn", "code": 503}`)},
+	}
+	p, err := cond_expr.Compile("level in 'error,warn' && code >= 500")
+	if err != nil {
+		t.Fatalf("compile failure: %s", err)
+	}
+	offsets := []int64{}
+	for {
+		msg, data, err := ReadMatch(context.Background(), reader, p)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read failure: %s", err)
+		}
+		if data["code"].(float64) < 500 {
+			t.Fatalf("offset %d need code >= 500 but got %v", msg.Offset, data["code"])
+		}
+		offsets = append(offsets, msg.Offset)
+	}
+	if len(offsets) != 2 || offsets[0] != 2 || offsets[1] != 4 {
+		t.Fatalf("need offsets [2 4] but got %v", offsets)
+	}
+}
@@ -19,6 +19,9 @@ type Options struct {
 	Loc     string
 
 	DBFilePath string
+
+	// Regexp 是否注册 regexp 函数，用于支持 `x REGEXP y`
+	Regexp bool
 }
 
 func WithUser(user string) Option {
@@ -75,6 +78,13 @@ func WithDBFilePath(DBFilePath string) Option {
 	}
 }
 
+// WithRegexp 注册 regexp 函数，见 RegisterRegexp
+func WithRegexp() Option {
+	return func(opts *Options) {
+		opts.Regexp = true
+	}
+}
+
 func initOptions(opts ...Option) *Options {
 	options := &Options{
 		User:     "root",
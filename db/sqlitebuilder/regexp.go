@@ -0,0 +1,108 @@
+package sqlitebuilder
+
+import (
+	"container/list"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"modernc.org/sqlite"
+)
+
+// regexpCacheSize 正则缓存的最大个数
+const regexpCacheSize = 256
+
+var (
+	registerOnce sync.Once
+	registerErr  error
+
+	// regexpCache 编译后的正则缓存，超出 regexpCacheSize 时淘汰最久未使用的
+	regexpCache = newRegexpLRU(regexpCacheSize)
+)
+
+// RegisterRegexp 注册 regexp 函数，注册后支持 `x REGEXP y`，即 regexp(y, x)
+//
+//	sqlite 的自定义函数是驱动级别的，注册后对之后打开的所有连接生效，重复调用只注册一次
+func RegisterRegexp() error {
+	registerOnce.Do(func() {
+		registerErr = sqlite.RegisterDeterministicScalarFunction("regexp", 2, regexpFn)
+	})
+	return registerErr
+}
+
+// regexpFn regexp(pattern, value)，任意参数为 NULL 时返回 NULL
+func regexpFn(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	}
+	re, err := regexpCache.get(toString(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	if re.MatchString(toString(args[1])) {
+		return int64(1), nil
+	}
+	return int64(0), nil
+}
+
+type regexpEntry struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// regexpLRU 固定大小的正则缓存
+type regexpLRU struct {
+	mu    sync.Mutex
+	size  int
+	list  *list.List
+	items map[string]*list.Element
+}
+
+func newRegexpLRU(size int) *regexpLRU {
+	return &regexpLRU{
+		size:  size,
+		list:  list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+// get 获取编译后的正则，不存在时编译并缓存
+func (c *regexpLRU) get(pattern string) (*regexp.Regexp, error) {
+	c.mu.Lock()
+	if elem, ok := c.items[pattern]; ok {
+		c.list.MoveToFront(elem)
+		c.mu.Unlock()
+		return elem.Value.(*regexpEntry).re, nil
+	}
+	c.mu.Unlock()
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[pattern]; ok {
+		c.list.MoveToFront(elem)
+		return elem.Value.(*regexpEntry).re, nil
+	}
+	c.items[pattern] = c.list.PushFront(&regexpEntry{pattern: pattern, re: re})
+	if c.list.Len() > c.size {
+		oldest := c.list.Back()
+		c.list.Remove(oldest)
+		delete(c.items, oldest.Value.(*regexpEntry).pattern)
+	}
+	return re, nil
+}
+
+func toString(val driver.Value) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(val)
+}
@@ -26,6 +26,11 @@ type DBConnect struct {
 // New return a new mysql client, and try ping.
 func New(opts ...Option) (*DBConnect, error) {
 	options := initOptions(opts...)
+	if options.Regexp {
+		if err := RegisterRegexp(); err != nil {
+			return nil, err
+		}
+	}
 	// driver := BuildDBDriver(options)
 	db, err := sql.Open("sqlite", options.DBFilePath)
 	if err != nil {
@@ -0,0 +1,345 @@
+package expression
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+// regKey、regFunc 字段名、函数名，必须完整匹配，避免拼接到 SQL 等语句中
+var (
+	regKey  = regexp.MustCompile(`^[a-zA-Z_][\w\\.\-]*$`)
+	regFunc = regexp.MustCompile(`^[a-zA-Z_]\w*$`)
+)
+
+// And 使用 && 连接所有条件，忽略 nil
+//
+//	a := expression.And(expression.Cond("a", "==", 1), expression.Or(...))
+//	任意条件不合法时返回该 ILLEGAL 节点，组合的条件不会只执行合法的部分
+func And(nodes ...*AstNode) *AstNode {
+	return join("&&", nodes)
+}
+
+// Or 使用 || 连接所有条件，忽略 nil
+func Or(nodes ...*AstNode) *AstNode {
+	return join("||", nodes)
+}
+
+// join 连接所有条件，生成左结合的语法树
+func join(op string, nodes []*AstNode) *AstNode {
+	var tree *AstNode
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		if node.Type == token.ILLEGAL {
+			return node
+		}
+		if tree == nil {
+			tree = node
+			continue
+		}
+		tree = &AstNode{
+			Type:  token.OPERATOR,
+			Value: op,
+			Left:  tree,
+			Right: node,
+		}
+	}
+	if tree == nil {
+		return illegalAst(fmt.Errorf("%s need at least 1 condition", op))
+	}
+	return tree
+}
+
+// Not 取反
+func Not(node *AstNode) *AstNode {
+	if node == nil {
+		return illegalAst(fmt.Errorf("not need a condition"))
+	}
+	if node.Type == token.ILLEGAL {
+		return node
+	}
+	return &AstNode{
+		Type:  token.NOT,
+		Value: "!",
+		Left:  node,
+	}
+}
+
+// Cond 条件
+//
+//	key 为字段名，或者 Call 生成的函数调用
+//	value 支持 nil、bool、数字、string、数组，以及 Field、Call 生成的节点
+//	一元条件（exists、notExists）的 value 传 nil
+func Cond(key any, op string, value any) *AstNode {
+	left, err := operandAst(key)
+	if err != nil {
+		return illegalAst(err)
+	}
+	if !isCondition(op) {
+		return illegalAst(fmt.Errorf("illegal condition: %s", op))
+	}
+	right, err := valueAst(value)
+	if err != nil {
+		return illegalAst(err)
+	}
+	if token.REG_UNARY.MatchString(op) && right.Type != token.NULL {
+		return illegalAst(fmt.Errorf("%s need no value but got %T", op, value))
+	}
+	if arr, ok := right.Value.([]any); op == "between" && (right.Type != token.ARRAY || !ok || len(arr) != 2) {
+		return illegalAst(fmt.Errorf("between need an array of 2 items"))
+	}
+	if op == "inCidr" || op == "notInCidr" {
+		if _, err := ParseCidrs(right.Value); err != nil {
+			return illegalAst(err)
+		}
+	}
+	return &AstNode{
+		Type:  token.CONDITION,
+		Value: op,
+		Left:  left,
+		Right: right,
+	}
+}
+
+// Exists 字段存在
+func Exists(key any) *AstNode {
+	return Cond(key, "exists", nil)
+}
+
+// NotExists 字段不存在
+func NotExists(key any) *AstNode {
+	return Cond(key, "notExists", nil)
+}
+
+// Any 数组元素条件，数组 path 中任意一个元素满足 node
+//
+//	node 中的字段相对于数组元素：expression.Any("processes", expression.Cond("name", "==", "sh"))
+func Any(path string, node *AstNode) *AstNode {
+	return quant("any", path, node)
+}
+
+// All 数组元素条件，数组 path 中所有元素都满足 node，空数组为 true
+func All(path string, node *AstNode) *AstNode {
+	return quant("all", path, node)
+}
+
+func quant(name string, path string, node *AstNode) *AstNode {
+	if !isKey(path) {
+		return illegalAst(fmt.Errorf("illegal key: %s", path))
+	}
+	if node == nil {
+		return illegalAst(fmt.Errorf("%s need a condition", name))
+	}
+	if node.Type == token.ILLEGAL {
+		return node
+	}
+	return &AstNode{
+		Type:  token.QUANT,
+		Value: name,
+		Left:  &AstNode{Type: token.IDENT, Value: path},
+		Right: node,
+	}
+}
+
+// Key 字段，用作函数调用的参数
+func Key(name string) *AstNode {
+	if !isKey(name) {
+		return illegalAst(fmt.Errorf("illegal key: %s", name))
+	}
+	return &AstNode{Type: token.IDENT, Value: name}
+}
+
+// Field 字段引用，用作条件的值，与左侧字段比较
+func Field(name string) *AstNode {
+	if !isField(name) {
+		return illegalAst(fmt.Errorf("illegal field: %s", name))
+	}
+	return &AstNode{Type: token.FIELD, Value: name}
+}
+
+// Call 函数调用，参数支持 nil、bool、数字、string，以及 Key、Call 生成的节点
+func Call(name string, args ...any) *AstNode {
+	if !regFunc.MatchString(name) {
+		return illegalAst(fmt.Errorf("illegal function: %s", name))
+	}
+	node := &AstNode{Type: token.FUNC, Value: name}
+	for _, arg := range args {
+		if item, ok := arg.(*AstNode); ok && item != nil && item.Type == token.IDENT {
+			node.Args = append(node.Args, item)
+			continue
+		}
+		item, err := valueAst(arg)
+		if err != nil {
+			return illegalAst(err)
+		}
+		if item.Type == token.ARRAY || item.Type == token.FIELD {
+			return illegalAst(fmt.Errorf("illegal argument of %s: %s", name, item.Type))
+		}
+		node.Args = append(node.Args, item)
+	}
+	return node
+}
+
+// CheckAst 检查语法树，返回 builder 生成的第一个错误
+func CheckAst(ast *AstNode) error {
+	if ast == nil {
+		return fmt.Errorf("empty expression")
+	}
+	if ast.Type == token.ILLEGAL {
+		if err, ok := ast.Value.(error); ok {
+			return err
+		}
+		return fmt.Errorf("illegal node: %v", ast.Value)
+	}
+	for _, node := range []*AstNode{ast.Left, ast.Right} {
+		if node == nil {
+			continue
+		}
+		if err := CheckAst(node); err != nil {
+			return err
+		}
+	}
+	for _, arg := range ast.Args {
+		if err := CheckAst(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isKey 字段名需要完整匹配 regKey，并且 Format 后能重新解析为同一个字段
+//
+//	保留字不能作为字段名，如：not、true、trueish、null、in、exists
+func isKey(name string) bool {
+	if !regKey.MatchString(name) {
+		return false
+	}
+	tokens, err := LexParse(TokensRead(name + " exists"))
+	return err == nil && len(tokens) == 2 && tokens[0].Type == token.IDENT && tokens[0].Value == name
+}
+
+// isField 字段引用需要完整匹配 regKey，并且 Format 后能重新解析为同一个字段引用
+func isField(name string) bool {
+	if !regKey.MatchString(name) {
+		return false
+	}
+	tokens, err := LexParse(TokensRead("a == $" + name))
+	return err == nil && len(tokens) == 3 && tokens[2].Type == token.FIELD && tokens[2].Value == name
+}
+
+// illegalAst 把错误包装成 ILLEGAL 节点，CheckAst 会返回该错误
+//
+//	执行器遇到 ILLEGAL 节点时不匹配任何数据，cond_expr.CompileAst、label_expr 返回该错误
+func illegalAst(err error) *AstNode {
+	return &AstNode{
+		Type:  token.ILLEGAL,
+		Value: err,
+	}
+}
+
+// isCondition 判断是否为合法的条件
+func isCondition(op string) bool {
+	if strings.TrimSpace(op) != op {
+		return false
+	}
+	for _, reg := range []*regexp.Regexp{token.REG_CONDITION, token.REG_UNARY} {
+		if reg.FindString(op) == op {
+			return true
+		}
+	}
+	return false
+}
+
+// operandAst 条件左侧的节点
+func operandAst(key any) (*AstNode, error) {
+	switch v := key.(type) {
+	case string:
+		node := Key(v)
+		if node.Type == token.ILLEGAL {
+			return nil, node.Value.(error)
+		}
+		return node, nil
+	case *AstNode:
+		if v != nil && (v.Type == token.IDENT || v.Type == token.FUNC) {
+			return v, nil
+		}
+		if v != nil && v.Type == token.ILLEGAL {
+			return nil, CheckAst(v)
+		}
+	}
+	return nil, fmt.Errorf("illegal key: %v", key)
+}
+
+// valueAst 条件右侧的节点
+func valueAst(value any) (*AstNode, error) {
+	if node, ok := value.(*AstNode); ok {
+		if err := CheckAst(node); err != nil {
+			return nil, err
+		}
+		switch node.Type {
+		case token.FIELD, token.FUNC:
+			return node, nil
+		}
+		return nil, fmt.Errorf("illegal value: %s", node.Type)
+	}
+	if value == nil {
+		return &AstNode{Type: token.NULL, Value: nil}, nil
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		arr := make([]any, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			item, t, ok := literal(rv.Index(i).Interface())
+			if !ok || t == token.NULL {
+				return nil, fmt.Errorf("illegal array item: %T", rv.Index(i).Interface())
+			}
+			arr = append(arr, item)
+		}
+		return &AstNode{Type: token.ARRAY, Value: arr}, nil
+	}
+	item, t, ok := literal(value)
+	if !ok {
+		return nil, fmt.Errorf("illegal value: %T", value)
+	}
+	return &AstNode{Type: t, Value: item}, nil
+}
+
+// literal 把 go 的值转换为字面量，数字统一转换为 float64，time.Time、token.Date 转换为日期
+func literal(value any) (val any, t token.Token, ok bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, token.NULL, true
+	case token.Date:
+		if !token.IsDate(string(v)) {
+			return nil, "", false
+		}
+		return v, token.DATE, true
+	case time.Time:
+		return token.Date(v.Format(time.RFC3339Nano)), token.DATE, true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), token.BOOL, true
+	case reflect.String:
+		return rv.String(), token.STRING, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), token.NUM, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), token.NUM, true
+	case reflect.Float32, reflect.Float64:
+		// NaN、Inf 无法表示为字面量
+		if math.IsNaN(rv.Float()) || math.IsInf(rv.Float(), 0) {
+			return nil, "", false
+		}
+		return rv.Float(), token.NUM, true
+	}
+	return nil, "", false
+}
@@ -0,0 +1,101 @@
+package expression
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+func TestBuilder(t *testing.T) {
+	testCases := []struct {
+		Ast    *AstNode
+		Result string
+	}{
+		{
+			Ast:    And(Cond("a", "==", 1), Or(Cond("b", "!=", "it's"), Cond("c", ">=", 2.5)), nil),
+			Result: `a == 1 && (b != "it's" || c >= 2.5)`,
+		},
+		{
+			Ast:    Or(And(Cond("port", "in", []int{80, 443}), Exists("ip")), Not(Cond("user", "notIn", []string{"a", "b,c"}))),
+			Result: "port in [80, 443] && ip exists || !(user notIn ['a', 'b,c'])",
+		},
+		{
+			Ast:    And(Cond(Call("lower", Key("user")), "==", "root"), Cond("bytes_out", ">", Field("bytes_in")), Cond("ts", "<", Call("now", "-1h")), Cond("deleted", "is", nil)),
+			Result: "lower(user) == 'root' && bytes_out > $bytes_in && ts < now('-1h') && deleted is null",
+		},
+		{
+			Ast:    And(Cond("ts", "between", []any{token.Date("now-15m"), time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)}), Cond("ts", "<", token.Date("now/d"))),
+			Result: "ts between [now-15m, 2024-01-01T08:00:00Z] && ts < now/d",
+		},
+		{
+			Ast:    And(Any("processes", And(Cond("name", "==", "sh"), Not(All("args", Cond("v", "!=", "-c"))))), Cond("host", "exists", nil)),
+			Result: "any(processes, name == 'sh' && !(all(args, v != '-c'))) && host exists",
+		},
+	}
+	for _, testCase := range testCases {
+		if err := CheckAst(testCase.Ast); err != nil {
+			t.Fatalf("testCase %s check failure: %s", testCase.Result, err)
+		}
+		result := Format(testCase.Ast)
+		if result != testCase.Result {
+			t.Fatalf("need %s but got %s", testCase.Result, result)
+		}
+		if !reflect.DeepEqual(parseAst(t, result), testCase.Ast) {
+			t.Fatalf("testCase %s got a different ast", testCase.Result)
+		}
+	}
+}
+
+func TestBuilderIllegal(t *testing.T) {
+	testCases := []*AstNode{
+		Cond("a' OR 1=1 --", "==", 1),
+		Cond("a", "== 1 || b", 1),
+		Cond("a", "glob", 1),
+		Cond("a", "==", map[string]any{}),
+		Cond("a", "in", []any{nil}),
+		Cond("ts", ">", token.Date("yesterday")),
+		Cond("ts", ">", token.Date("2024-13-45")),
+		Cond("ts", "between", []any{token.Date("now-1d")}),
+		Cond("ip", "inCidr", "bad"),
+		Cond("ip", "notInCidr", []any{"10.0.0.0/8", "x"}),
+		Cond("a", "exists", 1),
+		Cond("a", "==", Key("b")),
+		Cond(Call("lower", []int{1}), "==", "x"),
+		Cond(Call("drop table"), "==", "x"),
+		And(),
+		Or(nil, nil),
+		Not(nil),
+		Any("processes[0]", Cond("name", "==", "sh")),
+		All("processes", nil),
+		Any("processes", Cond("", "==", "sh")),
+		And(Cond("a", "==", 1), Not(Cond("", "==", 1))),
+		Or(Cond("a", "==", 1), Cond("a", "glob", 1)),
+	}
+	for i, ast := range testCases {
+		if err := CheckAst(ast); err == nil {
+			t.Fatalf("testCase %d need error but got %s", i, Format(ast))
+		}
+	}
+}
+
+// TestBuilderReserved 保留字不能作为字段名，Format 后无法重新解析；以保留字开头的字段名可以重新解析
+func TestBuilderReserved(t *testing.T) {
+	for _, name := range []string{"not", "true", "trueish", "false", "null", "in", "exists", "between", "like", "inCidr"} {
+		for _, ast := range []*AstNode{Cond(name, "==", 1), Cond(Call("lower", Key(name)), "==", "x"), Any(name, Cond("v", "==", 1))} {
+			if err := CheckAst(ast); err == nil {
+				t.Fatalf("key %s need error but got %s", name, Format(ast))
+			}
+		}
+	}
+	for _, name := range []string{"nullable", "notx", "index", "exists_at", "now", "any", "not_found"} {
+		ast := And(Cond(name, "==", Field(name)), Cond(Call("lower", Key(name)), "==", "x"), Any(name, Cond("v", "==", 1)))
+		if err := CheckAst(ast); err != nil {
+			t.Fatalf("key %s check failure: %s", name, err)
+		}
+		if result := Format(ast); !reflect.DeepEqual(parseAst(t, result), ast) {
+			t.Fatalf("key %s got a different ast from %s", name, result)
+		}
+	}
+}
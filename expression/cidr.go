@@ -0,0 +1,69 @@
+package expression
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ParseCidr 解析 CIDR，如：10.0.0.0/8、fd00::/8
+//
+//	没有前缀长度时为单个地址，如：10.0.0.1 => 10.0.0.1/32；返回的前缀已去掉主机位
+func ParseCidr(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = addr.WithZone("")
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix.Masked(), nil
+}
+
+// ParseCidrs 解析 inCidr、notInCidr 的值，value 为逗号分隔的字符串，或者字符串数组
+func ParseCidrs(value any) ([]netip.Prefix, error) {
+	var items []string
+	switch val := value.(type) {
+	case string:
+		items = strings.Split(val, ",")
+	case []any:
+		items = make([]string, 0, len(val))
+		for _, item := range val {
+			str, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("cidr need a string but got %T", item)
+			}
+			items = append(items, str)
+		}
+	default:
+		return nil, fmt.Errorf("cidr need a string or an array but got %T", value)
+	}
+	prefixes := make([]netip.Prefix, 0, len(items))
+	for _, item := range items {
+		prefix, err := ParseCidr(item)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// CidrRange CIDR 的第一个和最后一个地址
+func CidrRange(prefix netip.Prefix) (from, to netip.Addr) {
+	from = prefix.Masked().Addr()
+	b := from.AsSlice()
+	for i := range b {
+		// 当前字节中网络位的个数，其余为主机位
+		n := min(max(prefix.Bits()-i*8, 0), 8)
+		b[i] |= byte(0xff >> n)
+	}
+	to, _ = netip.AddrFromSlice(b)
+	return from, to
+}
@@ -0,0 +1,47 @@
+package expression
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestCidr(t *testing.T) {
+	testCases := []struct {
+		Cidr string
+		From string
+		To   string
+	}{
+		{Cidr: "10.0.0.0/8", From: "10.0.0.0", To: "10.255.255.255"},
+		{Cidr: " 192.168.1.77/20", From: "192.168.0.0", To: "192.168.15.255"},
+		{Cidr: "10.0.0.1", From: "10.0.0.1", To: "10.0.0.1"},
+		{Cidr: "0.0.0.0/0", From: "0.0.0.0", To: "255.255.255.255"},
+		{Cidr: "fd00::/8", From: "fd00::", To: "fdff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{Cidr: "2001:db8::1/127", From: "2001:db8::", To: "2001:db8::1"},
+		{Cidr: "fe80::1%eth0", From: "fe80::1", To: "fe80::1"},
+	}
+	for _, testCase := range testCases {
+		prefix, err := ParseCidr(testCase.Cidr)
+		if err != nil {
+			t.Fatalf("testCase %s parse failure: %s", testCase.Cidr, err)
+		}
+		from, to := CidrRange(prefix)
+		if from != netip.MustParseAddr(testCase.From) || to != netip.MustParseAddr(testCase.To) {
+			t.Fatalf("testCase %s need [%s, %s] but got [%s, %s]", testCase.Cidr, testCase.From, testCase.To, from, to)
+		}
+	}
+
+	prefixes, err := ParseCidrs("10.0.0.0/8,fd00::/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	need := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")}
+	if !reflect.DeepEqual(prefixes, need) {
+		t.Fatalf("need %v but got %v", need, prefixes)
+	}
+	for _, value := range []any{"10.0.0.0/33", "10.0.0", []any{"10.0.0.0/8", 1}, float64(1)} {
+		if _, err := ParseCidrs(value); err == nil {
+			t.Fatalf("testCase %v need error but got nil", value)
+		}
+	}
+}
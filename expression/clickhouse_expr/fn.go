@@ -2,7 +2,11 @@ package clickhouse_expr
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/sqlfunc"
 	"github.com/jummyliu/pkg/expression/token"
 	"github.com/jummyliu/pkg/number"
 )
@@ -14,27 +18,47 @@ var DefaultFnMap = map[string]map[token.Token]ConditionFn{
 		token.NUM:    equal[float64],
 		token.BOOL:   equal[bool],
 		token.STRING: equal[string],
+		token.NULL:   isNull,
+		token.FIELD:  fieldCompare("="),
+		token.FUNC:   sqlfunc.Compare("="),
+		token.DATE:   equalDate,
 	},
 	"!=": {
 		token.NUM:    unEqual[float64],
 		token.BOOL:   unEqual[bool],
 		token.STRING: unEqual[string],
+		token.NULL:   isNotNull,
+		token.FIELD:  fieldCompare("!="),
+		token.FUNC:   sqlfunc.Compare("!="),
+		token.DATE:   unEqualDate,
 	},
 	">=": {
 		token.NUM:    gte[float64],
 		token.STRING: gte[string],
+		token.FIELD:  fieldCompare(">="),
+		token.FUNC:   sqlfunc.Compare(">="),
+		token.DATE:   dateCompare(">=", false),
 	},
 	"<=": {
 		token.NUM:    lte[float64],
 		token.STRING: lte[string],
+		token.FIELD:  fieldCompare("<="),
+		token.FUNC:   sqlfunc.Compare("<="),
+		token.DATE:   dateCompare("<=", true),
 	},
 	">": {
 		token.NUM:    gt[float64],
-		token.STRING: lt[string],
+		token.STRING: gt[string],
+		token.FIELD:  fieldCompare(">"),
+		token.FUNC:   sqlfunc.Compare(">"),
+		token.DATE:   dateCompare(">", true),
 	},
 	"<": {
 		token.NUM:    lt[float64],
 		token.STRING: lt[string],
+		token.FIELD:  fieldCompare("<"),
+		token.FUNC:   sqlfunc.Compare("<"),
+		token.DATE:   dateCompare("<", false),
 	},
 	"contains": {
 		token.STRING: contains,
@@ -54,14 +78,45 @@ var DefaultFnMap = map[string]map[token.Token]ConditionFn{
 	"unEndsWith": {
 		token.STRING: unEndsWith,
 	},
+	"iequals": {
+		token.STRING: ilike("%s"),
+	},
+	"icontains": {
+		token.STRING: ilike("%%%s%%"),
+	},
+	"istartsWith": {
+		token.STRING: ilike("%s%%"),
+	},
+	"iendsWith": {
+		token.STRING: ilike("%%%s"),
+	},
+	"like": {
+		token.STRING: wildcard("ILIKE"),
+	},
+	"notLike": {
+		token.STRING: wildcard("NOT ILIKE"),
+	},
 	"reg": {
 		token.STRING: reg,
 	},
 	"in": {
 		token.STRING: in,
+		token.ARRAY:  inArray,
 	},
 	"notIn": {
 		token.STRING: notIn,
+		token.ARRAY:  notInArray,
+	},
+	"between": {
+		token.ARRAY: between,
+	},
+	"inCidr": {
+		token.STRING: cidr(false),
+		token.ARRAY:  cidr(false),
+	},
+	"notInCidr": {
+		token.STRING: cidr(true),
+		token.ARRAY:  cidr(true),
 	},
 	"containsBit": {
 		token.NUM:    containsBit,
@@ -71,10 +126,43 @@ var DefaultFnMap = map[string]map[token.Token]ConditionFn{
 		token.NUM:    unContainsBit,
 		token.STRING: unContainsBitStr,
 	},
+	"is": {
+		token.NULL: isNull,
+	},
+	"isNot": {
+		token.NULL: isNotNull,
+	},
+	"exists": {
+		token.NULL: isNotNull,
+	},
+	"notExists": {
+		token.NULL: isNull,
+	},
 	"&": {},
 	"|": {},
 }
 
+// fieldCompare 字段与字段比较，value 为映射后的字段名
+func fieldCompare(op string) ConditionFn {
+	return func(key string, value any) (sql string, params []any) {
+		field, ok := value.(string)
+		if !ok {
+			return "", nil
+		}
+		return fmt.Sprintf("%s %s %s", key, op, field), nil
+	}
+}
+
+// isNull IS NULL
+func isNull(key string, value any) (sql string, params []any) {
+	return fmt.Sprintf("%s IS NULL", key), nil
+}
+
+// isNotNull IS NOT NULL
+func isNotNull(key string, value any) (sql string, params []any) {
+	return fmt.Sprintf("%s IS NOT NULL", key), nil
+}
+
 func equal[T comparable](key string, value any) (sql string, params []any) {
 	val, ok := value.(T)
 	if !ok {
@@ -171,6 +259,60 @@ func unEndsWith(key string, value any) (sql string, params []any) {
 	return fmt.Sprintf("%s NOT ILIKE CONCAT('%%', ?)", key), []any{val}
 }
 
+// ilike 忽略大小写的 LIKE 匹配，format 中的 %s 为转义后的值
+func ilike(format string) ConditionFn {
+	return func(key string, value any) (sql string, params []any) {
+		val, ok := value.(string)
+		if !ok {
+			return "", nil
+		}
+		return fmt.Sprintf("%s ILIKE ?", key), []any{fmt.Sprintf(format, expression.EscapeLike(val))}
+	}
+}
+
+// wildcard 通配符匹配，忽略大小写，通配符转换为 LIKE 的匹配模式
+func wildcard(op string) ConditionFn {
+	return func(key string, value any) (sql string, params []any) {
+		val, ok := value.(string)
+		if !ok {
+			return "", nil
+		}
+		return fmt.Sprintf("%s %s ?", key, op), []any{expression.WildcardToLike(val)}
+	}
+}
+
+// cidr 在任意一个 CIDR 范围内，使用 isIPAddressInRange；not 为 true 时取反
+//
+//	空数组时 inCidr 恒为 false，notInCidr 恒为 true
+func cidr(not bool) ConditionFn {
+	return func(key string, value any) (sql string, params []any) {
+		prefixes, err := expression.ParseCidrs(value)
+		if err != nil {
+			// LexParse、builder 已校验，无法解析时不匹配任何数据，不能退化为查询全部
+			return "1 = 0", nil
+		}
+		if len(prefixes) == 0 {
+			if not {
+				return "1 = 1", nil
+			}
+			return "1 = 0", nil
+		}
+		sqls := make([]string, 0, len(prefixes))
+		for _, prefix := range prefixes {
+			sqls = append(sqls, fmt.Sprintf("isIPAddressInRange(%s, ?)", key))
+			params = append(params, prefix.String())
+		}
+		sql = strings.Join(sqls, " OR ")
+		if len(sqls) > 1 {
+			sql = fmt.Sprintf("( %s )", sql)
+		}
+		if not {
+			sql = fmt.Sprintf("NOT %s", sql)
+		}
+		return sql, params
+	}
+}
+
 func reg(key string, value any) (sql string, params []any) {
 	val, ok := value.(string)
 	if !ok {
@@ -195,6 +337,81 @@ func notIn(key string, value any) (sql string, params []any) {
 	return fmt.Sprintf("has(splitByChar(',', ?), toString(`%s`)) != 1", key), []any{val}
 }
 
+// inArray IN (?, ?)，空数组恒为 false
+func inArray(key string, value any) (sql string, params []any) {
+	val, ok := value.([]any)
+	if !ok {
+		return "", nil
+	}
+	if len(val) == 0 {
+		return "1 = 0", nil
+	}
+	params, ok = sqlfunc.DateParams(val, time.Now())
+	if !ok {
+		return "", nil
+	}
+	return fmt.Sprintf("%s IN (%s)", key, placeholders(len(val))), params
+}
+
+// notInArray NOT IN (?, ?)，空数组恒为 true
+func notInArray(key string, value any) (sql string, params []any) {
+	val, ok := value.([]any)
+	if !ok {
+		return "", nil
+	}
+	if len(val) == 0 {
+		return "1 = 1", nil
+	}
+	params, ok = sqlfunc.DateParams(val, time.Now())
+	if !ok {
+		return "", nil
+	}
+	return fmt.Sprintf("%s NOT IN (%s)", key, placeholders(len(val))), params
+}
+
+// dateCompare 日期比较，日期的解析见 sqlfunc.Date
+func dateCompare(op string, roundUp bool) ConditionFn {
+	return func(key string, value any) (sql string, params []any) {
+		t, ok := sqlfunc.Date(value, roundUp)
+		if !ok {
+			return "", nil
+		}
+		return fmt.Sprintf("%s %s ?", key, op), []any{t}
+	}
+}
+
+// equalDate 在取整的周期内，如：ts == now/d 为今天
+func equalDate(key string, value any) (sql string, params []any) {
+	params, ok := sqlfunc.DateRange(value)
+	if !ok {
+		return "", nil
+	}
+	return fmt.Sprintf("%s BETWEEN ? AND ?", key), params
+}
+
+// unEqualDate 不在取整的周期内
+func unEqualDate(key string, value any) (sql string, params []any) {
+	params, ok := sqlfunc.DateRange(value)
+	if !ok {
+		return "", nil
+	}
+	return fmt.Sprintf("%s NOT BETWEEN ? AND ?", key), params
+}
+
+// between BETWEEN ? AND ?，包含两端，日期的结束值向上取整
+func between(key string, value any) (sql string, params []any) {
+	params, ok := sqlfunc.Between(value)
+	if !ok {
+		return "", nil
+	}
+	return fmt.Sprintf("%s BETWEEN ? AND ?", key), params
+}
+
+// placeholders 生成 n 个占位符：?, ?, ?
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
 // containsBit 位运算不进行类型判断，直接转成 int64
 func containsBit(key string, value any) (sql string, params []any) {
 	intVal := number.ParseInt[int64](value)
@@ -0,0 +1,32 @@
+package clickhouse_expr
+
+import (
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/sqlfunc"
+)
+
+// Arg 函数参数
+type Arg = sqlfunc.Arg
+
+// Func 表达式中的函数，根据参数生成 sql 片段及参数，不支持时返回 ""
+type Func = sqlfunc.Func
+
+// DefaultFuncMap 默认函数
+var DefaultFuncMap = map[string]Func{
+	"lower": sqlfunc.Call("lower", 1),
+	"upper": sqlfunc.Call("upper", 1),
+	"len":   sqlfunc.Call("length", 1),
+	"now":   sqlfunc.Now("now()", nowOffset),
+}
+
+// nowOffset now('-1h') => addSeconds(now(), ?)
+func nowOffset(seconds int64) (sql string, params []any) {
+	return "addSeconds(now(), ?)", []any{seconds}
+}
+
+// call 把函数调用转换为 sql 片段
+func (e *Executor) call(call *expression.AstNode, prefix, suffix string) (arg Arg, keys []string, ok bool) {
+	return sqlfunc.Convert(e.FuncMap, call, func(key string) string {
+		return e.buildKey(key, prefix, suffix)
+	})
+}
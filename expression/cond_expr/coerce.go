@@ -0,0 +1,240 @@
+package cond_expr
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jummyliu/pkg/datetime"
+	"github.com/jummyliu/pkg/db/types"
+	"github.com/jummyliu/pkg/expression/token"
+	"github.com/jummyliu/pkg/number"
+)
+
+// Coercion 比较时的类型转换策略
+type Coercion int
+
+const (
+	// CoercionStrict 只转换类型明确的值
+	// 	整数、浮点数、json.Number 按数字比较，time.Time 把表达式中的字符串解析为日期后比较
+	CoercionStrict Coercion = iota
+	// CoercionLenient 在 CoercionStrict 的基础上，转换字符串、数字、bool
+	// 	"443" 可以与数字比较，"true" 可以与 bool 比较，数字、bool 可以使用字符串条件
+	CoercionLenient
+)
+
+// dateLayouts 把表达式中的字符串解析为日期时，依次尝试的格式
+var dateLayouts = []string{
+	datetime.DatetimeLayout,
+	"2006-01-02",
+	time.RFC3339Nano,
+}
+
+// toFloat 把 v 转换为 float64
+func toFloat(v any, c Coercion) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32:
+		return number.ParseFloat[float64](val), true
+	case json.Number:
+		f, err := val.Float64()
+		return f, err == nil
+	case string:
+		if c != CoercionLenient {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toBool 把 v 转换为 bool
+func toBool(v any, c Coercion) (bool, bool) {
+	switch val := v.(type) {
+	case bool:
+		return val, true
+	case string:
+		if c != CoercionLenient {
+			return false, false
+		}
+		b, err := strconv.ParseBool(strings.TrimSpace(val))
+		return b, err == nil
+	}
+	return false, false
+}
+
+// toString 把 v 转换为字符串，用于字符串条件
+func toString(v any, c Coercion) (string, bool) {
+	if val, ok := v.(string); ok {
+		return val, true
+	}
+	if c != CoercionLenient {
+		return "", false
+	}
+	if val, ok := v.(bool); ok {
+		return strconv.FormatBool(val), true
+	}
+	if val, ok := v.(json.Number); ok {
+		return val.String(), true
+	}
+	if f, ok := toFloat(v, c); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// toAddr 把 v 转换为 IP 地址，IPv4 映射的 IPv6 地址转换为 IPv4
+func toAddr(v any) (netip.Addr, bool) {
+	var addr netip.Addr
+	switch val := v.(type) {
+	case string:
+		addr, _ = netip.ParseAddr(strings.TrimSpace(val))
+	case netip.Addr:
+		addr = val
+	case net.IP:
+		addr, _ = netip.AddrFromSlice(val)
+	}
+	if !addr.IsValid() {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// parseTime 按 dateLayouts 把表达式中的字符串解析为日期
+func parseTime(value string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t := datetime.ParseDateWithLayout(value, layout); !t.IsZero() {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// compareNum 数字比较，value 为表达式中的数字
+func compareNum(v any, value any, c Coercion) (int, bool) {
+	num, ok := value.(float64)
+	if !ok {
+		return 0, false
+	}
+	val, ok := toFloat(v, c)
+	if !ok || math.IsNaN(val) {
+		return 0, false
+	}
+	return compareFloat(val, num), true
+}
+
+// compareStr 字符串比较，value 为表达式中的字符串
+//
+//	v 为 time.Time、types.Time 时，value 解析为日期后比较
+//	CoercionLenient 时，v 为数字并且 value 可以解析为数字，按数字比较
+func compareStr(v any, value any, c Coercion) (int, bool) {
+	str, ok := value.(string)
+	if !ok {
+		return 0, false
+	}
+	if val, ok := v.(string); ok {
+		return strings.Compare(val, str), true
+	}
+	if val, ok := timeOf(v); ok {
+		t, ok := parseTime(str)
+		if !ok {
+			return 0, false
+		}
+		return val.Compare(t), true
+	}
+	if c != CoercionLenient {
+		return 0, false
+	}
+	val, ok := toFloat(v, c)
+	if !ok {
+		return 0, false
+	}
+	num, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || math.IsNaN(val) || math.IsNaN(num) {
+		return 0, false
+	}
+	return compareFloat(val, num), true
+}
+
+// compareDate 日期比较，value 为表达式中的日期，日期向下取整
+//
+//	v 为 time.Time、types.Time，或者可以解析为日期的字符串
+func compareDate(v any, value any, c Coercion) (int, bool) {
+	return compareDateRound(v, value, false)
+}
+
+// compareDateUp 日期比较，日期向上取整，用于 > 和 <=
+func compareDateUp(v any, value any, c Coercion) (int, bool) {
+	return compareDateRound(v, value, true)
+}
+
+func compareDateRound(v any, value any, roundUp bool) (int, bool) {
+	date, ok := value.(token.Date)
+	if !ok {
+		return 0, false
+	}
+	val, ok := toTime(v)
+	if !ok {
+		return 0, false
+	}
+	t, err := date.Time(time.Now(), roundUp)
+	if err != nil {
+		return 0, false
+	}
+	return val.Compare(t), true
+}
+
+// timeOf 获取 time.Time、types.Time 的值
+func timeOf(v any) (time.Time, bool) {
+	switch val := v.(type) {
+	case time.Time:
+		return val, true
+	case types.Time:
+		return time.Time(val), true
+	}
+	return time.Time{}, false
+}
+
+// toTime 把 v 转换为日期，字符串按 dateLayouts 解析
+func toTime(v any) (time.Time, bool) {
+	if t, ok := timeOf(v); ok {
+		return t, true
+	}
+	if str, ok := v.(string); ok {
+		return parseTime(str)
+	}
+	return time.Time{}, false
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// normalize 把字段引用、函数调用得到的值转换为表达式中的值类型
+//
+//	整数、json.Number 转换为 float64，time.Time、types.Time 转换为 RFC3339Nano 字符串
+func normalize(v any) any {
+	switch v.(type) {
+	case float64, bool, string, nil, []any:
+		return v
+	}
+	if t, ok := timeOf(v); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	if f, ok := toFloat(v, CoercionStrict); ok {
+		return f
+	}
+	return v
+}
@@ -0,0 +1,130 @@
+package cond_expr
+
+import (
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+// Explanation 表达式的执行过程，结构与语法树一致，可以序列化为 JSON
+type Explanation struct {
+	Type    token.Token `json:"type"`              // operator | not | condition | quant
+	Op      string      `json:"op,omitempty"`      // 逻辑关系或者条件，如：&&、==、any
+	Expr    string      `json:"expr"`              // 节点对应的表达式
+	Result  bool        `json:"result"`            // 执行结果
+	Skipped bool        `json:"skipped,omitempty"` // 被短路，没有执行
+
+	// 条件
+	Key     string `json:"key,omitempty"`     // 字段名，左侧为函数调用时为空；数组元素条件为数组字段
+	Value   any    `json:"value,omitempty"`   // 查找到的值，左侧为函数调用时为函数的返回值
+	Missing bool   `json:"missing,omitempty"` // 字段不存在
+	Literal any    `json:"literal,omitempty"` // 比较的值，字段引用、函数调用时为执行时的值
+	Error   string `json:"error,omitempty"`   // 函数调用失败
+
+	// Children 子节点；数组元素条件为每个执行过的元素，没有执行过元素时为被跳过的子表达式
+	Children []*Explanation `json:"children,omitempty"`
+}
+
+// Explain 使用 StdExecutor 执行表达式，返回执行过程
+func Explain(m map[string]any, expr string) (*Explanation, error) {
+	return StdExecutor.Explain(m, expr, "", "")
+}
+
+// Explain 执行表达式，返回执行过程
+func (e *Executor) Explain(m map[string]any, expr string, prefix, suffix string) (*Explanation, error) {
+	p, err := e.Compile(expr, prefix, suffix)
+	if err != nil {
+		return nil, err
+	}
+	return p.Explain(m), nil
+}
+
+// Explain 执行表达式，返回执行过程；结果与 Eval 一致
+func (p *Program) Explain(m map[string]any) *Explanation {
+	if p.root == nil {
+		return &Explanation{Result: true}
+	}
+	return p.root.explain(m, false)
+}
+
+// explain 执行节点，skip 为 true 时只生成结构，不执行
+func (n *program) explain(m map[string]any, skip bool) *Explanation {
+	if n == nil {
+		return &Explanation{Result: true, Skipped: skip}
+	}
+	result := &Explanation{
+		Type:    n.typ,
+		Op:      n.op,
+		Expr:    expression.Format(n.ast),
+		Skipped: skip,
+	}
+	switch n.typ {
+	case token.OPERATOR:
+		left := n.left.explain(m, skip)
+		// && 左侧为 false、|| 左侧为 true 时短路
+		short := !skip && left.Result != (n.op == "&&")
+		right := n.right.explain(m, skip || short)
+		result.Children = []*Explanation{left, right}
+		if !skip {
+			result.Result = left.Result
+			if !short {
+				result.Result = right.Result
+			}
+		}
+		return result
+	case token.NOT:
+		sub := n.left.explain(m, skip)
+		result.Op = "!"
+		result.Children = []*Explanation{sub}
+		result.Result = !skip && !sub.Result
+		return result
+	case token.QUANT:
+		return n.explainQuant(m, skip, result)
+	}
+	result.Key = n.key
+	if skip {
+		result.Literal = n.value
+		return result
+	}
+	res, left, right, err := n.term(m)
+	result.Result = res
+	if left == Missing {
+		result.Missing = true
+	} else {
+		result.Value = left
+	}
+	if n.compareFn == nil && n.conditionFn != nil && n.path != nil {
+		// 条件函数自行查找字段，这里只用于展示
+		val, ok := n.path.get(m)
+		result.Value, result.Missing = val, !ok
+	}
+	if right != Missing {
+		result.Literal = right
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// explainQuant 执行数组元素条件，与 evalQuant 一样在结果确定后停止
+func (n *program) explainQuant(m map[string]any, skip bool, result *Explanation) *Explanation {
+	result.Key = n.key
+	if !skip {
+		val, ok := n.path.get(m)
+		result.Missing = !ok
+		all := n.op == "all"
+		result.Result = all
+		for _, elem := range elements(val) {
+			sub := n.left.explain(elem, false)
+			result.Children = append(result.Children, sub)
+			if sub.Result != all {
+				result.Result = !all
+				break
+			}
+		}
+	}
+	if len(result.Children) == 0 {
+		result.Children = []*Explanation{n.left.explain(nil, true)}
+	}
+	return result
+}
@@ -0,0 +1,62 @@
+package cond_expr
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	testCases := []struct {
+		Expr string
+		JSON string
+	}{
+		{
+			Expr: "port == 80 && user == 'admin' || !(host.name endsWith '.com')",
+			JSON: `{"type":"operator","op":"||","expr":"port == 80 && user == 'admin' || !(host.name endsWith '.com')","result":false,"children":[` +
+				`{"type":"operator","op":"&&","expr":"port == 80 && user == 'admin'","result":false,"children":[` +
+				`{"type":"condition","op":"==","expr":"port == 80","result":false,"key":"port","value":443,"literal":80},` +
+				`{"type":"condition","op":"==","expr":"user == 'admin'","result":false,"skipped":true,"key":"user","literal":"admin"}]},` +
+				`{"type":"not","op":"!","expr":"!(host.name endsWith '.com')","result":false,"children":[` +
+				`{"type":"condition","op":"endsWith","expr":"host.name endsWith '.com'","result":true,"key":"host.name","value":"web-01.example.com","literal":".com"}]}]}`,
+		},
+		{
+			Expr: "missing == null && bytes_out > $bytes_in && len(tags) >= 4",
+			JSON: `{"type":"operator","op":"&&","expr":"missing == null && bytes_out > $bytes_in && len(tags) >= 4","result":true,"children":[` +
+				`{"type":"operator","op":"&&","expr":"missing == null && bytes_out > $bytes_in","result":true,"children":[` +
+				`{"type":"condition","op":"==","expr":"missing == null","result":true,"key":"missing","missing":true},` +
+				`{"type":"condition","op":">","expr":"bytes_out > $bytes_in","result":true,"key":"bytes_out","value":200,"literal":100}]},` +
+				`{"type":"condition","op":">=","expr":"len(tags) >= 4","result":true,"value":4,"literal":4}]}`,
+		},
+		{
+			Expr: "ts < now('1x')",
+			JSON: `{"type":"condition","op":"<","expr":"ts < now('1x')","result":false,"key":"ts","error":"time: unknown unit \"x\" in duration \"1x\""}`,
+		},
+		{
+			Expr: "any(processes, pid > 10) || all(empty, pid > 10)",
+			JSON: `{"type":"operator","op":"||","expr":"any(processes, pid > 10) || all(empty, pid > 10)","result":true,"children":[` +
+				`{"type":"quant","op":"any","expr":"any(processes, pid > 10)","result":true,"key":"processes","children":[` +
+				`{"type":"condition","op":">","expr":"pid > 10","result":false,"key":"pid","value":1,"literal":10},` +
+				`{"type":"condition","op":">","expr":"pid > 10","result":true,"key":"pid","value":20,"literal":10}]},` +
+				`{"type":"quant","op":"all","expr":"all(empty, pid > 10)","result":false,"skipped":true,"key":"empty","children":[` +
+				`{"type":"condition","op":">","expr":"pid > 10","result":false,"skipped":true,"key":"pid","literal":10}]}]}`,
+		},
+	}
+	for _, testCase := range testCases {
+		p, err := Compile(testCase.Expr)
+		if err != nil {
+			t.Fatalf("testCase %s compile failure: %s", testCase.Expr, err)
+		}
+		explanation := p.Explain(testMap)
+		if explanation.Result != p.Eval(testMap) {
+			t.Fatalf("testCase %s explain result %v is different from eval", testCase.Expr, explanation.Result)
+		}
+		var b strings.Builder
+		encoder := json.NewEncoder(&b)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(explanation)
+		if data := strings.TrimSpace(b.String()); data != testCase.JSON {
+			t.Fatalf("testCase %s need\n%s\nbut got\n%s", testCase.Expr, testCase.JSON, data)
+		}
+	}
+}
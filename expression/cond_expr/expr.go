@@ -3,6 +3,7 @@ package cond_expr
 import (
 	"fmt"
 
+	"github.com/elastic/elastic-agent-libs/mapstr"
 	"github.com/jummyliu/pkg/expression"
 	"github.com/jummyliu/pkg/expression/token"
 )
@@ -10,26 +11,57 @@ import (
 type Executor struct {
 	FnMap map[string]map[token.Token]ConditionFn
 
+	// CompareFnMap 比较函数映射，Compile 时优先使用
+	// 	为空或者不存在对应的比较函数时，使用 FnMap 中的条件函数
+	CompareFnMap map[string]map[token.Token]CompareFn
+
 	// KeyMap 字段映射
 	// 	存在映射 => key 转换为映射值
 	KeyMap map[string]string
 
+	// FuncMap 表达式中可以调用的函数
+	// 	函数在条件左侧时，只能使用 CompareFnMap 中的比较函数
+	FuncMap map[string]Func
+
+	// Coercion 类型转换策略，用于预编译的比较函数
+	// 	需要与 FnMap、CompareFnMap 使用的策略一致，使用 NewWithCoercion 创建
+	Coercion Coercion
+
 	NeedKeys bool
 }
 
 var StdExecutor = New(nil, nil)
 
 func New(fnMap map[string]map[token.Token]ConditionFn, keyMap map[string]string) *Executor {
+	var compareFnMap map[string]map[token.Token]CompareFn
 	if fnMap == nil {
 		fnMap = DefaultFnMap
+		compareFnMap = DefaultCompareFnMap
 	}
 	return &Executor{
-		FnMap:  fnMap,
-		KeyMap: keyMap,
+		FnMap:        fnMap,
+		CompareFnMap: compareFnMap,
+		KeyMap:       keyMap,
+		FuncMap:      DefaultFuncMap,
 		// NeedKeys: true,
 	}
 }
 
+// NewWithCoercion 使用指定类型转换策略的默认条件函数创建执行器
+func NewWithCoercion(c Coercion, keyMap map[string]string) *Executor {
+	compareFnMap := DefaultCompareFnMap
+	if c == CoercionLenient {
+		compareFnMap = LenientCompareFnMap
+	}
+	return &Executor{
+		FnMap:        ToFnMap(compareFnMap),
+		CompareFnMap: compareFnMap,
+		KeyMap:       keyMap,
+		FuncMap:      DefaultFuncMap,
+		Coercion:     c,
+	}
+}
+
 // DoExpr 执行表达式
 func (e *Executor) DoExpr(m map[string]any, expr string, prefix, suffix string) (result bool, keys []string, err error) {
 	lexTokens, err := expression.LexParse(expression.TokensRead(expr))
@@ -69,13 +101,89 @@ func (e *Executor) DoAst(m map[string]any, ast *expression.AstNode, prefix, suff
 			keys = append(keys, rightKeys...)
 			return OperatorOr(leftResult, rightResult), keys
 		}
+	case token.NOT:
+		// 取反
+		subResult, subKeys := e.DoAst(m, ast.Left, prefix, suffix)
+		return OperatorNot(subResult), subKeys
+	case token.ILLEGAL:
+		// 不合法的条件
+		return false, nil
 	case token.CONDITION:
 		// 单个表达式转换
 		return e.DoTerm(m, ast, prefix, suffix)
+	case token.QUANT:
+		// 数组元素条件
+		return e.DoQuant(m, ast, prefix, suffix)
 	}
 	return false, nil
 }
 
+// DoQuant 执行数组元素条件 any(path, expr)、all(path, expr)
+//
+//	path 拼接前缀，子表达式中的字段相对于数组元素，只拼接后缀，不进行字段映射
+//	any：任意一个元素满足，空数组、字段不存在时为 false
+//	all：所有元素都满足，空数组、字段不存在时为 true
+func (e *Executor) DoQuant(m map[string]any, quant *expression.AstNode, prefix, suffix string) (result bool, keys []string) {
+	if quant.Left == nil || quant.Right == nil {
+		return false, nil
+	}
+	path, ok := quant.Left.Value.(string)
+	if !ok {
+		return false, nil
+	}
+	name, _ := quant.Value.(string)
+	if !token.IsQuant(name) {
+		return false, nil
+	}
+	path = e.buildKey(path, prefix, "")
+	if e.NeedKeys {
+		keys = []string{path}
+	}
+	v, _ := mapstr.M(m).GetValue(path)
+	sub := e.elemExecutor()
+	all := name == "all"
+	for _, elem := range elements(v) {
+		if res, _ := sub.DoAst(elem, quant.Right, "", suffix); res != all {
+			return !all, keys
+		}
+	}
+	return all, keys
+}
+
+// elemExecutor 执行数组元素子表达式的执行器，不进行字段映射
+func (e *Executor) elemExecutor() *Executor {
+	sub := *e
+	sub.KeyMap = nil
+	sub.NeedKeys = false
+	return &sub
+}
+
+// elements 把数组字段的值转换为元素列表，不是对象的元素作为空对象
+func elements(v any) []map[string]any {
+	switch arr := v.(type) {
+	case []map[string]any:
+		return arr
+	case []mapstr.M:
+		elems := make([]map[string]any, len(arr))
+		for i, item := range arr {
+			elems[i] = item
+		}
+		return elems
+	case []any:
+		elems := make([]map[string]any, len(arr))
+		for i, item := range arr {
+			switch elem := item.(type) {
+			case map[string]any:
+				elems[i] = elem
+			case mapstr.M:
+				elems[i] = elem
+			}
+		}
+		return elems
+	}
+	return nil
+}
+
 // DoTerm 执行 term
 func (e *Executor) DoTerm(m map[string]any, term *expression.AstNode, prefix, suffix string) (result bool, keys []string) {
 	// 判断是否有 left 和 right
@@ -94,11 +202,96 @@ func (e *Executor) DoTerm(m map[string]any, term *expression.AstNode, prefix, su
 	if !ok {
 		return false, nil
 	}
-	fn, ok := fns[term.Right.Type]
-	if !ok {
-		return false, nil
+	var key string
+	var left *operand
+	if term.Left.Type == token.FUNC {
+		call, callKeys, err := e.compileCall(term.Left, prefix, suffix)
+		if err != nil {
+			return false, nil
+		}
+		left = call
+		keys = callKeys
+	} else {
+		key = e.buildKey(term.Left.Value.(string), prefix, suffix)
+		keys = []string{key}
 	}
-	key := term.Left.Value.(string)
+	value, valueType := term.Right.Value, term.Right.Type
+	switch valueType {
+	case token.FIELD:
+		// 字段与字段比较，从 m 中取出字段的值，按值的类型进行比较
+		field, ok := value.(string)
+		if !ok {
+			return false, nil
+		}
+		field = e.buildKey(field, prefix, suffix)
+		keys = append(keys, field)
+		v, err := mapstr.M(m).GetValue(field)
+		if err != nil {
+			return false, nil
+		}
+		if value, valueType, ok = valueToken(v); !ok {
+			return false, nil
+		}
+	case token.FUNC:
+		// 函数调用，计算出值，按值的类型进行比较
+		call, callKeys, err := e.compileCall(term.Right, prefix, suffix)
+		if err != nil {
+			return false, nil
+		}
+		keys = append(keys, callKeys...)
+		v, err := call.get(m)
+		if err != nil {
+			return false, nil
+		}
+		if value, valueType, ok = valueToken(v); !ok {
+			return false, nil
+		}
+	}
+	if left != nil {
+		fn, ok := e.CompareFnMap[term.Value.(string)][valueType]
+		if !ok {
+			return false, nil
+		}
+		val, err := left.get(m)
+		if err != nil {
+			return false, nil
+		}
+		result = fn(val, value)
+	} else {
+		fn, ok := fns[valueType]
+		if !ok {
+			return false, nil
+		}
+		result = fn(m, key, value)
+	}
+	if e.NeedKeys {
+		return result, keys
+	}
+	return result, nil
+}
+
+// valueToken 根据值的类型，获取对应的 token
+//
+//	值先经过 normalize 转换，整数、json.Number 为 token.NUM，time.Time 为 token.STRING
+func valueToken(v any) (val any, tok token.Token, ok bool) {
+	v = normalize(v)
+	switch v.(type) {
+	case nil:
+		return v, token.NULL, true
+	case float64:
+		return v, token.NUM, true
+	case bool:
+		return v, token.BOOL, true
+	case string:
+		return v, token.STRING, true
+	case []any:
+		return v, token.ARRAY, true
+	}
+	return v, "", false
+}
+
+// buildKey 字段映射，并拼接前缀、后缀
+func (e *Executor) buildKey(key string, prefix, suffix string) string {
 	if _, ok := e.KeyMap[key]; ok {
 		key = e.KeyMap[key]
 	}
@@ -108,10 +301,7 @@ func (e *Executor) DoTerm(m map[string]any, term *expression.AstNode, prefix, su
 	if len(suffix) > 0 {
 		key = fmt.Sprintf("%s.%s", key, suffix)
 	}
-	if e.NeedKeys {
-		return fn(m, key, term.Right.Value), []string{key}
-	}
-	return fn(m, key, term.Right.Value), nil
+	return key
 }
 
 func OperatorAnd(left, right bool) bool {
@@ -121,3 +311,22 @@ func OperatorAnd(left, right bool) bool {
 func OperatorOr(left, right bool) bool {
 	return left || right
 }
+
+func OperatorNot(val bool) bool {
+	return !val
+}
+
+// Support 支持的条件，用于 expression.Validate
+//
+//	字段引用、函数调用的值在执行时才能确定类型，只要存在该条件就支持
+func (e *Executor) Support() expression.Support {
+	support := expression.NewSupport(e.FnMap)
+	for _, types := range support {
+		if len(types) == 0 {
+			continue
+		}
+		types[token.FIELD] = true
+		types[token.FUNC] = true
+	}
+	return support
+}
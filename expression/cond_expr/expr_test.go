@@ -0,0 +1,212 @@
+package cond_expr
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testMap = map[string]any{
+	"port":    float64(443),
+	"enabled": true,
+	"user":    "Admin",
+	"host": map[string]any{
+		"name": "web-01.example.com",
+		"ip":   "10.0.0.1",
+	},
+	"flags":      float64(6),
+	"deleted":    nil,
+	"bytes_in":   float64(100),
+	"bytes_out":  float64(200),
+	"src_ip":     "10.0.0.1",
+	"event.type": "login",
+	"tags":       []any{"a", "b", "c", "d"},
+	"ts":         "2000-01-01 00:00:00",
+	"processes": []any{
+		map[string]any{"name": "bash", "pid": 1, "args": []any{map[string]any{"v": "-l"}}},
+		map[string]any{"name": "sh", "pid": float64(20), "args": []any{map[string]any{"v": "-c"}, map[string]any{"v": "id"}}},
+	},
+	"empty": []any{},
+}
+
+func TestCompile(t *testing.T) {
+	testCases := []struct {
+		Expr   string
+		Result bool
+	}{
+		{Expr: "port == 443", Result: true},
+		{Expr: "port != 443", Result: false},
+		{Expr: "port >= 400 && port < 500", Result: true},
+		{Expr: "enabled == true && user == 'admin'", Result: true},
+		{Expr: "host.name endsWith '.example.com'", Result: true},
+		{Expr: "host.name reg '^web-\\d+'", Result: true},
+		{Expr: "host.ip in '10.0.0.1,10.0.0.2'", Result: true},
+		{Expr: "host.missing == 1 || flags containsBit 4", Result: true},
+		{Expr: "event.type == 'login'", Result: true},
+		{Expr: "!(user contains 'adm') || port == 80", Result: false},
+		{Expr: "port in [80, 443]", Result: true},
+		{Expr: "port notIn [80, 443]", Result: false},
+		{Expr: "user in ['admin', 'root']", Result: false},
+		{Expr: "host.ip in ['10.0.0.1', 1, true]", Result: true},
+		{Expr: "host notIn ['a']", Result: true},
+		{Expr: "missing notIn ['a']", Result: false},
+		{Expr: "missing == 'a'", Result: false},
+		{Expr: "deleted exists && deleted == null", Result: true},
+		{Expr: "deleted isNot null", Result: false},
+		{Expr: "missing == null && missing notExists", Result: true},
+		{Expr: "missing exists || missing is null && missing != null", Result: false},
+		{Expr: "host.name exists && host.name != null", Result: true},
+		{Expr: "host.name.first notExists", Result: true},
+		{Expr: "bytes_out > $bytes_in && host.ip == $src_ip", Result: true},
+		{Expr: "bytes_out <= $bytes_in || user == $host.name", Result: false},
+		{Expr: "bytes_out != $missing", Result: false},
+		{Expr: "deleted == $deleted", Result: true},
+		{Expr: "user.name == 'a'", Result: false},
+		{Expr: "lower(user) == 'admin' && upper(host.name) startsWith 'WEB'", Result: true},
+		{Expr: "len(tags) > 3 && len(user) == 5", Result: true},
+		{Expr: "len(lower(user)) == len(user)", Result: true},
+		{Expr: "ts < now('-1h') && ts < now() && ts < now('-1w')", Result: true},
+		{Expr: "ts < now-1y/y && ts between [1999-12-31, 2000-01-01T00:00] && ts == 2000-01-01", Result: true},
+		{Expr: "ts > now-1d || port between [443, 443] && user between ['a', 'b']", Result: false},
+		{Expr: "len(missing) >= 0 || len(port) >= 0", Result: false},
+		{Expr: "any(processes, name == 'sh' && pid > 10) && all(processes, pid >= 1)", Result: true},
+		{Expr: "any(processes, name == 'sh' && pid < 10) || all(processes, name startsWith 'b')", Result: false},
+		{Expr: "any(processes, any(args, v == '-c')) && !any(processes, all(args, v == 'id'))", Result: true},
+		{Expr: "any(empty, name exists) || any(missing, name notExists) || any(tags, x notExists)", Result: true},
+		{Expr: "all(empty, name exists) && all(missing, name exists) && !all(tags, x exists)", Result: true},
+		{Expr: "user iequals 'ADMIN' && host.name icontains 'EXAMPLE' && host.name istartsWith 'Web' && host.name iendsWith '.COM'", Result: true},
+		{Expr: "host.name like 'WEB-??.*.com' && host.name notLike '*.org' && user like 'adm*'", Result: true},
+		{Expr: "src_ip inCidr '10.0.0.0/8' && host.ip inCidr ['192.168.0.0/16', '10.0.0.1'] && src_ip notInCidr 'fd00::/8,10.0.1.0/24'", Result: true},
+		{Expr: "src_ip notInCidr ['10.0.0.0/31'] || user inCidr '0.0.0.0/0' || user notInCidr '0.0.0.0/0' || missing notInCidr []", Result: false},
+		{Expr: "host.name like 'web-?.*' || host.name like 'web' || user like 'a\\*' || port like '443'", Result: false},
+	}
+	for _, testCase := range testCases {
+		result, _, err := StdExecutor.DoExpr(testMap, testCase.Expr, "", "")
+		if err != nil {
+			t.Fatalf("testCase %s DoExpr failure: %s", testCase.Expr, err)
+		}
+		if result != testCase.Result {
+			t.Fatalf("testCase %s DoExpr need %v but got %v", testCase.Expr, testCase.Result, result)
+		}
+		program, err := Compile(testCase.Expr)
+		if err != nil {
+			t.Fatalf("testCase %s Compile failure: %s", testCase.Expr, err)
+		}
+		if result := program.Eval(testMap); result != testCase.Result {
+			t.Fatalf("testCase %s Eval need %v but got %v", testCase.Expr, testCase.Result, result)
+		}
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	testCases := []string{
+		"a == ",
+		"a reg '('",
+		"a contains 1",
+		"foo(a) == 1",
+		"len(a) contains 1",
+		"a inCidr '10.0.0.0/33'",
+	}
+	for _, testCase := range testCases {
+		if _, err := Compile(testCase); err == nil {
+			t.Fatalf("testCase %s need error but got nil", testCase)
+		}
+	}
+}
+
+func TestCoercion(t *testing.T) {
+	m := map[string]any{
+		"port":    int64(443),
+		"uport":   uint16(80),
+		"size":    json.Number("1024"),
+		"sport":   "443",
+		"enabled": "true",
+		"ts":      time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local),
+		"start":   time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local),
+	}
+	testCases := []struct {
+		Expr    string
+		Strict  bool
+		Lenient bool
+	}{
+		{Expr: "port == 443 && port >= 400 && port != 80", Strict: true, Lenient: true},
+		{Expr: "uport < 443 && size > 1000 && size == $size", Strict: true, Lenient: true},
+		{Expr: "port in [80, 443] && uport notIn [443]", Strict: true, Lenient: true},
+		{Expr: "port == $sport", Strict: false, Lenient: true},
+		{Expr: "sport == 443", Strict: false, Lenient: true},
+		{Expr: "sport in [80, 443]", Strict: false, Lenient: true},
+		{Expr: "port == '443' && port startsWith '44'", Strict: false, Lenient: true},
+		{Expr: "port in '80,443' && port reg '^4'", Strict: false, Lenient: true},
+		{Expr: "enabled == true", Strict: false, Lenient: true},
+		{Expr: "ts > '2024-01-01' && ts < '2024-06-01 12:00:01'", Strict: true, Lenient: true},
+		{Expr: "ts == '2024-06-01 12:00:00' && ts != '2024-06-01'", Strict: true, Lenient: true},
+		{Expr: "ts > $start && ts < now()", Strict: true, Lenient: true},
+		{Expr: "ts > 'yesterday' || ts <= 'yesterday'", Strict: false, Lenient: false},
+	}
+	lenient := NewWithCoercion(CoercionLenient, nil)
+	for _, testCase := range testCases {
+		for _, item := range []struct {
+			executor *Executor
+			result   bool
+		}{
+			{executor: StdExecutor, result: testCase.Strict},
+			{executor: lenient, result: testCase.Lenient},
+		} {
+			result, _, err := item.executor.DoExpr(m, testCase.Expr, "", "")
+			if err != nil {
+				t.Fatalf("testCase %s DoExpr failure: %s", testCase.Expr, err)
+			}
+			if result != item.result {
+				t.Fatalf("testCase %s coercion %d DoExpr need %v but got %v", testCase.Expr, item.executor.Coercion, item.result, result)
+			}
+			program, err := item.executor.Compile(testCase.Expr, "", "")
+			if err != nil {
+				t.Fatalf("testCase %s Compile failure: %s", testCase.Expr, err)
+			}
+			if result := program.Eval(m); result != item.result {
+				t.Fatalf("testCase %s coercion %d Eval need %v but got %v", testCase.Expr, item.executor.Coercion, item.result, result)
+			}
+		}
+	}
+}
+
+func TestProgramConcurrent(t *testing.T) {
+	program, err := Compile("host.name reg '^web-\\d+' && port == 443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if !program.Eval(testMap) {
+					t.Error("Eval need true but got false")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+const benchExpr = "host.name reg '^web-\\d+' && port >= 400 && (user contains 'adm' || enabled == false)"
+
+func BenchmarkDoExpr(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		StdExecutor.DoExpr(testMap, benchExpr, "", "")
+	}
+}
+
+func BenchmarkProgramEval(b *testing.B) {
+	program, err := Compile(benchExpr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		program.Eval(testMap)
+	}
+}
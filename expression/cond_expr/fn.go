@@ -1,272 +1,499 @@
 package cond_expr
 
 import (
+	"net/netip"
 	"regexp"
 	"strings"
 
 	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/jummyliu/pkg/expression"
 	"github.com/jummyliu/pkg/expression/token"
 	"github.com/jummyliu/pkg/number"
 	"github.com/jummyliu/pkg/utils"
 )
 
-// typeCheck 类型检查
-func typeCheck[T comparable](m map[string]any, key string, value any) (val T, ok bool) {
-	v, err := mapstr.M(m).GetValue(key)
-	if err != nil {
-		return val, false
+// ConditionFn 条件函数，从 m 中取出 key 对应的值，与 value 进行比较
+type ConditionFn func(m map[string]any, key string, value any) bool
+
+// CompareFn 比较函数，val 为 m 中 key 对应的值，value 为表达式中的值
+type CompareFn func(val any, value any) bool
+
+// missing 字段不存在
+type missing struct{}
+
+// Missing 字段不存在时，传给 token.NULL 比较函数的值
+var Missing any = missing{}
+
+// ToConditionFn 把比较函数转换为条件函数
+//
+//	key 不存在时，返回 false
+func ToConditionFn(fn CompareFn) ConditionFn {
+	return func(m map[string]any, key string, value any) bool {
+		val, err := mapstr.M(m).GetValue(key)
+		if err != nil {
+			return false
+		}
+		return fn(val, value)
 	}
-	vNew, ok := v.(T)
-	if !ok {
-		return val, false
+}
+
+// ToNullConditionFn 把 token.NULL 的比较函数转换为条件函数
+//
+//	key 不存在时，使用 Missing 作为值进行比较
+func ToNullConditionFn(fn CompareFn) ConditionFn {
+	return func(m map[string]any, key string, value any) bool {
+		val, err := mapstr.M(m).GetValue(key)
+		if err != nil {
+			val = Missing
+		}
+		return fn(val, value)
 	}
-	if _, ok := value.(T); !ok {
-		return val, false
+}
+
+// ToFnMap 把比较函数映射转换为条件函数映射
+func ToFnMap(compareFnMap map[string]map[token.Token]CompareFn) map[string]map[token.Token]ConditionFn {
+	fnMap := make(map[string]map[token.Token]ConditionFn, len(compareFnMap))
+	for op, fns := range compareFnMap {
+		fnMap[op] = make(map[token.Token]ConditionFn, len(fns))
+		for tok, fn := range fns {
+			if tok == token.NULL {
+				fnMap[op][tok] = ToNullConditionFn(fn)
+				continue
+			}
+			fnMap[op][tok] = ToConditionFn(fn)
+		}
 	}
-	return vNew, true
+	return fnMap
 }
 
-type ConditionFn func(m map[string]any, key string, value any) bool
+var DefaultFnMap = ToFnMap(DefaultCompareFnMap)
 
-var DefaultFnMap = map[string]map[token.Token]ConditionFn{
-	"==": {
-		token.NUM:    equal[float64],
-		token.BOOL:   equal[bool],
-		token.STRING: equalStr,
-	},
-	"!=": {
-		token.NUM:    unEqual[float64],
-		token.BOOL:   unEqual[bool],
-		token.STRING: unEqualStr,
-	},
-	">=": {
-		token.NUM:    gte[float64],
-		token.STRING: gte[string],
-	},
-	"<=": {
-		token.NUM:    lte[float64],
-		token.STRING: lte[string],
-	},
-	">": {
-		token.NUM:    gt[float64],
-		token.STRING: lt[string],
-	},
-	"<": {
-		token.NUM:    lt[float64],
-		token.STRING: lt[string],
-	},
-	"contains": {
-		token.STRING: contains,
-	},
-	"unContains": {
-		token.STRING: unContains,
-	},
-	"startsWith": {
-		token.STRING: startsWith,
-	},
-	"unStartsWith": {
-		token.STRING: unStartsWith,
-	},
-	"endsWith": {
-		token.STRING: endsWith,
-	},
-	"unEndsWith": {
-		token.STRING: unEndsWith,
-	},
-	"reg": {
-		token.STRING: reg,
-	},
-	"in": {
-		token.STRING: in,
-	},
-	"notIn": {
-		token.STRING: notIn,
-	},
-	"containsBit": {
-		token.NUM:    containsBit,
-		token.STRING: containsBit,
-	},
-	"unContainsBit": {
-		token.NUM:    unContainsBit,
-		token.STRING: unContainsBit,
-	},
-	"&": {},
-	"|": {},
-}
-
-// equal ==
-func equal[T comparable](m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[T](m, key, value)
-	if !ok {
-		return false
+var DefaultCompareFnMap = NewCompareFnMap(CoercionStrict)
+
+// LenientFnMap 使用 CoercionLenient 的条件函数映射
+var LenientFnMap = ToFnMap(LenientCompareFnMap)
+
+// LenientCompareFnMap 使用 CoercionLenient 的比较函数映射
+var LenientCompareFnMap = NewCompareFnMap(CoercionLenient)
+
+// NewCompareFnMap 生成使用指定类型转换策略的比较函数映射
+func NewCompareFnMap(c Coercion) map[string]map[token.Token]CompareFn {
+	return map[string]map[token.Token]CompareFn{
+		"==": {
+			token.NUM:    compare(c, compareNum, isEqual),
+			token.BOOL:   equalBool(c),
+			token.STRING: equalStr(c),
+			token.NULL:   isNull,
+			token.DATE:   equalDate(c),
+		},
+		"!=": {
+			token.NUM:    compare(c, compareNum, isUnEqual),
+			token.BOOL:   unEqualBool(c),
+			token.STRING: unEqualStr(c),
+			token.NULL:   isNotNull,
+			token.DATE:   unEqualDate(c),
+		},
+		">=": {
+			token.NUM:    compare(c, compareNum, isGte),
+			token.STRING: compare(c, compareStr, isGte),
+			token.DATE:   compare(c, compareDate, isGte),
+		},
+		"<=": {
+			token.NUM:    compare(c, compareNum, isLte),
+			token.STRING: compare(c, compareStr, isLte),
+			token.DATE:   compare(c, compareDateUp, isLte),
+		},
+		">": {
+			token.NUM:    compare(c, compareNum, isGt),
+			token.STRING: compare(c, compareStr, isGt),
+			token.DATE:   compare(c, compareDateUp, isGt),
+		},
+		"<": {
+			token.NUM:    compare(c, compareNum, isLt),
+			token.STRING: compare(c, compareStr, isLt),
+			token.DATE:   compare(c, compareDate, isLt),
+		},
+		"contains": {
+			token.STRING: matchStr(c, contains),
+		},
+		"unContains": {
+			token.STRING: matchStr(c, unContains),
+		},
+		"startsWith": {
+			token.STRING: matchStr(c, startsWith),
+		},
+		"unStartsWith": {
+			token.STRING: matchStr(c, unStartsWith),
+		},
+		"endsWith": {
+			token.STRING: matchStr(c, endsWith),
+		},
+		"unEndsWith": {
+			token.STRING: matchStr(c, unEndsWith),
+		},
+		"iequals": {
+			token.STRING: matchStr(c, strings.EqualFold),
+		},
+		"icontains": {
+			token.STRING: matchStr(c, contains),
+		},
+		"istartsWith": {
+			token.STRING: matchStr(c, startsWith),
+		},
+		"iendsWith": {
+			token.STRING: matchStr(c, endsWith),
+		},
+		"like": {
+			token.STRING: matchStr(c, like),
+		},
+		"notLike": {
+			token.STRING: matchStr(c, notLike),
+		},
+		"reg": {
+			token.STRING: matchStr(c, reg),
+		},
+		"in": {
+			token.STRING: matchStr(c, in),
+			token.ARRAY:  inArray(c),
+		},
+		"notIn": {
+			token.STRING: matchStr(c, notIn),
+			token.ARRAY:  notInArray(c),
+		},
+		"between": {
+			token.ARRAY: between(c),
+		},
+		"inCidr": {
+			token.STRING: inCidr,
+			token.ARRAY:  inCidr,
+		},
+		"notInCidr": {
+			token.STRING: notInCidr,
+			token.ARRAY:  notInCidr,
+		},
+		"containsBit": {
+			token.NUM:    containsBit,
+			token.STRING: containsBit,
+		},
+		"unContainsBit": {
+			token.NUM:    unContainsBit,
+			token.STRING: unContainsBit,
+		},
+		"is": {
+			token.NULL: isNull,
+		},
+		"isNot": {
+			token.NULL: isNotNull,
+		},
+		"exists": {
+			token.NULL: exists,
+		},
+		"notExists": {
+			token.NULL: notExists,
+		},
+		"&": {},
+		"|": {},
 	}
-	return val == value
 }
 
-// equalStr ==
-func equalStr(m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[string](m, key, value)
-	if !ok {
-		return false
+// isNull 字段不存在，或者值为 nil
+func isNull(v any, value any) bool {
+	return v == Missing || v == nil
+}
+
+// isNotNull 字段存在，并且值不为 nil
+func isNotNull(v any, value any) bool {
+	return !isNull(v, value)
+}
+
+// exists 字段存在，值可以为 nil
+func exists(v any, value any) bool {
+	return v != Missing
+}
+
+// notExists 字段不存在
+func notExists(v any, value any) bool {
+	return v == Missing
+}
+
+// compare 使用 cmp 转换并比较 v 和 value，再用 test 判断比较结果
+//
+//	无法比较时，返回 false
+func compare(c Coercion, cmp func(v any, value any, c Coercion) (int, bool), test func(int) bool) CompareFn {
+	return func(v any, value any) bool {
+		result, ok := cmp(v, value, c)
+		if !ok {
+			return false
+		}
+		return test(result)
 	}
-	return strings.EqualFold(val, value.(string))
 }
 
-// unEqual !=
-func unEqual[T comparable](m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[T](m, key, value)
-	if !ok {
-		return false
+func isEqual(result int) bool   { return result == 0 }
+func isUnEqual(result int) bool { return result != 0 }
+func isGte(result int) bool     { return result >= 0 }
+func isLte(result int) bool     { return result <= 0 }
+func isGt(result int) bool      { return result > 0 }
+func isLt(result int) bool      { return result < 0 }
+
+// equalBool ==
+func equalBool(c Coercion) CompareFn {
+	return func(v any, value any) bool {
+		val, ok := toBool(v, c)
+		if !ok {
+			return false
+		}
+		b, ok := value.(bool)
+		return ok && val == b
 	}
-	return val != value
 }
 
-// unEqualStr !=
-func unEqualStr(m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[string](m, key, value)
-	if !ok {
-		return false
+// unEqualBool !=
+func unEqualBool(c Coercion) CompareFn {
+	return func(v any, value any) bool {
+		val, ok := toBool(v, c)
+		if !ok {
+			return false
+		}
+		b, ok := value.(bool)
+		return ok && val != b
 	}
-	return !strings.EqualFold(val, value.(string))
 }
 
-// gte >=
-func gte[T int64 | float64 | string](m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[T](m, key, value)
-	if !ok {
-		return false
+// equalStr ==，字符串忽略大小写
+func equalStr(c Coercion) CompareFn {
+	return func(v any, value any) bool {
+		if val, ok := v.(string); ok {
+			str, ok := value.(string)
+			return ok && strings.EqualFold(val, str)
+		}
+		result, ok := compareStr(v, value, c)
+		return ok && result == 0
 	}
-	return val >= value.(T)
 }
 
-// lte <=
-func lte[T int64 | float64 | string](m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[T](m, key, value)
-	if !ok {
-		return false
+// unEqualStr !=，字符串忽略大小写
+func unEqualStr(c Coercion) CompareFn {
+	return func(v any, value any) bool {
+		if val, ok := v.(string); ok {
+			str, ok := value.(string)
+			return ok && !strings.EqualFold(val, str)
+		}
+		result, ok := compareStr(v, value, c)
+		return ok && result != 0
 	}
-	return val <= value.(T)
 }
 
-// gt >
-func gt[T int64 | float64 | string](m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[T](m, key, value)
-	if !ok {
-		return false
+// equalDate ==，日期取整时，在取整的周期内即为相等，如：ts == now/d 为今天
+func equalDate(c Coercion) CompareFn {
+	return func(v any, value any) bool {
+		from, ok := compareDate(v, value, c)
+		if !ok {
+			return false
+		}
+		to, ok := compareDateUp(v, value, c)
+		return ok && from >= 0 && to <= 0
 	}
-	return val > value.(T)
 }
 
-// lt <
-func lt[T int64 | float64 | string](m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[T](m, key, value)
-	if !ok {
-		return false
+// unEqualDate !=，不在取整的周期内
+func unEqualDate(c Coercion) CompareFn {
+	return func(v any, value any) bool {
+		from, ok := compareDate(v, value, c)
+		if !ok {
+			return false
+		}
+		to, ok := compareDateUp(v, value, c)
+		return ok && (from < 0 || to > 0)
 	}
-	return val < value.(T)
 }
 
-// contains 包含
-func contains(m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[string](m, key, value)
-	if !ok {
-		return false
+// between 在 [from, to] 范围内，包含两端，数组元素可以是数字、字符串、日期
+func between(c Coercion) CompareFn {
+	return func(v any, value any) bool {
+		arr, ok := value.([]any)
+		if !ok || len(arr) != 2 {
+			return false
+		}
+		from, ok := compareItem(v, arr[0], c, false)
+		if !ok || from < 0 {
+			return false
+		}
+		to, ok := compareItem(v, arr[1], c, true)
+		return ok && to <= 0
 	}
-	return strings.Contains(strings.ToLower(val), strings.ToLower(value.(string)))
 }
 
-// unContains 不包含
-func unContains(m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[string](m, key, value)
-	if !ok {
-		return false
+// compareItem 与数组元素比较，roundUp 为日期的取整方向
+func compareItem(v any, item any, c Coercion, roundUp bool) (int, bool) {
+	switch item.(type) {
+	case float64:
+		return compareNum(v, item, c)
+	case string:
+		return compareStr(v, item, c)
+	case token.Date:
+		if roundUp {
+			return compareDateUp(v, item, c)
+		}
+		return compareDate(v, item, c)
 	}
-	return !strings.Contains(strings.ToLower(val), strings.ToLower(value.(string)))
+	return 0, false
 }
 
-// startsWith 前缀匹配
-func startsWith(m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[string](m, key, value)
-	if !ok {
-		return false
+// matchStr 把 v 转换为字符串后，使用 fn 匹配
+func matchStr(c Coercion, fn func(val string, value string) bool) CompareFn {
+	return func(v any, value any) bool {
+		val, ok := toString(v, c)
+		if !ok {
+			return false
+		}
+		str, ok := value.(string)
+		if !ok {
+			return false
+		}
+		return fn(val, str)
 	}
-	return strings.HasPrefix(strings.ToLower(val), strings.ToLower(value.(string)))
+}
+
+// contains 包含
+func contains(val string, value string) bool {
+	return strings.Contains(strings.ToLower(val), strings.ToLower(value))
+}
+
+// unContains 不包含
+func unContains(val string, value string) bool {
+	return !contains(val, value)
+}
+
+// startsWith 前缀匹配
+func startsWith(val string, value string) bool {
+	return strings.HasPrefix(strings.ToLower(val), strings.ToLower(value))
 }
 
 // unStartsWith 前缀不匹配
-func unStartsWith(m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[string](m, key, value)
-	if !ok {
-		return false
-	}
-	return !strings.HasPrefix(strings.ToLower(val), strings.ToLower(value.(string)))
+func unStartsWith(val string, value string) bool {
+	return !startsWith(val, value)
 }
 
 // endsWith 后缀匹配
-func endsWith(m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[string](m, key, value)
-	if !ok {
-		return false
-	}
-	return strings.HasSuffix(strings.ToLower(val), strings.ToLower(value.(string)))
+func endsWith(val string, value string) bool {
+	return strings.HasSuffix(strings.ToLower(val), strings.ToLower(value))
 }
 
 // unEndsWith 后缀不匹配
-func unEndsWith(m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[string](m, key, value)
-	if !ok {
-		return false
-	}
-	return !strings.HasSuffix(strings.ToLower(val), strings.ToLower(value.(string)))
+func unEndsWith(val string, value string) bool {
+	return !endsWith(val, value)
+}
+
+// like 通配符匹配，忽略大小写
+func like(val string, value string) bool {
+	return reg(val, "(?i)"+expression.WildcardToRegexp(value))
+}
+
+// notLike 通配符不匹配
+func notLike(val string, value string) bool {
+	return !like(val, value)
 }
 
 // reg 正则
-func reg(m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[string](m, key, value)
-	if !ok {
-		return false
-	}
-	result, err := regexp.MatchString(value.(string), val)
+func reg(val string, value string) bool {
+	result, err := regexp.MatchString(value, val)
 	if err != nil {
 		return false
 	}
 	return result
 }
 
-func in(m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[string](m, key, value)
-	if !ok {
+func in(val string, value string) bool {
+	return utils.FindIndex(strings.Split(value, ","), val) != -1
+}
+
+func notIn(val string, value string) bool {
+	return utils.FindIndex(strings.Split(value, ","), val) == -1
+}
+
+// inArray 与数组中任意一个值相等
+func inArray(c Coercion) CompareFn {
+	return func(v any, value any) bool {
+		arr, ok := value.([]any)
+		if !ok {
+			return false
+		}
+		for _, item := range arr {
+			if equalItem(v, item, c) {
+				return true
+			}
+		}
 		return false
 	}
-	return utils.FindIndex(strings.Split(value.(string), ","), val) != -1
 }
 
-func notIn(m map[string]any, key string, value any) bool {
-	val, ok := typeCheck[string](m, key, value)
-	if !ok {
+// notInArray 与数组中所有值都不相等
+func notInArray(c Coercion) CompareFn {
+	fn := inArray(c)
+	return func(v any, value any) bool {
+		if _, ok := value.([]any); !ok {
+			return false
+		}
+		return !fn(v, value)
+	}
+}
+
+// equalItem 与数组元素比较，元素只能是 float64、bool、string，字符串区分大小写
+func equalItem(v any, item any, c Coercion) bool {
+	switch item := item.(type) {
+	case float64:
+		result, ok := compareNum(v, item, c)
+		return ok && result == 0
+	case bool:
+		val, ok := toBool(v, c)
+		return ok && val == item
+	case string:
+		val, ok := toString(v, c)
+		return ok && val == item
+	}
+	return false
+}
+
+// inCidr 在任意一个 CIDR 范围内，value 为逗号分隔的字符串或者数组；v 不是 IP 地址时，返回 false
+func inCidr(v any, value any) bool {
+	prefixes, err := expression.ParseCidrs(value)
+	if err != nil {
 		return false
 	}
-	return utils.FindIndex(strings.Split(value.(string), ","), val) == -1
+	addr, ok := toAddr(v)
+	return ok && containsAddr(prefixes, addr)
 }
 
-// containsBit 位运算不进行类型判断，直接转成 int64
-func containsBit(m map[string]any, key string, value any) bool {
-	mVal, err := mapstr.M(m).GetValue(key)
+// notInCidr 不在任何一个 CIDR 范围内；v 不是 IP 地址时，返回 false
+func notInCidr(v any, value any) bool {
+	prefixes, err := expression.ParseCidrs(value)
 	if err != nil {
 		return false
 	}
-	mIntVal := number.ParseInt[int64](mVal)
+	addr, ok := toAddr(v)
+	return ok && !containsAddr(prefixes, addr)
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsBit 位运算不进行类型判断，直接转成 int64
+func containsBit(v any, value any) bool {
+	mIntVal := number.ParseInt[int64](v)
 	intVal := number.ParseInt[int64](value)
 	return mIntVal&intVal == intVal
 }
 
 // containsBit 位运算不进行类型判断，直接转成 int64
-func unContainsBit(m map[string]any, key string, value any) bool {
-	mVal, err := mapstr.M(m).GetValue(key)
-	if err != nil {
-		return false
-	}
-	mIntVal := number.ParseInt[int64](mVal)
+func unContainsBit(v any, value any) bool {
+	mIntVal := number.ParseInt[int64](v)
 	intVal := number.ParseInt[int64](value)
 	return mIntVal&intVal != intVal
 }
@@ -0,0 +1,88 @@
+package cond_expr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jummyliu/pkg/datetime"
+)
+
+// Func 表达式中的函数，args 为参数的值
+//
+//	字段参数为 m 中字段的值，字段不存在时为 Missing
+type Func func(args ...any) (any, error)
+
+// DefaultFuncMap 默认函数
+var DefaultFuncMap = map[string]Func{
+	"lower": lower,
+	"upper": upper,
+	"len":   length,
+	"now":   now,
+}
+
+// lower 转小写，lower(str)
+func lower(args ...any) (any, error) {
+	str, err := stringArg("lower", args)
+	if err != nil {
+		return nil, err
+	}
+	return strings.ToLower(str), nil
+}
+
+// upper 转大写，upper(str)
+func upper(args ...any) (any, error) {
+	str, err := stringArg("upper", args)
+	if err != nil {
+		return nil, err
+	}
+	return strings.ToUpper(str), nil
+}
+
+// length 字符串的字符数、数组或对象的元素个数，len(val)
+func length(args ...any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("len need 1 argument but got %d", len(args))
+	}
+	switch v := args[0].(type) {
+	case string:
+		return float64(utf8.RuneCountInString(v)), nil
+	case []any:
+		return float64(len(v)), nil
+	case map[string]any:
+		return float64(len(v)), nil
+	}
+	return nil, fmt.Errorf("len need a string, array or object but got %T", args[0])
+}
+
+// now 当前时间加上偏移，格式为 "2006-01-02 15:04:05"，now() | now('-1h')
+func now(args ...any) (any, error) {
+	if len(args) > 1 {
+		return nil, fmt.Errorf("now need at most 1 argument but got %d", len(args))
+	}
+	offset := ""
+	if len(args) == 1 {
+		str, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("now need a string but got %T", args[0])
+		}
+		offset = str
+	}
+	d, err := datetime.ParseDuration(offset)
+	if err != nil {
+		return nil, err
+	}
+	return datetime.FormatDate(time.Now().Add(d)), nil
+}
+
+func stringArg(name string, args []any) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("%s need 1 argument but got %d", name, len(args))
+	}
+	str, ok := args[0].(string)
+	if !ok {
+		return "", fmt.Errorf("%s need a string but got %T", name, args[0])
+	}
+	return str, nil
+}
@@ -0,0 +1,555 @@
+package cond_expr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+// PrepareFn 预编译函数，根据表达式中的值和类型转换策略生成比较函数，如：预编译正则
+type PrepareFn func(value any, c Coercion) (CompareFn, error)
+
+// prepareFnMap 需要预编译的条件
+var prepareFnMap = map[string]map[token.Token]PrepareFn{
+	"reg": {
+		token.STRING: prepareReg,
+	},
+	"like": {
+		token.STRING: prepareLike(false),
+	},
+	"notLike": {
+		token.STRING: prepareLike(true),
+	},
+	"inCidr": {
+		token.STRING: prepareCidr(false),
+		token.ARRAY:  prepareCidr(false),
+	},
+	"notInCidr": {
+		token.STRING: prepareCidr(true),
+		token.ARRAY:  prepareCidr(true),
+	},
+	"in": {
+		token.ARRAY: prepareInArray,
+	},
+	"notIn": {
+		token.ARRAY: prepareNotInArray,
+	},
+}
+
+// prepareReg 预编译正则
+func prepareReg(value any, c Coercion) (CompareFn, error) {
+	val, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("reg need a string but got %T", value)
+	}
+	re, err := regexp.Compile(val)
+	if err != nil {
+		return nil, err
+	}
+	return func(v any, _ any) bool {
+		str, ok := toString(v, c)
+		if !ok {
+			return false
+		}
+		return re.MatchString(str)
+	}, nil
+}
+
+// prepareLike 把通配符预编译为正则，not 为 true 时取反
+func prepareLike(not bool) PrepareFn {
+	return func(value any, c Coercion) (CompareFn, error) {
+		val, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("like need a string but got %T", value)
+		}
+		re, err := regexp.Compile("(?i)" + expression.WildcardToRegexp(val))
+		if err != nil {
+			return nil, err
+		}
+		return func(v any, _ any) bool {
+			str, ok := toString(v, c)
+			if !ok {
+				return false
+			}
+			return re.MatchString(str) != not
+		}, nil
+	}
+}
+
+// prepareCidr 预先解析 CIDR，not 为 true 时取反；v 不是 IP 地址时，返回 false
+func prepareCidr(not bool) PrepareFn {
+	return func(value any, c Coercion) (CompareFn, error) {
+		prefixes, err := expression.ParseCidrs(value)
+		if err != nil {
+			return nil, err
+		}
+		return func(v any, _ any) bool {
+			addr, ok := toAddr(v)
+			return ok && containsAddr(prefixes, addr) != not
+		}, nil
+	}
+}
+
+// prepareInArray 把数组转换为集合
+func prepareInArray(value any, c Coercion) (CompareFn, error) {
+	set, err := arraySet(value)
+	if err != nil {
+		return nil, err
+	}
+	return func(v any, _ any) bool {
+		return set.has(v, c)
+	}, nil
+}
+
+// prepareNotInArray 把数组转换为集合
+func prepareNotInArray(value any, c Coercion) (CompareFn, error) {
+	set, err := arraySet(value)
+	if err != nil {
+		return nil, err
+	}
+	return func(v any, _ any) bool {
+		return !set.has(v, c)
+	}, nil
+}
+
+// valueSet 数组元素集合，元素只能是 float64、bool、string
+type valueSet map[any]struct{}
+
+func arraySet(value any) (valueSet, error) {
+	arr, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("in need an array but got %T", value)
+	}
+	set := make(valueSet, len(arr))
+	for _, item := range arr {
+		set[item] = struct{}{}
+	}
+	return set, nil
+}
+
+// has 集合中是否存在 v，v 按类型转换策略依次转换为 float64、bool、string 后查找
+func (set valueSet) has(v any, c Coercion) bool {
+	if f, ok := toFloat(v, c); ok {
+		if _, ok := set[f]; ok {
+			return true
+		}
+	}
+	if b, ok := toBool(v, c); ok {
+		if _, ok := set[b]; ok {
+			return true
+		}
+	}
+	if str, ok := toString(v, c); ok {
+		if _, ok := set[str]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Program 预编译的表达式
+//
+//	编译时解析条件函数、预编译正则、拆分字段路径；编译后只读，可以并发执行
+type Program struct {
+	root *program
+	keys []string
+}
+
+// program 预编译的语法树节点
+type program struct {
+	typ   token.Token
+	op    string
+	left  *program
+	right *program
+	ast   *expression.AstNode // 对应的语法树节点，用于 Explain
+
+	// term
+	key         string
+	path        *keyPath
+	value       any
+	valueType   token.Token
+	compareFn   CompareFn
+	conditionFn ConditionFn
+
+	// 字段与字段比较
+	field             *keyPath
+	fieldCompareFns   map[token.Token]CompareFn
+	fieldConditionFns map[token.Token]ConditionFn
+
+	// 函数调用
+	leftCall  *operand
+	rightCall *operand
+}
+
+// operand 预编译的函数调用及其参数
+type operand struct {
+	fn    Func
+	args  []*operand
+	path  *keyPath // 字段参数
+	value any      // 字面值参数
+}
+
+// compileCall 编译函数调用，返回用到的字段
+func (e *Executor) compileCall(call *expression.AstNode, prefix, suffix string) (o *operand, keys []string, err error) {
+	name, _ := call.Value.(string)
+	fn, ok := e.FuncMap[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown function: %v", call.Value)
+	}
+	o = &operand{fn: fn}
+	for _, arg := range call.Args {
+		switch arg.Type {
+		case token.FUNC:
+			sub, subKeys, err := e.compileCall(arg, prefix, suffix)
+			if err != nil {
+				return nil, nil, err
+			}
+			o.args = append(o.args, sub)
+			keys = append(keys, subKeys...)
+		case token.IDENT:
+			key, ok := arg.Value.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("illegal key: %v", arg.Value)
+			}
+			key = e.buildKey(key, prefix, suffix)
+			o.args = append(o.args, &operand{path: newKeyPath(key)})
+			keys = append(keys, key)
+		default:
+			o.args = append(o.args, &operand{value: arg.Value})
+		}
+	}
+	return o, keys, nil
+}
+
+// get 计算函数调用的值
+func (o *operand) get(m map[string]any) (any, error) {
+	if o.path != nil {
+		val, ok := o.path.get(m)
+		if !ok {
+			return Missing, nil
+		}
+		return val, nil
+	}
+	if o.fn == nil {
+		return o.value, nil
+	}
+	args := make([]any, len(o.args))
+	for i, arg := range o.args {
+		val, err := arg.get(m)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = val
+	}
+	return o.fn(args...)
+}
+
+// Compile 使用 StdExecutor 编译表达式
+func Compile(expr string) (*Program, error) {
+	return StdExecutor.Compile(expr, "", "")
+}
+
+// Compile 编译表达式
+func (e *Executor) Compile(expr string, prefix, suffix string) (*Program, error) {
+	lexTokens, err := expression.LexParse(expression.TokensRead(expr))
+	if err != nil {
+		return nil, err
+	}
+	return e.CompileAst(expression.AstParse(lexTokens), prefix, suffix)
+}
+
+// CompileAst 编译 ast
+func (e *Executor) CompileAst(ast *expression.AstNode, prefix, suffix string) (*Program, error) {
+	if err := expression.CheckAst(ast); err != nil {
+		return nil, err
+	}
+	p := &Program{}
+	root, err := e.compile(p, ast, prefix, suffix)
+	if err != nil {
+		return nil, err
+	}
+	p.root = root
+	return p, nil
+}
+
+func (e *Executor) compile(p *Program, ast *expression.AstNode, prefix, suffix string) (*program, error) {
+	if ast == nil {
+		return nil, nil
+	}
+	switch ast.Type {
+	case token.OPERATOR:
+		op, _ := ast.Value.(string)
+		if op != "&&" && op != "||" {
+			return nil, fmt.Errorf("unsupported operator: %v", ast.Value)
+		}
+		left, err := e.compile(p, ast.Left, prefix, suffix)
+		if err != nil {
+			return nil, err
+		}
+		right, err := e.compile(p, ast.Right, prefix, suffix)
+		if err != nil {
+			return nil, err
+		}
+		return &program{typ: ast.Type, op: op, left: left, right: right, ast: ast}, nil
+	case token.NOT:
+		left, err := e.compile(p, ast.Left, prefix, suffix)
+		if err != nil {
+			return nil, err
+		}
+		return &program{typ: ast.Type, left: left, ast: ast}, nil
+	case token.CONDITION:
+		return e.compileTerm(p, ast, prefix, suffix)
+	case token.QUANT:
+		return e.compileQuant(p, ast, prefix, suffix)
+	}
+	return nil, fmt.Errorf("unsupported node: %s", ast.Type)
+}
+
+// compileQuant 编译数组元素条件，子表达式编译为 left，规则与 DoQuant 一致
+func (e *Executor) compileQuant(p *Program, quant *expression.AstNode, prefix, suffix string) (*program, error) {
+	if quant.Left == nil || quant.Right == nil {
+		return nil, fmt.Errorf("illegal quant: %v", quant.Value)
+	}
+	path, ok := quant.Left.Value.(string)
+	if !ok {
+		return nil, fmt.Errorf("illegal key: %v", quant.Left.Value)
+	}
+	name, _ := quant.Value.(string)
+	if !token.IsQuant(name) {
+		return nil, fmt.Errorf("unsupported quant: %v", quant.Value)
+	}
+	// 子表达式中的字段相对于数组元素，不加入 p.keys
+	left, err := e.elemExecutor().compile(&Program{}, quant.Right, "", suffix)
+	if err != nil {
+		return nil, err
+	}
+	path = e.buildKey(path, prefix, "")
+	p.keys = append(p.keys, path)
+	return &program{
+		typ:  quant.Type,
+		op:   name,
+		left: left,
+		ast:  quant,
+		key:  path,
+		path: newKeyPath(path),
+	}, nil
+}
+
+func (e *Executor) compileTerm(p *Program, term *expression.AstNode, prefix, suffix string) (*program, error) {
+	// 判断是否有 left 和 right
+	if term.Left == nil || term.Right == nil {
+		return nil, fmt.Errorf("illegal term: %v", term.Value)
+	}
+	key, ok := term.Left.Value.(string)
+	if !ok {
+		return nil, fmt.Errorf("illegal key: %v", term.Left.Value)
+	}
+	op, ok := term.Value.(string)
+	if !ok {
+		return nil, fmt.Errorf("illegal condition: %v", term.Value)
+	}
+	node := &program{
+		typ:       term.Type,
+		op:        op,
+		ast:       term,
+		value:     term.Right.Value,
+		valueType: term.Right.Type,
+	}
+	if term.Left.Type == token.FUNC {
+		// 左侧为函数调用，只能使用比较函数
+		call, keys, err := e.compileCall(term.Left, prefix, suffix)
+		if err != nil {
+			return nil, err
+		}
+		node.leftCall = call
+		p.keys = append(p.keys, keys...)
+	} else {
+		key = e.buildKey(key, prefix, suffix)
+		node.key = key
+		node.path = newKeyPath(key)
+		p.keys = append(p.keys, key)
+	}
+	switch term.Right.Type {
+	case token.FIELD:
+		// 字段与字段比较，值的类型在执行时才能确定
+		field, ok := term.Right.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("illegal field: %v", term.Right.Value)
+		}
+		if _, ok := e.FnMap[op]; !ok {
+			return nil, fmt.Errorf("unsupported condition: %s %s", op, term.Right.Type)
+		}
+		field = e.buildKey(field, prefix, suffix)
+		node.field = newKeyPath(field)
+		node.fieldCompareFns = e.CompareFnMap[op]
+		node.fieldConditionFns = e.FnMap[op]
+		p.keys = append(p.keys, field)
+		return node, nil
+	case token.FUNC:
+		// 右侧为函数调用，值的类型在执行时才能确定
+		if _, ok := e.FnMap[op]; !ok {
+			return nil, fmt.Errorf("unsupported condition: %s %s", op, term.Right.Type)
+		}
+		call, keys, err := e.compileCall(term.Right, prefix, suffix)
+		if err != nil {
+			return nil, err
+		}
+		node.rightCall = call
+		node.fieldCompareFns = e.CompareFnMap[op]
+		node.fieldConditionFns = e.FnMap[op]
+		p.keys = append(p.keys, keys...)
+		return node, nil
+	}
+	if fn, ok := e.CompareFnMap[op][term.Right.Type]; ok {
+		node.compareFn = fn
+		if prepare, ok := prepareFnMap[op][term.Right.Type]; ok {
+			fn, err := prepare(term.Right.Value, e.Coercion)
+			if err != nil {
+				return nil, err
+			}
+			node.compareFn = fn
+		}
+	} else if fn, ok := e.FnMap[op][term.Right.Type]; ok && node.leftCall == nil {
+		node.conditionFn = fn
+	} else {
+		return nil, fmt.Errorf("unsupported condition: %s %s", op, term.Right.Type)
+	}
+	return node, nil
+}
+
+// Keys 表达式中用到的所有字段
+func (p *Program) Keys() []string {
+	return p.keys
+}
+
+// Eval 执行表达式
+func (p *Program) Eval(m map[string]any) bool {
+	return p.root.eval(m)
+}
+
+func (n *program) eval(m map[string]any) bool {
+	if n == nil {
+		return true
+	}
+	switch n.typ {
+	case token.OPERATOR:
+		if n.op == "&&" {
+			return n.left.eval(m) && n.right.eval(m)
+		}
+		return n.left.eval(m) || n.right.eval(m)
+	case token.NOT:
+		return !n.left.eval(m)
+	case token.QUANT:
+		return n.evalQuant(m)
+	}
+	return n.evalTerm(m)
+}
+
+func (n *program) evalQuant(m map[string]any) bool {
+	val, _ := n.path.get(m)
+	all := n.op == "all"
+	for _, elem := range elements(val) {
+		if n.left.eval(elem) != all {
+			return !all
+		}
+	}
+	return all
+}
+
+func (n *program) evalTerm(m map[string]any) bool {
+	result, _, _, _ := n.term(m)
+	return result
+}
+
+// term 执行条件，返回结果、左侧的值以及比较的值
+//
+//	左侧字段不存在时为 Missing；字段引用、函数调用失败时返回 err
+func (n *program) term(m map[string]any) (result bool, left any, right any, err error) {
+	value, valueType := n.value, n.valueType
+	compareFn, conditionFn := n.compareFn, n.conditionFn
+	if n.field != nil || n.rightCall != nil {
+		var v any
+		if n.field != nil {
+			var ok bool
+			if v, ok = n.field.get(m); !ok {
+				return false, nil, Missing, nil
+			}
+		} else if v, err = n.rightCall.get(m); err != nil {
+			return false, nil, nil, err
+		}
+		var ok bool
+		if value, valueType, ok = valueToken(v); !ok {
+			return false, nil, v, nil
+		}
+		compareFn, conditionFn = n.fieldCompareFns[valueType], n.fieldConditionFns[valueType]
+	}
+	if n.leftCall != nil {
+		if compareFn == nil {
+			return false, nil, value, nil
+		}
+		val, err := n.leftCall.get(m)
+		if err != nil {
+			return false, nil, value, err
+		}
+		return compareFn(val, value), val, value, nil
+	}
+	if compareFn == nil {
+		if conditionFn == nil {
+			return false, nil, value, nil
+		}
+		return conditionFn(m, n.key, value), nil, value, nil
+	}
+	val, ok := n.path.get(m)
+	if !ok {
+		if valueType != token.NULL {
+			return false, Missing, value, nil
+		}
+		val = Missing
+	}
+	return compareFn(val, value), val, value, nil
+}
+
+// keyPath 预先拆分的字段路径，查找规则与 mapstr.M.GetValue 一致
+//
+//	每一层先查找完整的剩余 key，不存在再按 '.' 进入下一层
+type keyPath struct {
+	parts []string // a, b, c
+	rests []string // a.b.c, b.c, c
+}
+
+func newKeyPath(key string) *keyPath {
+	parts := strings.Split(key, ".")
+	rests := make([]string, len(parts))
+	for i := range parts {
+		rests[i] = strings.Join(parts[i:], ".")
+	}
+	return &keyPath{
+		parts: parts,
+		rests: rests,
+	}
+}
+
+func (p *keyPath) get(m map[string]any) (val any, ok bool) {
+	data := m
+	for i, part := range p.parts {
+		if val, ok := data[p.rests[i]]; ok {
+			return val, true
+		}
+		if i == len(p.parts)-1 {
+			break
+		}
+		switch sub := data[part].(type) {
+		case map[string]any:
+			data = sub
+		case mapstr.M:
+			data = sub
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,557 @@
+package expression_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jummyliu/pkg/db/sqlitebuilder"
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/clickhouse_expr"
+	"github.com/jummyliu/pkg/expression/cond_expr"
+	"github.com/jummyliu/pkg/expression/es_expr"
+	"github.com/jummyliu/pkg/expression/internal/sqlitetest"
+	"github.com/jummyliu/pkg/expression/mysql_expr"
+	"github.com/jummyliu/pkg/expression/sqlite_expr"
+	"github.com/jummyliu/pkg/expression/token"
+	"github.com/jummyliu/pkg/utils"
+)
+
+// 差异测试：随机生成表达式和文档，以 cond_expr 的结果为准，比较其他执行器的结果
+//
+//	mysql_expr、clickhouse_expr、sqlite_expr 生成的 SQL 在内存 sqlite 中执行，只比较方言重叠的条件
+//	es_expr 生成的查询使用 evalES 在进程内执行
+//
+//	字段总是存在且不为 null，字符串只使用小写字母，避免各数据库 null、排序规则的差异
+
+// diffField 随机文档的字段
+type diffField struct {
+	Name string
+	Type token.Token
+}
+
+var diffFields = []diffField{
+	{Name: "n1", Type: token.NUM},
+	{Name: "n2", Type: token.NUM},
+	{Name: "flags", Type: token.NUM},
+	{Name: "s1", Type: token.STRING},
+	{Name: "s2", Type: token.STRING},
+}
+
+var (
+	diffNumOps = []string{"==", "!=", ">=", "<=", ">", "<", "in", "notIn", "containsBit", "unContainsBit", "exists", "notExists"}
+	diffStrOps = []string{"==", "!=", ">=", "<=", ">", "<", "contains", "unContains", "startsWith", "unStartsWith", "endsWith", "unEndsWith", "reg", "in", "notIn", "exists", "notExists"}
+	diffRegs   = []string{"^a", "b$", "a.b", "^$", "a|b", "^(ab)+$"}
+)
+
+// diffTarget 被比较的执行器
+type diffTarget struct {
+	Name string
+	// Supports 支持的条件 op => 值的类型，为 nil 时支持所有条件
+	Supports map[string][]token.Token
+	// Run 执行语法树，返回匹配的文档 id，以及用于报告的查询语句
+	Run func(t *testing.T, ast *expression.AstNode) (ids []int, query string, ok bool)
+}
+
+// sqlOverlap mysql、clickhouse 与 sqlite 方言重叠的条件
+var sqlOverlap = map[string][]token.Token{
+	"==":        {token.NUM, token.STRING, token.FIELD},
+	"!=":        {token.NUM, token.STRING, token.FIELD},
+	">=":        {token.NUM, token.STRING, token.FIELD},
+	"<=":        {token.NUM, token.STRING, token.FIELD},
+	">":         {token.NUM, token.STRING, token.FIELD},
+	"<":         {token.NUM, token.STRING, token.FIELD},
+	"reg":       {token.STRING},
+	"in":        {token.ARRAY},
+	"notIn":     {token.ARRAY},
+	"exists":    {token.NULL},
+	"notExists": {token.NULL},
+}
+
+// diffDB 内存 sqlite，保存当前的随机文档
+type diffDB struct {
+	db   *sqlitebuilder.DBConnect
+	docs []map[string]any
+}
+
+func newDiffDB(t testing.TB) *diffDB {
+	db := sqlitetest.New(t, "CREATE TABLE docs (id INTEGER, n1 REAL, n2 REAL, flags INTEGER, s1 TEXT, s2 TEXT)")
+	return &diffDB{db: db}
+}
+
+// reset 重新写入文档
+func (d *diffDB) reset(t testing.TB, docs []map[string]any) {
+	if _, _, err := d.db.Exec(context.Background(), "DELETE FROM docs"); err != nil {
+		t.Fatal(err)
+	}
+	sqlitetest.Insert(t, d.db, "docs", []string{"id", "n1", "n2", "flags", "s1", "s2"}, docs)
+	d.docs = docs
+}
+
+// query 执行 SQL 条件，返回匹配的文档 id
+func (d *diffDB) query(t *testing.T, sql string, params []any) ([]int, bool) {
+	if len(sql) == 0 {
+		return nil, false
+	}
+	return sqlitetest.QueryIDs(t, d.db, "docs", sql, params), true
+}
+
+func (d *diffDB) targets() []*diffTarget {
+	sqlTarget := func(name string, do func(ast *expression.AstNode) (string, []any), supports map[string][]token.Token) *diffTarget {
+		return &diffTarget{
+			Name:     name,
+			Supports: supports,
+			Run: func(t *testing.T, ast *expression.AstNode) ([]int, string, bool) {
+				sql, params := do(ast)
+				ids, ok := d.query(t, sql, params)
+				return ids, fmt.Sprintf("%s %#v", sql, params), ok
+			},
+		}
+	}
+	return []*diffTarget{
+		sqlTarget("mysql_expr", func(ast *expression.AstNode) (string, []any) {
+			sql, params, _ := mysql_expr.StdExecutor.DoAst(ast, "", "")
+			return sql, params
+		}, sqlOverlap),
+		sqlTarget("clickhouse_expr", func(ast *expression.AstNode) (string, []any) {
+			sql, params, _ := clickhouse_expr.StdExecutor.DoAst(ast, "", "")
+			return sql, params
+		}, sqlOverlap),
+		sqlTarget("sqlite_expr", func(ast *expression.AstNode) (string, []any) {
+			sql, params, _ := sqlite_expr.StdExecutor.DoAst(ast, "", "")
+			return sql, params
+		}, nil),
+		{
+			Name: "es_expr",
+			// es 的 regexp 是全匹配，与其他执行器的 reg 语义不同，不进行比较
+			Supports: supportsExcept(es_expr.DefaultFnMap, "reg"),
+			Run: func(t *testing.T, ast *expression.AstNode) ([]int, string, bool) {
+				query, _ := es_expr.StdExecutor.DoAst(ast, "", "")
+				if query == nil {
+					return nil, "", false
+				}
+				ids := []int{}
+				for _, doc := range d.docs {
+					if evalES(t, query, doc) {
+						ids = append(ids, doc["id"].(int))
+					}
+				}
+				return ids, fmt.Sprint(query), true
+			},
+		},
+	}
+}
+
+// supportsExcept 执行器支持的条件，排除 excludes
+func supportsExcept[T any](fnMap map[string]map[token.Token]T, excludes ...string) map[string][]token.Token {
+	supports := map[string][]token.Token{}
+	for op, fns := range fnMap {
+		if utils.FindIndex(excludes, op) != -1 {
+			continue
+		}
+		for t := range fns {
+			supports[op] = append(supports[op], t)
+		}
+	}
+	return supports
+}
+
+// supported 判断执行器是否支持语法树中的所有条件
+func (target *diffTarget) supported(ast *expression.AstNode) bool {
+	if target.Supports == nil || ast == nil {
+		return true
+	}
+	switch ast.Type {
+	case token.OPERATOR:
+		return target.supported(ast.Left) && target.supported(ast.Right)
+	case token.NOT:
+		return target.supported(ast.Left)
+	case token.CONDITION:
+		for _, t := range target.Supports[ast.Value.(string)] {
+			if t == ast.Right.Type {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// oracle cond_expr 的结果
+func oracle(t *testing.T, ast *expression.AstNode, docs []map[string]any) []int {
+	p, err := cond_expr.StdExecutor.CompileAst(ast, "", "")
+	if err != nil {
+		t.Fatalf("expr %s compile failure: %s", expression.Format(ast), err)
+	}
+	ids := []int{}
+	for _, doc := range docs {
+		if p.Eval(doc) {
+			ids = append(ids, doc["id"].(int))
+		}
+	}
+	return ids
+}
+
+// genString 随机字符串，只使用 a、b
+func genString(r *rand.Rand) string {
+	var b strings.Builder
+	for i := r.Intn(4); i > 0; i-- {
+		b.WriteByte("ab"[r.Intn(2)])
+	}
+	return b.String()
+}
+
+// genNum 随机数字，包含少量小数
+func genNum(r *rand.Rand) float64 {
+	if r.Intn(5) == 0 {
+		return float64(r.Intn(7)-3) + 0.5
+	}
+	return float64(r.Intn(7) - 3)
+}
+
+// genDocs 随机文档，字段总是存在且不为 null
+func genDocs(r *rand.Rand, n int) []map[string]any {
+	docs := make([]map[string]any, 0, n)
+	for i := 1; i <= n; i++ {
+		docs = append(docs, map[string]any{
+			"id":    i,
+			"n1":    genNum(r),
+			"n2":    genNum(r),
+			"flags": float64(r.Intn(8)),
+			"s1":    genString(r),
+			"s2":    genString(r),
+		})
+	}
+	return docs
+}
+
+// genCond 随机条件，值的类型与字段一致
+func genCond(r *rand.Rand) *expression.AstNode {
+	field := diffFields[r.Intn(len(diffFields))]
+	if field.Type == token.NUM {
+		op := diffNumOps[r.Intn(len(diffNumOps))]
+		switch op {
+		case "in", "notIn":
+			arr := []float64{}
+			for i := r.Intn(3); i > 0; i-- {
+				arr = append(arr, genNum(r))
+			}
+			return expression.Cond(field.Name, op, arr)
+		case "containsBit", "unContainsBit":
+			return expression.Cond("flags", op, r.Intn(8))
+		case "exists", "notExists":
+			return expression.Cond(field.Name, op, nil)
+		}
+		if r.Intn(4) == 0 {
+			other := "n2"
+			if field.Name == "n2" {
+				other = "n1"
+			}
+			return expression.Cond(field.Name, op, expression.Field(other))
+		}
+		return expression.Cond(field.Name, op, genNum(r))
+	}
+	op := diffStrOps[r.Intn(len(diffStrOps))]
+	switch op {
+	case "reg":
+		return expression.Cond(field.Name, op, diffRegs[r.Intn(len(diffRegs))])
+	case "in", "notIn":
+		arr := []string{}
+		for i := r.Intn(3); i > 0; i-- {
+			arr = append(arr, genString(r))
+		}
+		return expression.Cond(field.Name, op, arr)
+	case "exists", "notExists":
+		return expression.Cond(field.Name, op, nil)
+	}
+	return expression.Cond(field.Name, op, genString(r))
+}
+
+// genAst 随机语法树
+func genAst(r *rand.Rand, depth int) *expression.AstNode {
+	if depth <= 0 || r.Intn(3) == 0 {
+		return genCond(r)
+	}
+	switch r.Intn(5) {
+	case 0:
+		return expression.Not(genAst(r, depth-1))
+	case 1, 2:
+		return expression.And(genAst(r, depth-1), genAst(r, depth-1))
+	}
+	return expression.Or(genAst(r, depth-1), genAst(r, depth-1))
+}
+
+// diffMismatch 不一致的结果
+type diffMismatch struct {
+	Target string
+	Expr   string
+	Query  string
+	Doc    map[string]any
+	Need   bool
+}
+
+func (m *diffMismatch) String() string {
+	keys := make([]string, 0, len(m.Doc))
+	for key := range m.Doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fields := make([]string, 0, len(keys))
+	for _, key := range keys {
+		fields = append(fields, fmt.Sprintf("%s: %#v", key, m.Doc[key]))
+	}
+	return fmt.Sprintf("%s disagrees with cond_expr\nexpr:  %s\nquery: %s\ndoc:   {%s}\nneed:  %v",
+		m.Target, m.Expr, m.Query, strings.Join(fields, ", "), m.Need)
+}
+
+// diffCheck 比较执行器与 cond_expr 的结果，不一致时返回第一个不一致的文档
+func diffCheck(t *testing.T, d *diffDB, target *diffTarget, ast *expression.AstNode) *diffMismatch {
+	if !target.supported(ast) {
+		return nil
+	}
+	got, query, ok := target.Run(t, ast)
+	if !ok {
+		return &diffMismatch{Target: target.Name, Expr: expression.Format(ast), Query: "<empty>"}
+	}
+	need := oracle(t, ast, d.docs)
+	if reflect.DeepEqual(got, need) {
+		return nil
+	}
+	for _, doc := range d.docs {
+		id := doc["id"].(int)
+		isNeed := utils.FindIndex(need, id) != -1
+		if isNeed != (utils.FindIndex(got, id) != -1) {
+			return &diffMismatch{Target: target.Name, Expr: expression.Format(ast), Query: query, Doc: doc, Need: isNeed}
+		}
+	}
+	return nil
+}
+
+// shrink 语法树的候选简化：用子树替换当前节点，或者简化其中一个子树
+func shrink(ast *expression.AstNode) []*expression.AstNode {
+	switch ast.Type {
+	case token.NOT:
+		candidates := []*expression.AstNode{ast.Left}
+		for _, item := range shrink(ast.Left) {
+			candidates = append(candidates, expression.Not(item))
+		}
+		return candidates
+	case token.OPERATOR:
+		candidates := []*expression.AstNode{ast.Left, ast.Right}
+		for _, item := range shrink(ast.Left) {
+			candidates = append(candidates, &expression.AstNode{Type: ast.Type, Value: ast.Value, Left: item, Right: ast.Right})
+		}
+		for _, item := range shrink(ast.Right) {
+			candidates = append(candidates, &expression.AstNode{Type: ast.Type, Value: ast.Value, Left: ast.Left, Right: item})
+		}
+		return candidates
+	}
+	return nil
+}
+
+// minimize 最小化不一致的表达式和文档
+func minimize(t *testing.T, d *diffDB, target *diffTarget, ast *expression.AstNode, mismatch *diffMismatch) *diffMismatch {
+	// 只保留不一致的文档
+	if mismatch.Doc != nil {
+		d.reset(t, []map[string]any{mismatch.Doc})
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, candidate := range shrink(ast) {
+			if m := diffCheck(t, d, target, candidate); m != nil {
+				ast, mismatch, changed = candidate, m, true
+				break
+			}
+		}
+	}
+	return mismatch
+}
+
+// diffRun 使用随机种子生成表达式和文档，比较所有执行器
+func diffRun(t *testing.T, d *diffDB, seed int64) {
+	r := rand.New(rand.NewSource(seed))
+	docs := genDocs(r, 8)
+	ast := genAst(r, 3)
+	if err := expression.CheckAst(ast); err != nil {
+		t.Fatalf("seed %d build failure: %s", seed, err)
+	}
+	for _, target := range d.targets() {
+		d.reset(t, docs)
+		if mismatch := diffCheck(t, d, target, ast); mismatch != nil {
+			t.Fatalf("seed %d: %s", seed, minimize(t, d, target, ast, mismatch))
+		}
+	}
+}
+
+func TestDifferential(t *testing.T) {
+	d := newDiffDB(t)
+	for seed := int64(0); seed < 300; seed++ {
+		diffRun(t, d, seed)
+	}
+}
+
+func FuzzDifferential(f *testing.F) {
+	for seed := int64(0); seed < 8; seed++ {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		diffRun(t, newDiffDB(t), seed)
+	})
+}
+
+// regESScript es_expr 字段比较的 script
+var regESScript = regexp.MustCompile(`doc\[params\.left\]\.value (\S+) doc\[params\.right\]\.value`)
+
+// evalES 在进程内执行 es_expr 生成的查询，只支持 es_expr 会生成的查询
+//
+//	字段名的 .keyword 后缀会被去掉
+func evalES(t *testing.T, query map[string]any, doc map[string]any) bool {
+	if len(query) != 1 {
+		t.Fatalf("es query need 1 clause but got %v", query)
+	}
+	for kind, body := range query {
+		clause := body.(map[string]any)
+		switch kind {
+		case "bool":
+			return evalESBool(t, clause, doc)
+		case "exists":
+			_, ok := doc[esField(clause["field"].(string))]
+			return ok
+		case "script":
+			script := clause["script"].(map[string]any)
+			params := script["params"].(map[string]any)
+			match := regESScript.FindStringSubmatch(script["source"].(string))
+			if match == nil {
+				t.Fatalf("unsupported es script %v", script)
+			}
+			return compareAny(doc[params["left"].(string)], match[1], doc[params["right"].(string)])
+		}
+		for field, cond := range clause {
+			return evalESLeaf(t, kind, doc[esField(field)], cond)
+		}
+	}
+	return false
+}
+
+// evalESBool bool 查询，只有 should 时至少匹配一个
+func evalESBool(t *testing.T, clause map[string]any, doc map[string]any) bool {
+	for _, item := range esClauses(clause["must"]) {
+		if !evalES(t, item, doc) {
+			return false
+		}
+	}
+	for _, item := range esClauses(clause["filter"]) {
+		if !evalES(t, item, doc) {
+			return false
+		}
+	}
+	for _, item := range esClauses(clause["must_not"]) {
+		if evalES(t, item, doc) {
+			return false
+		}
+	}
+	should := esClauses(clause["should"])
+	if len(should) == 0 {
+		return true
+	}
+	for _, item := range should {
+		if evalES(t, item, doc) {
+			return true
+		}
+	}
+	return false
+}
+
+func esClauses(val any) []map[string]any {
+	clauses, _ := val.([]map[string]any)
+	return clauses
+}
+
+func esField(field string) string {
+	return strings.TrimSuffix(field, ".keyword")
+}
+
+// evalESLeaf term、terms、range、wildcard、regexp 查询
+func evalESLeaf(t *testing.T, kind string, val any, cond any) bool {
+	switch kind {
+	case "term":
+		params := cond.(map[string]any)
+		if params["case_insensitive"] == true {
+			return strings.EqualFold(fmt.Sprint(val), fmt.Sprint(params["value"]))
+		}
+		return val == params["value"]
+	case "terms":
+		items := reflect.ValueOf(cond)
+		for i := 0; i < items.Len(); i++ {
+			if val == items.Index(i).Interface() {
+				return true
+			}
+		}
+		return false
+	case "range":
+		for op, bound := range cond.(map[string]any) {
+			esOps := map[string]string{"gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+			if !compareAny(val, esOps[op], bound) {
+				return false
+			}
+		}
+		return true
+	case "wildcard":
+		params := cond.(map[string]any)
+		pattern := "^" + strings.ReplaceAll(strings.ReplaceAll(regexp.QuoteMeta(params["value"].(string)), `\*`, ".*"), `\?`, ".") + "$"
+		if params["case_insensitive"] == true {
+			pattern = "(?i)" + pattern
+		}
+		return regexp.MustCompile(pattern).MatchString(fmt.Sprint(val))
+	case "regexp":
+		return regexp.MustCompile("^(?:" + cond.(string) + ")$").MatchString(fmt.Sprint(val))
+	}
+	t.Fatalf("unsupported es query %s", kind)
+	return false
+}
+
+// compareAny 比较相同类型的 float64 或者 string
+func compareAny(left any, op string, right any) bool {
+	var cmp int
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return false
+		}
+		if l < r {
+			cmp = -1
+		} else if l > r {
+			cmp = 1
+		} else {
+			cmp = 0
+		}
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return false
+		}
+		cmp = strings.Compare(l, r)
+	default:
+		return false
+	}
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package expression
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+// 语法错误信息
+const (
+	ErrNextState = "illegal NEXT STATE" // 下一状态不匹配
+	ErrMissLBT   = "illegal MISS LBT"   // 右括号不匹配
+	ErrEOF       = "illegal EOF"        // 没有解析完 token
+	ErrMissEnd   = "illegal MISS END"   // 表达式不完整
+	ErrMissRBT   = "illegal MISS RBT"   // 括号没闭合
+	ErrDate      = "illegal DATE"       // 日期无法解析
+	ErrBetween   = "illegal BETWEEN"    // between 的值不是 2 个元素的数组
+	ErrCidr      = "illegal CIDR"       // inCidr、notInCidr 的值无法解析
+)
+
+// SyntaxError 语法错误
+//
+//	包含出错位置、出错的分词，以及当前状态下合法的分词
+type SyntaxError struct {
+	Msg      string        // 错误信息
+	Offset   int           // 出错位置，字节偏移
+	Len      int           // 出错分词的长度，到达表达式末尾时为 0
+	Token    *LexNode      // 出错的分词，到达表达式末尾时为 nil
+	Expected []token.Token // 当前状态下合法的分词
+}
+
+// newSyntaxError 创建语法错误，expected 中会排除 excludes
+func newSyntaxError(msg string, offset int, item *LexNode, expected []token.Token, excludes ...token.Token) *SyntaxError {
+	err := &SyntaxError{
+		Msg:    msg,
+		Offset: offset,
+		Token:  item,
+	}
+	if item != nil {
+		err.Len = item.Len
+	}
+	for _, expected := range expected {
+		isExclude := false
+		for _, exclude := range excludes {
+			if expected == exclude {
+				isExclude = true
+				break
+			}
+		}
+		if !isExclude {
+			err.Expected = append(err.Expected, expected)
+		}
+	}
+	return err
+}
+
+func (e *SyntaxError) Error() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%s at %d", e.Msg, e.Offset))
+	if e.Token != nil {
+		b.WriteString(fmt.Sprintf(": unexpected %s %q", e.Token.Type, fmt.Sprint(e.Token.Value)))
+	} else {
+		b.WriteString(": unexpected end")
+	}
+	if len(e.Expected) != 0 {
+		expected := make([]string, 0, len(e.Expected))
+		for _, item := range e.Expected {
+			expected = append(expected, string(item))
+		}
+		b.WriteString(fmt.Sprintf(", expected %s", strings.Join(expected, " | ")))
+	}
+	return b.String()
+}
+
+// Caret 在表达式下方使用 ^ 标出出错的位置
+//
+//	a == 1 && b
+//	          ^
+func (e *SyntaxError) Caret(expr string) string {
+	offset := e.Offset
+	if offset > len(expr) {
+		offset = len(expr)
+	}
+	length := e.Len
+	if offset+length > len(expr) {
+		length = len(expr) - offset
+	}
+	width := utf8.RuneCountInString(expr[offset : offset+length])
+	if width == 0 {
+		width = 1
+	}
+	var b strings.Builder
+	b.WriteString(expr)
+	b.WriteByte('\n')
+	b.WriteString(strings.Repeat(" ", utf8.RuneCountInString(expr[:offset])))
+	b.WriteString(strings.Repeat("^", width))
+	return b.String()
+}
@@ -9,6 +9,9 @@ import (
 
 type Executor struct {
 	FnMap map[string]map[token.Token]ConditionFn
+
+	// FuncMap 表达式中可以调用的函数
+	FuncMap map[string]Func
 }
 
 var StdExecutor = New(nil)
@@ -18,17 +21,21 @@ func New(fnMap map[string]map[token.Token]ConditionFn) *Executor {
 		fnMap = DefaultFnMap
 	}
 	return &Executor{
-		FnMap: fnMap,
+		FnMap:   fnMap,
+		FuncMap: DefaultFuncMap,
 	}
 }
 
-// DoExpr 执行表达式
+// DoExpr 执行表达式，存在不支持的函数调用时返回错误
 func (e *Executor) DoExpr(expr string, prefix, suffix string) (query map[string]any, keys []string, err error) {
 	lexTokens, err := expression.LexParse(expression.TokensRead(expr))
 	if err != nil {
 		return nil, nil, err
 	}
 	ast := expression.AstParse(lexTokens)
+	if err := e.checkFunc(ast); err != nil {
+		return nil, nil, err
+	}
 	query, keys = e.DoAst(ast, prefix, suffix)
 	return query, keys, nil
 }
@@ -68,21 +75,89 @@ func (e *Executor) DoAst(ast *expression.AstNode, prefix, suffix string) (query
 				},
 			}, keys
 		}
+	case token.NOT:
+		// 取反
+		subResult, subKeys := e.DoAst(ast.Left, prefix, suffix)
+		if subResult == nil {
+			return nil, nil
+		}
+		return map[string]any{
+			"bool": map[string]any{
+				"must_not": []map[string]any{
+					subResult,
+				},
+			},
+		}, subKeys
+	case token.ILLEGAL:
+		// 不合法的条件，不能退化为查询全部
+		return map[string]any{"match_none": map[string]any{}}, nil
 	case token.CONDITION:
 		// 单个表达式转换
 		return e.DoTerm(ast, prefix, suffix)
+	case token.QUANT:
+		// 数组元素条件
+		return e.DoQuant(ast, prefix, suffix)
 	}
 	return nil, nil
 }
 
+// DoQuant 执行数组元素条件，转换为 nested 查询，path 需要为 nested 类型
+//
+//	子表达式中的字段拼接 path 作为前缀
+//	any(path, expr) => nested(expr)
+//	all(path, expr) => must_not nested(must_not expr)，空数组、字段不存在时为 true
+func (e *Executor) DoQuant(quant *expression.AstNode, prefix, suffix string) (query map[string]any, keys []string) {
+	if quant.Left == nil || quant.Right == nil {
+		return nil, nil
+	}
+	path, ok := quant.Left.Value.(string)
+	if !ok {
+		return nil, nil
+	}
+	name, _ := quant.Value.(string)
+	if !token.IsQuant(name) {
+		return nil, nil
+	}
+	path = buildKey(path, prefix, "")
+	subResult, keys := e.DoAst(quant.Right, path, suffix)
+	if subResult == nil {
+		return nil, nil
+	}
+	if name == "any" {
+		return nested(path, subResult), keys
+	}
+	return map[string]any{
+		"bool": map[string]any{
+			"must_not": []map[string]any{
+				nested(path, map[string]any{
+					"bool": map[string]any{
+						"must_not": []map[string]any{
+							subResult,
+						},
+					},
+				}),
+			},
+		},
+	}, keys
+}
+
+func nested(path string, query map[string]any) map[string]any {
+	return map[string]any{
+		"nested": map[string]any{
+			"path":  path,
+			"query": query,
+		},
+	}
+}
+
 // DoTerm 执行 term
 func (e *Executor) DoTerm(term *expression.AstNode, prefix, suffix string) (query map[string]any, keys []string) {
 	// 判断是否有 left 和 right
 	if term.Left == nil || term.Right == nil {
 		return nil, nil
 	}
-	// 判断 left，即 key 的类型
-	if _, ok := term.Left.Value.(string); !ok {
+	// 判断 left，即 key 的类型，不支持函数调用
+	if _, ok := term.Left.Value.(string); !ok || term.Left.Type == token.FUNC {
 		return nil, nil
 	}
 	// 判断 condition 的类型
@@ -97,12 +172,34 @@ func (e *Executor) DoTerm(term *expression.AstNode, prefix, suffix string) (quer
 	if !ok {
 		return nil, nil
 	}
-	key := term.Left.Value.(string)
+	key := buildKey(term.Left.Value.(string), prefix, suffix)
+	if term.Right.Type == token.FIELD {
+		// 字段与字段比较，值为拼接前缀、后缀后的字段名
+		field, ok := term.Right.Value.(string)
+		if !ok {
+			return nil, nil
+		}
+		field = buildKey(field, prefix, suffix)
+		return fn(key, field), []string{key, field}
+	}
+	if term.Right.Type == token.FUNC {
+		// 函数调用，值为函数计算出的值，如：日期计算表达式
+		value, ok := e.call(term.Right)
+		if !ok {
+			return nil, nil
+		}
+		return fn(key, value), []string{key}
+	}
+	return fn(key, term.Right.Value), []string{key}
+}
+
+// buildKey 拼接前缀、后缀
+func buildKey(key string, prefix, suffix string) string {
 	if len(prefix) > 0 {
 		key = fmt.Sprintf("%s.%s", prefix, key)
 	}
 	if len(suffix) > 0 {
 		key = fmt.Sprintf("%s.%s", key, suffix)
 	}
-	return fn(key, term.Right.Value), []string{key}
+	return key
 }
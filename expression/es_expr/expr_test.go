@@ -0,0 +1,29 @@
+package es_expr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDoExprUnsupportedFunc(t *testing.T) {
+	testCases := map[string]string{
+		"lower(user) == 'x'":                    "lower",
+		"a == 1 && len(host) > 5":               "len",
+		"ts > now('-1h') || user == upper('x')": "upper",
+		"ts > now(lower('x'))":                  "lower",
+		"ts > now('abc')":                       "now",
+		"any(p, ts > now(host))":                "now",
+	}
+	for expr, name := range testCases {
+		query, _, err := StdExecutor.DoExpr(expr, "", "")
+		if err == nil {
+			t.Fatalf("testCase %s need error but got %v", expr, query)
+		}
+		if !strings.Contains(err.Error(), name) {
+			t.Fatalf("testCase %s need error with %s but got %s", expr, name, err)
+		}
+	}
+	if _, _, err := StdExecutor.DoExpr("ts > now('-1h') && ts <= now()", "", ""); err != nil {
+		t.Fatal(err)
+	}
+}
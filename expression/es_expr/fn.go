@@ -3,7 +3,9 @@ package es_expr
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/jummyliu/pkg/expression"
 	"github.com/jummyliu/pkg/expression/token"
 )
 
@@ -14,27 +16,45 @@ var DefaultFnMap = map[string]map[token.Token]ConditionFn{
 		token.NUM:    equal[float64],
 		token.BOOL:   equal[bool],
 		token.STRING: equalStr,
+		token.NULL:   notExists,
+		token.FIELD:  fieldCompare("=="),
+		token.DATE:   equalDate,
 	},
 	"!=": {
 		token.NUM:    unEqual[float64],
 		token.BOOL:   unEqual[bool],
 		token.STRING: unEqualStr,
+		token.NULL:   exists,
+		token.FIELD:  fieldCompare("!="),
+		token.DATE:   unEqualDate,
 	},
 	">=": {
 		token.NUM:    gte[float64],
 		token.STRING: gte[string],
+		token.FIELD:  fieldCompare(">="),
+		token.FUNC:   gte[string],
+		token.DATE:   dateRange("gte"),
 	},
 	"<=": {
 		token.NUM:    lte[float64],
 		token.STRING: lte[string],
+		token.FIELD:  fieldCompare("<="),
+		token.FUNC:   lte[string],
+		token.DATE:   dateRange("lte"),
 	},
 	">": {
 		token.NUM:    gt[float64],
-		token.STRING: lt[string],
+		token.STRING: gt[string],
+		token.FIELD:  fieldCompare(">"),
+		token.FUNC:   gt[string],
+		token.DATE:   dateRange("gt"),
 	},
 	"<": {
 		token.NUM:    lt[float64],
 		token.STRING: lt[string],
+		token.FIELD:  fieldCompare("<"),
+		token.FUNC:   lt[string],
+		token.DATE:   dateRange("lt"),
 	},
 	"contains": {
 		token.STRING: contains,
@@ -54,19 +74,112 @@ var DefaultFnMap = map[string]map[token.Token]ConditionFn{
 	"unEndsWith": {
 		token.STRING: unEndsWith,
 	},
+	"iequals": {
+		token.STRING: equalStr,
+	},
+	"icontains": {
+		token.STRING: contains,
+	},
+	"istartsWith": {
+		token.STRING: startsWith,
+	},
+	"iendsWith": {
+		token.STRING: endsWith,
+	},
+	"like": {
+		token.STRING: like,
+	},
+	"notLike": {
+		token.STRING: notLike,
+	},
 	"reg": {
 		token.STRING: reg,
 	},
 	"in": {
 		token.STRING: in,
+		token.ARRAY:  inArray,
 	},
 	"notIn": {
 		token.STRING: notIn,
+		token.ARRAY:  notInArray,
+	},
+	"between": {
+		token.ARRAY: between,
+	},
+	"inCidr": {
+		token.STRING: inCidr,
+		token.ARRAY:  inCidr,
+	},
+	"notInCidr": {
+		token.STRING: notInCidr,
+		token.ARRAY:  notInCidr,
+	},
+	"is": {
+		token.NULL: notExists,
+	},
+	"isNot": {
+		token.NULL: exists,
+	},
+	"exists": {
+		token.NULL: exists,
+	},
+	"notExists": {
+		token.NULL: notExists,
 	},
 	"&": {},
 	"|": {},
 }
 
+// fieldCompare 字段与字段比较，使用 script 查询，value 为字段名
+//
+//	任意一个字段没有值时，不匹配
+func fieldCompare(op string) ConditionFn {
+	return func(key string, value any) map[string]any {
+		field, ok := value.(string)
+		if !ok {
+			return nil
+		}
+		return map[string]any{
+			"script": map[string]any{
+				"script": map[string]any{
+					"source": fmt.Sprintf(
+						"doc[params.left].size() != 0 && doc[params.right].size() != 0 && doc[params.left].value %s doc[params.right].value",
+						op,
+					),
+					"params": map[string]any{
+						"left":  key,
+						"right": field,
+					},
+				},
+			},
+		}
+	}
+}
+
+// exists 字段存在，并且值不为 null
+func exists(key string, value any) map[string]any {
+	return map[string]any{
+		"exists": map[string]any{
+			"field": key,
+		},
+	}
+}
+
+// notExists 字段不存在，或者值为 null
+func notExists(key string, value any) map[string]any {
+	return map[string]any{
+		"bool": map[string]any{
+			"must_not": []map[string]any{
+				{
+					"exists": map[string]any{
+						"field": key,
+					},
+				},
+			},
+		},
+	}
+}
+
 func equal[T comparable](key string, value any) map[string]any {
 	val, ok := value.(T)
 	if !ok {
@@ -197,6 +310,107 @@ func lt[T int64 | float64 | string](key string, value any) map[string]any {
 	}
 }
 
+// dateBound 在生成查询时解析日期，与 cond_expr、SQL 的取整一致，相对日期以生成查询的时间为准
+//
+//	roundUp 为日期的取整方向，gt 和 lte 向上取整到周期的最后一毫秒
+func dateBound(date token.Date, now time.Time, roundUp bool) (string, bool) {
+	t, err := date.Time(now, roundUp)
+	if err != nil {
+		return "", false
+	}
+	return t.Format(time.RFC3339Nano), true
+}
+
+// dateRange 日期范围查询，使用解析后的日期
+func dateRange(op string) ConditionFn {
+	return func(key string, value any) map[string]any {
+		val, ok := value.(token.Date)
+		if !ok {
+			return nil
+		}
+		bound, ok := dateBound(val, time.Now(), op == "gt" || op == "lte")
+		if !ok {
+			return nil
+		}
+		return map[string]any{
+			"range": map[string]any{
+				key: map[string]any{
+					op: bound,
+				},
+			},
+		}
+	}
+}
+
+// equalDate 在取整的周期内，如：ts == now/d 为今天，ts == 2024-01-15 为当天
+func equalDate(key string, value any) map[string]any {
+	val, ok := value.(token.Date)
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	from, ok := dateBound(val, now, false)
+	if !ok {
+		return nil
+	}
+	to, ok := dateBound(val, now, true)
+	if !ok {
+		return nil
+	}
+	return map[string]any{
+		"range": map[string]any{
+			key: map[string]any{
+				"gte": from,
+				"lte": to,
+			},
+		},
+	}
+}
+
+// unEqualDate 不在取整的周期内
+func unEqualDate(key string, value any) map[string]any {
+	query := equalDate(key, value)
+	if query == nil {
+		return nil
+	}
+	return map[string]any{
+		"bool": map[string]any{
+			"must_not": []map[string]any{
+				query,
+			},
+		},
+	}
+}
+
+// between 范围查询，包含两端，日期的结束值向上取整
+func between(key string, value any) map[string]any {
+	val, ok := value.([]any)
+	if !ok || len(val) != 2 {
+		return nil
+	}
+	now := time.Now()
+	rangeQuery := map[string]any{}
+	for i, op := range []string{"gte", "lte"} {
+		switch item := val[i].(type) {
+		case token.Date:
+			bound, ok := dateBound(item, now, op == "lte")
+			if !ok {
+				return nil
+			}
+			rangeQuery[op] = bound
+		case float64, string:
+			rangeQuery[op] = item
+		default:
+			return nil
+		}
+	}
+	return map[string]any{
+		"range": map[string]any{
+			key: rangeQuery,
+		},
+	}
+}
+
 func contains(key string, value any) map[string]any {
 	val, ok := value.(string)
 	if !ok {
@@ -311,6 +525,38 @@ func unEndsWith(key string, value any) map[string]any {
 	}
 }
 
+// like 通配符匹配，忽略大小写，ES 的 wildcard 查询与表达式的通配符语法一致
+func like(key string, value any) map[string]any {
+	val, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	key = fmt.Sprintf("%s.keyword", key)
+	return map[string]any{
+		"wildcard": map[string]any{
+			key: map[string]any{
+				"case_insensitive": true,
+				"value":            val,
+			},
+		},
+	}
+}
+
+// notLike 通配符不匹配
+func notLike(key string, value any) map[string]any {
+	query := like(key, value)
+	if query == nil {
+		return nil
+	}
+	return map[string]any{
+		"bool": map[string]any{
+			"must_not": []map[string]any{
+				query,
+			},
+		},
+	}
+}
+
 func reg(key string, value any) map[string]any {
 	val, ok := value.(string)
 	if !ok {
@@ -353,3 +599,73 @@ func notIn(key string, value any) map[string]any {
 		},
 	}
 }
+
+func inArray(key string, value any) map[string]any {
+	val, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+	return map[string]any{
+		"terms": map[string]any{
+			key: val,
+		},
+	}
+}
+
+func notInArray(key string, value any) map[string]any {
+	val, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+	return map[string]any{
+		"bool": map[string]any{
+			"must_not": []map[string]any{
+				{
+					"terms": map[string]any{
+						key: val,
+					},
+				},
+			},
+		},
+	}
+}
+
+// inCidr ip 字段的 CIDR 查询，多个 CIDR 时使用 terms
+func inCidr(key string, value any) map[string]any {
+	prefixes, err := expression.ParseCidrs(value)
+	if err != nil {
+		// LexParse、builder 已校验，无法解析时不匹配任何数据，不能退化为查询全部
+		return map[string]any{"match_none": map[string]any{}}
+	}
+	values := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		values = append(values, prefix.String())
+	}
+	if len(values) == 1 {
+		return map[string]any{
+			"term": map[string]any{
+				key: values[0],
+			},
+		}
+	}
+	return map[string]any{
+		"terms": map[string]any{
+			key: values,
+		},
+	}
+}
+
+// notInCidr 不在任何一个 CIDR 范围内
+func notInCidr(key string, value any) map[string]any {
+	if _, err := expression.ParseCidrs(value); err != nil {
+		return map[string]any{"match_none": map[string]any{}}
+	}
+	query := inCidr(key, value)
+	return map[string]any{
+		"bool": map[string]any{
+			"must_not": []map[string]any{
+				query,
+			},
+		},
+	}
+}
@@ -0,0 +1,137 @@
+package es_expr
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jummyliu/pkg/datetime"
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+// Func 表达式中的函数，根据参数计算出查询中使用的值，不支持时返回 false
+//
+//	函数只能出现在条件右侧，参数只能是值
+type Func func(args []any) (value any, ok bool)
+
+// DefaultFuncMap 默认函数
+var DefaultFuncMap = map[string]Func{
+	"now": now,
+}
+
+// dateMathUnits 日期计算单位，从大到小
+var dateMathUnits = []struct {
+	unit string
+	d    time.Duration
+}{
+	{"w", 7 * 24 * time.Hour},
+	{"d", 24 * time.Hour},
+	{"h", time.Hour},
+	{"m", time.Minute},
+	{"s", time.Second},
+}
+
+// now 转换为日期计算表达式，now('-1h') => now-1h，now('-1.5h') => now-90m
+func now(args []any) (value any, ok bool) {
+	if len(args) == 0 {
+		return "now", true
+	}
+	if len(args) != 1 {
+		return nil, false
+	}
+	offset, ok := args[0].(string)
+	if !ok {
+		return nil, false
+	}
+	d, err := datetime.ParseDuration(offset)
+	if err != nil {
+		return nil, false
+	}
+	d = d.Truncate(time.Second)
+	if d == 0 {
+		return "now", true
+	}
+	sign := "+"
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	for _, item := range dateMathUnits {
+		if d%item.d == 0 {
+			return fmt.Sprintf("now%s%d%s", sign, d/item.d, item.unit), true
+		}
+	}
+	return nil, false
+}
+
+// call 计算函数调用的值
+func (e *Executor) call(call *expression.AstNode) (value any, ok bool) {
+	name, _ := call.Value.(string)
+	fn, ok := e.FuncMap[name]
+	if !ok {
+		return nil, false
+	}
+	args := make([]any, 0, len(call.Args))
+	for _, item := range call.Args {
+		switch item.Type {
+		case token.FUNC:
+			v, ok := e.call(item)
+			if !ok {
+				return nil, false
+			}
+			args = append(args, v)
+		case token.IDENT:
+			// 不支持字段参数
+			return nil, false
+		default:
+			args = append(args, item.Value)
+		}
+	}
+	return fn(args)
+}
+
+// checkFunc 检查表达式中的函数调用，存在不支持的函数时返回错误
+//
+//	ES 的函数只能出现在条件右侧，并且能计算出值；DoAst 遇到不支持的函数时返回 nil，容易被当作查询全部
+func (e *Executor) checkFunc(ast *expression.AstNode) error {
+	if ast == nil {
+		return nil
+	}
+	switch ast.Type {
+	case token.OPERATOR:
+		if err := e.checkFunc(ast.Left); err != nil {
+			return err
+		}
+		return e.checkFunc(ast.Right)
+	case token.NOT:
+		return e.checkFunc(ast.Left)
+	case token.QUANT:
+		return e.checkFunc(ast.Right)
+	case token.CONDITION:
+		if ast.Left != nil && ast.Left.Type == token.FUNC {
+			return fmt.Errorf("unsupported function on the left side: %v", ast.Left.Value)
+		}
+		if ast.Right != nil && ast.Right.Type == token.FUNC {
+			if _, ok := e.call(ast.Right); !ok {
+				return fmt.Errorf("unsupported function: %s", e.unsupportedFunc(ast.Right))
+			}
+		}
+	}
+	return nil
+}
+
+// unsupportedFunc 函数调用中第一个不支持的函数名，参数都支持时为 call 本身
+func (e *Executor) unsupportedFunc(call *expression.AstNode) string {
+	name, _ := call.Value.(string)
+	if _, ok := e.FuncMap[name]; !ok {
+		return name
+	}
+	for _, item := range call.Args {
+		if item.Type != token.FUNC {
+			continue
+		}
+		if _, ok := e.call(item); !ok {
+			return e.unsupportedFunc(item)
+		}
+	}
+	return name
+}
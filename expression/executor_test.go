@@ -0,0 +1,903 @@
+package expression_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jummyliu/pkg/datetime"
+	"github.com/jummyliu/pkg/db/types"
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/clickhouse_expr"
+	"github.com/jummyliu/pkg/expression/cond_expr"
+	"github.com/jummyliu/pkg/expression/es_expr"
+	"github.com/jummyliu/pkg/expression/mysql_expr"
+	"github.com/jummyliu/pkg/expression/mysql_json_expr"
+	"github.com/jummyliu/pkg/expression/mysql_mixed_expr"
+	"github.com/jummyliu/pkg/expression/sqlite_expr"
+	"github.com/jummyliu/pkg/expression/sqlite_json_expr"
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+// executorResult 执行所有执行器，返回各自的结果
+func executorResult(ast *expression.AstNode, m map[string]any) map[string]any {
+	condResult, _ := cond_expr.StdExecutor.DoAst(m, ast, "", "")
+	esResult, _ := es_expr.StdExecutor.DoAst(ast, "", "")
+	mysqlSQL, mysqlParams, _ := mysql_expr.StdExecutor.DoAst(ast, "", "")
+	jsonSQL, jsonParams, _ := mysql_json_expr.StdExecutor.DoAst(ast, "", "", "attr")
+	mixedSQL, mixedParams, _ := mysql_mixed_expr.StdExecutor.DoAst(ast, "", "")
+	chSQL, chParams, _ := clickhouse_expr.StdExecutor.DoAst(ast, "", "")
+	return map[string]any{
+		"cond_expr":        condResult,
+		"es_expr":          esResult,
+		"mysql_expr":       []any{mysqlSQL, mysqlParams},
+		"mysql_json_expr":  []any{jsonSQL, jsonParams},
+		"mysql_mixed_expr": []any{mixedSQL, mixedParams},
+		"clickhouse_expr":  []any{chSQL, chParams},
+	}
+}
+
+func parseAst(t *testing.T, expr string, opts ...expression.AstOption) *expression.AstNode {
+	lexTokens, err := expression.LexParse(expression.TokensRead(expr))
+	if err != nil {
+		t.Fatalf("testCase %s parse failure: %s", expr, err)
+	}
+	return expression.AstParse(lexTokens, opts...)
+}
+
+func TestExecutorPrecedence(t *testing.T) {
+	m := map[string]any{
+		"a": float64(1),
+		"b": float64(0),
+		"c": float64(0),
+		"d": float64(4),
+	}
+	testCases := []struct {
+		Expr     string
+		Explicit string
+		Opts     []expression.AstOption
+		Result   bool
+	}{
+		{
+			Expr:     "a == 1 || b == 2 && c == 3",
+			Explicit: "a == 1 || (b == 2 && c == 3)",
+			Result:   true,
+		},
+		{
+			Expr:     "b == 2 && c == 3 || d == 4",
+			Explicit: "(b == 2 && c == 3) || d == 4",
+			Result:   true,
+		},
+		{
+			Expr:     "a == 1 || b == 2 && c == 3 || d == 5",
+			Explicit: "(a == 1 || (b == 2 && c == 3)) || d == 5",
+			Result:   true,
+		},
+		{
+			Expr:     "!(a == 1) || b == 0 && !c == 3",
+			Explicit: "!(a == 1) || (b == 0 && !(c == 3))",
+			Result:   true,
+		},
+		{
+			Expr:     "a == 1 || b == 2 && c == 3",
+			Explicit: "(a == 1 || b == 2) && c == 3",
+			Opts:     []expression.AstOption{expression.WithLeftAssociative()},
+			Result:   false,
+		},
+	}
+	for _, testCase := range testCases {
+		result := executorResult(parseAst(t, testCase.Expr, testCase.Opts...), m)
+		explicit := executorResult(parseAst(t, testCase.Explicit), m)
+		for name, item := range result {
+			if !reflect.DeepEqual(item, explicit[name]) {
+				t.Fatalf("testCase %s executor %s need %v but got %v", testCase.Expr, name, explicit[name], item)
+			}
+		}
+		if result["cond_expr"] != testCase.Result {
+			t.Fatalf("testCase %s need %v but got %v", testCase.Expr, testCase.Result, result["cond_expr"])
+		}
+	}
+}
+
+func TestExecutorArray(t *testing.T) {
+	m := map[string]any{
+		"port": float64(443),
+		"user": "root",
+	}
+	ast := parseAst(t, "port in [80, 443] && user notIn ['admin', 'guest']")
+	result := executorResult(ast, m)
+	need := map[string]any{
+		"cond_expr": true,
+		"es_expr": map[string]any{
+			"bool": map[string]any{
+				"must": []map[string]any{
+					{"terms": map[string]any{"port": []any{float64(80), float64(443)}}},
+					{"bool": map[string]any{"must_not": []map[string]any{
+						{"terms": map[string]any{"user": []any{"admin", "guest"}}},
+					}}},
+				},
+			},
+		},
+		"mysql_expr": []any{
+			"( port IN (?, ?) AND user NOT IN (?, ?) )",
+			[]any{float64(80), float64(443), "admin", "guest"},
+		},
+		"mysql_json_expr": []any{
+			"( ( JSON_CONTAINS(attr, CONCAT('', ?, ''), CONCAT('$.', '\"', ?, '\"', '')) OR JSON_CONTAINS(attr, CONCAT('', ?, ''), CONCAT('$.', '\"', ?, '\"', '')) ) AND " +
+				"( JSON_CONTAINS(attr, CONCAT('\"', ?, '\"'), CONCAT('$.', '\"', ?, '\"', '')) = 0 AND JSON_CONTAINS(attr, CONCAT('\"', ?, '\"'), CONCAT('$.', '\"', ?, '\"', '')) = 0 ) )",
+			[]any{float64(80), "port", float64(443), "port", "admin", "user", "guest", "user"},
+		},
+		"mysql_mixed_expr": []any{
+			"( port IN (?, ?) AND user NOT IN (?, ?) )",
+			[]any{float64(80), float64(443), "admin", "guest"},
+		},
+		"clickhouse_expr": []any{
+			"( port IN (?, ?) AND user NOT IN (?, ?) )",
+			[]any{float64(80), float64(443), "admin", "guest"},
+		},
+	}
+	for name, item := range result {
+		if !reflect.DeepEqual(item, need[name]) {
+			t.Fatalf("executor %s need %#v but got %#v", name, need[name], item)
+		}
+	}
+}
+
+func TestExecutorNull(t *testing.T) {
+	m := map[string]any{
+		"deleted": nil,
+	}
+	ast := parseAst(t, "deleted == null && name notExists || ip exists")
+	result := executorResult(ast, m)
+	need := map[string]any{
+		"cond_expr": true,
+		"es_expr": map[string]any{
+			"bool": map[string]any{
+				"should": []map[string]any{
+					{"bool": map[string]any{"must": []map[string]any{
+						{"bool": map[string]any{"must_not": []map[string]any{{"exists": map[string]any{"field": "deleted"}}}}},
+						{"bool": map[string]any{"must_not": []map[string]any{{"exists": map[string]any{"field": "name"}}}}},
+					}}},
+					{"exists": map[string]any{"field": "ip"}},
+				},
+			},
+		},
+		"mysql_expr": []any{
+			"( ( deleted IS NULL AND name IS NULL ) OR ip IS NOT NULL )",
+			[]any(nil),
+		},
+		"mysql_json_expr": []any{
+			"( ( COALESCE(JSON_TYPE(JSON_EXTRACT(attr, CONCAT('$.', '\"', ?, '\"', ''))), 'NULL') = 'NULL' AND " +
+				"JSON_CONTAINS_PATH(attr, 'one', CONCAT('$.', '\"', ?, '\"', '')) = 0 ) OR " +
+				"JSON_CONTAINS_PATH(attr, 'one', CONCAT('$.', '\"', ?, '\"', '')) = 1 )",
+			[]any{"deleted", "name", "ip"},
+		},
+		"mysql_mixed_expr": []any{
+			"( ( deleted IS NULL AND name IS NULL ) OR ip IS NOT NULL )",
+			[]any(nil),
+		},
+		"clickhouse_expr": []any{
+			"( ( deleted IS NULL AND name IS NULL ) OR ip IS NOT NULL )",
+			[]any(nil),
+		},
+	}
+	for name, item := range result {
+		if !reflect.DeepEqual(item, need[name]) {
+			t.Fatalf("executor %s need %#v but got %#v", name, need[name], item)
+		}
+	}
+}
+
+func TestExecutorField(t *testing.T) {
+	m := map[string]any{
+		"bytes_in":  float64(100),
+		"bytes_out": float64(200),
+		"src_ip":    "10.0.0.1",
+		"dst_ip":    "10.0.0.2",
+	}
+	ast := parseAst(t, "bytes_out > $bytes_in && src_ip != $dst_ip")
+	result := executorResult(ast, m)
+	script := func(op, left, right string) map[string]any {
+		return map[string]any{
+			"script": map[string]any{
+				"script": map[string]any{
+					"source": "doc[params.left].size() != 0 && doc[params.right].size() != 0 && doc[params.left].value " + op + " doc[params.right].value",
+					"params": map[string]any{"left": left, "right": right},
+				},
+			},
+		}
+	}
+	need := map[string]any{
+		"cond_expr": true,
+		"es_expr": map[string]any{
+			"bool": map[string]any{
+				"must": []map[string]any{
+					script(">", "bytes_out", "bytes_in"),
+					script("!=", "src_ip", "dst_ip"),
+				},
+			},
+		},
+		"mysql_expr": []any{
+			"( bytes_out > bytes_in AND src_ip != dst_ip )",
+			[]any(nil),
+		},
+		"mysql_json_expr": []any{
+			"( JSON_EXTRACT(attr, CONCAT('$.', '\"', ?, '\"', '')) > JSON_EXTRACT(attr, CONCAT('$.', '\"', ?, '\"', '')) AND " +
+				"JSON_EXTRACT(attr, CONCAT('$.', '\"', ?, '\"', '')) != JSON_EXTRACT(attr, CONCAT('$.', '\"', ?, '\"', '')) )",
+			[]any{"bytes_out", "bytes_in", "src_ip", "dst_ip"},
+		},
+		"mysql_mixed_expr": []any{
+			"( bytes_out > bytes_in AND src_ip != dst_ip )",
+			[]any(nil),
+		},
+		"clickhouse_expr": []any{
+			"( bytes_out > bytes_in AND src_ip != dst_ip )",
+			[]any(nil),
+		},
+	}
+	for name, item := range result {
+		if !reflect.DeepEqual(item, need[name]) {
+			t.Fatalf("executor %s need %#v but got %#v", name, need[name], item)
+		}
+	}
+
+	// KeyMap 映射，mixed 中同一个 json 字段的两个属性
+	executor := mysql_mixed_expr.New(nil, nil, map[string]string{"in": "attr.bytes_in"})
+	sql, params, keys := executor.DoAst(parseAst(t, "attr.bytes_out > $in && plain == $attr.x"), "", "")
+	if sql != "" {
+		t.Fatalf("mixed need empty sql for json/plain comparison but got %s", sql)
+	}
+	sql, params, keys = executor.DoAst(parseAst(t, "attr.bytes_out > $in"), "", "")
+	if sql != "JSON_EXTRACT(attr, CONCAT('$.', '\"', ?, '\"', '')) > JSON_EXTRACT(attr, CONCAT('$.', '\"', ?, '\"', ''))" ||
+		!reflect.DeepEqual(params, []any{"bytes_out", "bytes_in"}) ||
+		!reflect.DeepEqual(keys, []string{"attr.bytes_out", "attr.bytes_in"}) {
+		t.Fatalf("mixed got %s %v %v", sql, params, keys)
+	}
+}
+
+func TestExecutorFunc(t *testing.T) {
+	m := map[string]any{
+		"user": "Admin",
+		"tags": []any{"a", "b", "c", "d"},
+		"ts":   datetime.FormatDate(time.Now()),
+	}
+	testCases := []struct {
+		Expr string
+		Need map[string]any
+	}{
+		{
+			Expr: "lower(user) == 'admin' && len(tags) > 3",
+			Need: map[string]any{
+				"cond_expr": true,
+				"es_expr":   map[string]any(nil),
+				"mysql_expr": []any{
+					"( LOWER(user) = ? AND CHAR_LENGTH(tags) > ? )",
+					[]any{"admin", float64(3)},
+				},
+				"mysql_json_expr":  []any{"", []any(nil)},
+				"mysql_mixed_expr": []any{"", []any(nil)},
+				"clickhouse_expr": []any{
+					"( lower(user) = ? AND length(tags) > ? )",
+					[]any{"admin", float64(3)},
+				},
+			},
+		},
+		{
+			Expr: "ts >= now('-1.5h') && ts <= now()",
+			Need: map[string]any{
+				"cond_expr": true,
+				"es_expr": map[string]any{
+					"bool": map[string]any{
+						"must": []map[string]any{
+							{"range": map[string]any{"ts": map[string]any{"gte": "now-90m"}}},
+							{"range": map[string]any{"ts": map[string]any{"lte": "now"}}},
+						},
+					},
+				},
+				"mysql_expr": []any{
+					"( ts >= DATE_ADD(NOW(), INTERVAL ? SECOND) AND ts <= NOW() )",
+					[]any{int64(-5400)},
+				},
+				"mysql_json_expr": []any{"", []any(nil)},
+				"mysql_mixed_expr": []any{
+					"( ts >= DATE_ADD(NOW(), INTERVAL ? SECOND) AND ts <= NOW() )",
+					[]any{int64(-5400)},
+				},
+				"clickhouse_expr": []any{
+					"( ts >= addSeconds(now(), ?) AND ts <= now() )",
+					[]any{int64(-5400)},
+				},
+			},
+		},
+		{
+			// 左侧函数调用的参数在值的参数之前
+			Expr: "now('-1h') >= '2000-01-01 00:00:00'",
+			Need: map[string]any{
+				"cond_expr": true,
+				"es_expr":   map[string]any(nil),
+				"mysql_expr": []any{
+					"DATE_ADD(NOW(), INTERVAL ? SECOND) >= ?",
+					[]any{int64(-3600), "2000-01-01 00:00:00"},
+				},
+				"mysql_json_expr":  []any{"", []any(nil)},
+				"mysql_mixed_expr": []any{"", []any(nil)},
+				"clickhouse_expr": []any{
+					"addSeconds(now(), ?) >= ?",
+					[]any{int64(-3600), "2000-01-01 00:00:00"},
+				},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		result := executorResult(parseAst(t, testCase.Expr), m)
+		for name, item := range result {
+			if !reflect.DeepEqual(item, testCase.Need[name]) {
+				t.Fatalf("testCase %s executor %s need %#v but got %#v", testCase.Expr, name, testCase.Need[name], item)
+			}
+		}
+	}
+}
+
+func TestExecutorUnsupportedFunc(t *testing.T) {
+	doExprs := map[string]func(expr string) error{
+		"mysql_expr": func(expr string) error {
+			_, _, _, err := mysql_expr.StdExecutor.DoExpr(expr, "", "")
+			return err
+		},
+		"clickhouse_expr": func(expr string) error {
+			_, _, _, err := clickhouse_expr.StdExecutor.DoExpr(expr, "", "")
+			return err
+		},
+		"sqlite_expr": func(expr string) error {
+			_, _, _, err := sqlite_expr.StdExecutor.DoExpr(expr, "", "")
+			return err
+		},
+		"mysql_json_expr": func(expr string) error {
+			_, _, _, err := mysql_json_expr.StdExecutor.DoExpr(expr, "", "", "attr")
+			return err
+		},
+		"mysql_mixed_expr": func(expr string) error {
+			_, _, _, err := mysql_mixed_expr.StdExecutor.DoExpr(expr, "", "")
+			return err
+		},
+		"sqlite_json_expr": func(expr string) error {
+			_, _, _, err := sqlite_json_expr.StdExecutor.DoExpr(expr, "", "", "attr")
+			return err
+		},
+	}
+	// 错误中的函数名，json 执行器不支持任何函数，为最外层的函数名
+	testCases := []struct {
+		Expr string
+		Func string
+		JSON string
+	}{
+		{Expr: "a == 1 || foo(b) == 1", Func: "foo", JSON: "foo"},
+		{Expr: "a == 1 || b > lower(foo(c))", Func: "foo", JSON: "lower"},
+		{Expr: "!(a == 1 || len(b, c) > 1)", Func: "len", JSON: "len"},
+		{Expr: "a == 1 && ts > now('abc')", Func: "now", JSON: "now"},
+	}
+	for name, doExpr := range doExprs {
+		for _, testCase := range testCases {
+			fn := testCase.Func
+			if strings.HasSuffix(name, "json_expr") || name == "mysql_mixed_expr" {
+				fn = testCase.JSON
+			}
+			if err := doExpr(testCase.Expr); err == nil || err.Error() != "unsupported function: "+fn {
+				t.Fatalf("testCase %s executor %s need unsupported function %s but got %v", testCase.Expr, name, fn, err)
+			}
+		}
+	}
+	// 数组元素条件中的函数调用
+	if err := doExprs["mysql_json_expr"]("any(p, a == 1 || foo(b) == 1)"); err == nil || err.Error() != "unsupported function: foo" {
+		t.Fatalf("need unsupported function foo in any but got %v", err)
+	}
+	// json 执行器不支持函数调用，其他执行器支持默认函数
+	for name, doExpr := range doExprs {
+		err := doExpr("a == 1 || lower(b) == 'x'")
+		switch name {
+		case "mysql_json_expr", "mysql_mixed_expr", "sqlite_json_expr":
+			if err == nil || err.Error() != "unsupported function: lower" {
+				t.Fatalf("executor %s need unsupported function lower but got %v", name, err)
+			}
+		default:
+			if err != nil {
+				t.Fatalf("executor %s DoExpr failure: %s", name, err)
+			}
+		}
+	}
+}
+
+func TestExecutorDate(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	end := time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC)
+	mid := time.Date(2024, 1, 15, 0, 0, 0, 0, time.Local)
+	midEnd := time.Date(2024, 1, 15, 23, 59, 59, 999e6, time.Local)
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+	dayEnd := time.Date(2024, 1, 10, 23, 59, 59, 999e6, time.Local)
+	// es_expr 的日期在生成查询时解析，与其他执行器的取整一致
+	esDate := func(t time.Time) string { return t.Format(time.RFC3339Nano) }
+	m := map[string]any{
+		"ts":      time.Date(2024, 1, 10, 8, 0, 0, 0, time.Local),
+		"created": types.Time(time.Now().Add(-time.Minute)),
+		"port":    float64(443),
+	}
+	// mysql_json_expr 的日期转换为 DATETIME 比较
+	jsonDate := "CAST(JSON_UNQUOTE(JSON_EXTRACT(attr, CONCAT('$.', '\"', ?, '\"', ''))) AS DATETIME(3))"
+	testCases := []struct {
+		Expr string
+		Need map[string]any
+	}{
+		{
+			Expr: "ts between [2024-01-01, 2024-01-31T23:59:59Z] && ts != 2024-01-15",
+			Need: map[string]any{
+				"cond_expr": true,
+				"es_expr": map[string]any{
+					"bool": map[string]any{
+						"must": []map[string]any{
+							{"range": map[string]any{"ts": map[string]any{"gte": esDate(start), "lte": esDate(end)}}},
+							{"bool": map[string]any{"must_not": []map[string]any{
+								{"range": map[string]any{"ts": map[string]any{"gte": esDate(mid), "lte": esDate(midEnd)}}},
+							}}},
+						},
+					},
+				},
+				"mysql_expr": []any{
+					"( ts BETWEEN ? AND ? AND ts NOT BETWEEN ? AND ? )",
+					[]any{start, end, mid, midEnd},
+				},
+				"mysql_json_expr": []any{
+					"( " + jsonDate + " BETWEEN ? AND ? AND " + jsonDate + " NOT BETWEEN ? AND ? )",
+					[]any{"ts", start, end, "ts", mid, midEnd},
+				},
+				"mysql_mixed_expr": []any{
+					"( ts BETWEEN ? AND ? AND ts NOT BETWEEN ? AND ? )",
+					[]any{start, end, mid, midEnd},
+				},
+				"clickhouse_expr": []any{
+					"( ts BETWEEN ? AND ? AND ts NOT BETWEEN ? AND ? )",
+					[]any{start, end, mid, midEnd},
+				},
+			},
+		},
+		{
+			Expr: "ts >= 2024-01-10T08:00 && port between [400, 500]",
+			Need: map[string]any{
+				"cond_expr": true,
+				"es_expr": map[string]any{
+					"bool": map[string]any{
+						"must": []map[string]any{
+							{"range": map[string]any{"ts": map[string]any{"gte": esDate(time.Date(2024, 1, 10, 8, 0, 0, 0, time.Local))}}},
+							{"range": map[string]any{"port": map[string]any{"gte": float64(400), "lte": float64(500)}}},
+						},
+					},
+				},
+				"mysql_expr": []any{
+					"( ts >= ? AND port BETWEEN ? AND ? )",
+					[]any{time.Date(2024, 1, 10, 8, 0, 0, 0, time.Local), float64(400), float64(500)},
+				},
+				"mysql_json_expr": []any{
+					"( " + jsonDate + " >= ? AND JSON_EXTRACT(attr, CONCAT('$.', '\"', ?, '\"', '')) COLLATE utf8mb4_0900_ai_ci BETWEEN ? AND ? )",
+					[]any{"ts", time.Date(2024, 1, 10, 8, 0, 0, 0, time.Local), "port", float64(400), float64(500)},
+				},
+				"mysql_mixed_expr": []any{
+					"( ts >= ? AND port BETWEEN ? AND ? )",
+					[]any{time.Date(2024, 1, 10, 8, 0, 0, 0, time.Local), float64(400), float64(500)},
+				},
+				"clickhouse_expr": []any{
+					"( ts >= ? AND port BETWEEN ? AND ? )",
+					[]any{time.Date(2024, 1, 10, 8, 0, 0, 0, time.Local), float64(400), float64(500)},
+				},
+			},
+		},
+		{
+			// 只有日期的 ISO-8601 表示一整天，== 和 between 包含当天，<= 包含当天的最后一毫秒
+			Expr: "ts == 2024-01-10 && ts <= 2024-01-10 && ts between [2024-01-10, 2024-01-10]",
+			Need: map[string]any{
+				"cond_expr": true,
+				"es_expr": map[string]any{
+					"bool": map[string]any{
+						"must": []map[string]any{
+							{"bool": map[string]any{"must": []map[string]any{
+								{"range": map[string]any{"ts": map[string]any{"gte": esDate(day), "lte": esDate(dayEnd)}}},
+								{"range": map[string]any{"ts": map[string]any{"lte": esDate(dayEnd)}}},
+							}}},
+							{"range": map[string]any{"ts": map[string]any{"gte": esDate(day), "lte": esDate(dayEnd)}}},
+						},
+					},
+				},
+				"mysql_expr": []any{
+					"( ( ts BETWEEN ? AND ? AND ts <= ? ) AND ts BETWEEN ? AND ? )",
+					[]any{day, dayEnd, dayEnd, day, dayEnd},
+				},
+				"mysql_json_expr": []any{
+					"( ( " + jsonDate + " BETWEEN ? AND ? AND " + jsonDate + " <= ? ) AND " + jsonDate + " BETWEEN ? AND ? )",
+					[]any{"ts", day, dayEnd, "ts", dayEnd, "ts", day, dayEnd},
+				},
+				"mysql_mixed_expr": []any{
+					"( ( ts BETWEEN ? AND ? AND ts <= ? ) AND ts BETWEEN ? AND ? )",
+					[]any{day, dayEnd, dayEnd, day, dayEnd},
+				},
+				"clickhouse_expr": []any{
+					"( ( ts BETWEEN ? AND ? AND ts <= ? ) AND ts BETWEEN ? AND ? )",
+					[]any{day, dayEnd, dayEnd, day, dayEnd},
+				},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		result := executorResult(parseAst(t, testCase.Expr), m)
+		for name, item := range result {
+			if !reflect.DeepEqual(item, testCase.Need[name]) {
+				t.Fatalf("testCase %s executor %s need %#v but got %#v", testCase.Expr, name, testCase.Need[name], item)
+			}
+		}
+	}
+
+	// 相对日期在执行时计算，只比较 cond_expr 的结果
+	condCases := map[string]bool{
+		"created > now-15m && created < now && created == now/d":                    true,
+		"created between [now-1h, now] && created between [now/d, now/d]":           true,
+		"created <= now-1h || created > now || created != now/d":                    false,
+		"ts < now-1d/d && ts == 2024-01-10T08:00 && ts between ['2024-01-01', now]": true,
+		"port > now || port between [now-1d, now]":                                  false,
+	}
+	for expr, need := range condCases {
+		if result := executorResult(parseAst(t, expr), m)["cond_expr"]; result != need {
+			t.Fatalf("testCase %s cond_expr need %v but got %v", expr, need, result)
+		}
+	}
+}
+
+func TestExecutorMatch(t *testing.T) {
+	m := map[string]any{
+		"user": "root",
+		"host": "web-01.local",
+		"path": "/data/50%/log",
+	}
+	ast := parseAst(t, "user iequals 'ROOT' && host like 'Web-?1*' && path icontains '50%'")
+	result := executorResult(ast, m)
+	need := map[string]any{
+		"cond_expr": true,
+		"es_expr": map[string]any{
+			"bool": map[string]any{
+				"must": []map[string]any{
+					{"bool": map[string]any{"must": []map[string]any{
+						{"term": map[string]any{"user.keyword": map[string]any{"case_insensitive": true, "value": "ROOT"}}},
+						{"wildcard": map[string]any{"host.keyword": map[string]any{"case_insensitive": true, "value": "Web-?1*"}}},
+					}}},
+					{"wildcard": map[string]any{"path.keyword": map[string]any{"case_insensitive": true, "value": "*50%*"}}},
+				},
+			},
+		},
+		"mysql_expr": []any{
+			"( ( LOWER(user) = LOWER(?) AND LOWER(host) LIKE LOWER(?) ) AND LOWER(path) LIKE LOWER(?) )",
+			[]any{"ROOT", "Web-_1%", `%50\%%`},
+		},
+		"mysql_json_expr": []any{
+			"( ( LOWER(JSON_UNQUOTE(JSON_EXTRACT(attr, CONCAT('$.', '\"', ?, '\"', '')))) = LOWER(?) AND " +
+				"LOWER(JSON_UNQUOTE(JSON_EXTRACT(attr, CONCAT('$.', '\"', ?, '\"', '')))) LIKE LOWER(?) ) AND " +
+				"LOWER(JSON_UNQUOTE(JSON_EXTRACT(attr, CONCAT('$.', '\"', ?, '\"', '')))) LIKE LOWER(?) )",
+			[]any{"user", "ROOT", "host", "Web-_1%", "path", `%50\%%`},
+		},
+		"mysql_mixed_expr": []any{
+			"( ( LOWER(user) = LOWER(?) AND LOWER(host) LIKE LOWER(?) ) AND LOWER(path) LIKE LOWER(?) )",
+			[]any{"ROOT", "Web-_1%", `%50\%%`},
+		},
+		"clickhouse_expr": []any{
+			"( ( user ILIKE ? AND host ILIKE ? ) AND path ILIKE ? )",
+			[]any{"ROOT", "Web-_1%", `%50\%%`},
+		},
+	}
+	for name, item := range result {
+		if !reflect.DeepEqual(item, need[name]) {
+			t.Fatalf("executor %s need %#v but got %#v", name, need[name], item)
+		}
+	}
+}
+
+func TestExecutorCidr(t *testing.T) {
+	m := map[string]any{
+		"src_ip": "10.1.2.3",
+		"dst_ip": "fd00::1",
+	}
+	ast := parseAst(t, "src_ip inCidr '10.0.0.0/8' && dst_ip notInCidr ['192.168.0.0/16', '2001:db8::/32']")
+	result := executorResult(ast, m)
+	v4From, v4To := []byte{10, 0, 0, 0}, []byte{10, 255, 255, 255}
+	v6From := []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	v6To := []byte{0x20, 0x01, 0x0d, 0xb8, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	jsonKey := "CONCAT('$.', '\"', ?, '\"', '')"
+	jsonAddr := "INET6_ATON(JSON_UNQUOTE(JSON_EXTRACT(attr, " + jsonKey + ")))"
+	need := map[string]any{
+		"cond_expr": true,
+		"es_expr": map[string]any{
+			"bool": map[string]any{
+				"must": []map[string]any{
+					{"term": map[string]any{"src_ip": "10.0.0.0/8"}},
+					{"bool": map[string]any{"must_not": []map[string]any{
+						{"terms": map[string]any{"dst_ip": []string{"192.168.0.0/16", "2001:db8::/32"}}},
+					}}},
+				},
+			},
+		},
+		"mysql_expr": []any{
+			"( ( LENGTH(INET6_ATON(src_ip)) = ? AND INET6_ATON(src_ip) BETWEEN ? AND ? ) AND " +
+				"NOT ( ( LENGTH(INET6_ATON(dst_ip)) = ? AND INET6_ATON(dst_ip) BETWEEN ? AND ? ) OR ( LENGTH(INET6_ATON(dst_ip)) = ? AND INET6_ATON(dst_ip) BETWEEN ? AND ? ) ) )",
+			[]any{4, v4From, v4To, 4, []byte{192, 168, 0, 0}, []byte{192, 168, 255, 255}, 16, v6From, v6To},
+		},
+		"mysql_json_expr": []any{
+			"( ( LENGTH(" + jsonAddr + ") = ? AND " + jsonAddr + " BETWEEN ? AND ? ) AND " +
+				"NOT ( ( LENGTH(" + jsonAddr + ") = ? AND " + jsonAddr + " BETWEEN ? AND ? ) OR ( LENGTH(" + jsonAddr + ") = ? AND " + jsonAddr + " BETWEEN ? AND ? ) ) )",
+			[]any{
+				"src_ip", 4, "src_ip", v4From, v4To,
+				"dst_ip", 4, "dst_ip", []byte{192, 168, 0, 0}, []byte{192, 168, 255, 255},
+				"dst_ip", 16, "dst_ip", v6From, v6To,
+			},
+		},
+		"mysql_mixed_expr": []any{
+			"( ( LENGTH(INET6_ATON(src_ip)) = ? AND INET6_ATON(src_ip) BETWEEN ? AND ? ) AND " +
+				"NOT ( ( LENGTH(INET6_ATON(dst_ip)) = ? AND INET6_ATON(dst_ip) BETWEEN ? AND ? ) OR ( LENGTH(INET6_ATON(dst_ip)) = ? AND INET6_ATON(dst_ip) BETWEEN ? AND ? ) ) )",
+			[]any{4, v4From, v4To, 4, []byte{192, 168, 0, 0}, []byte{192, 168, 255, 255}, 16, v6From, v6To},
+		},
+		"clickhouse_expr": []any{
+			"( isIPAddressInRange(src_ip, ?) AND NOT ( isIPAddressInRange(dst_ip, ?) OR isIPAddressInRange(dst_ip, ?) ) )",
+			[]any{"10.0.0.0/8", "192.168.0.0/16", "2001:db8::/32"},
+		},
+	}
+	for name, item := range result {
+		if !reflect.DeepEqual(item, need[name]) {
+			t.Fatalf("executor %s need %#v but got %#v", name, need[name], item)
+		}
+	}
+}
+
+func TestExecutorIllegalCidr(t *testing.T) {
+	// 手工构造的语法树绕过了 LexParse、builder 的校验，无法解析的 CIDR 不能退化为查询全部
+	for _, op := range []string{"inCidr", "notInCidr"} {
+		ast := parseAst(t, "src_ip "+op+" '10.0.0.0/8'")
+		ast.Right.Value = "bad"
+		need := map[string]any{
+			"cond_expr":        false,
+			"es_expr":          map[string]any{"match_none": map[string]any{}},
+			"mysql_expr":       []any{"1 = 0", []any(nil)},
+			"mysql_json_expr":  []any{"1 = 0", []any(nil)},
+			"mysql_mixed_expr": []any{"1 = 0", []any(nil)},
+			"clickhouse_expr":  []any{"1 = 0", []any(nil)},
+		}
+		for name, item := range executorResult(ast, map[string]any{"src_ip": "1.1.1.1"}) {
+			if !reflect.DeepEqual(item, need[name]) {
+				t.Fatalf("%s executor %s need %#v but got %#v", op, name, need[name], item)
+			}
+		}
+	}
+}
+
+func TestExecutorQuant(t *testing.T) {
+	m := map[string]any{"ev": map[string]any{"processes": []any{
+		map[string]any{"name": "sh", "pid": float64(20), "args": []any{map[string]any{"v": "-c"}}},
+		map[string]any{"name": "bash", "pid": float64(2), "args": []any{map[string]any{"v": "id"}, map[string]any{"v": "-l"}}},
+	}}}
+	ast := parseAst(t, "any(ev.processes, name == 'sh') && all(ev.processes, pid > 1 && any(args, v startsWith '-'))")
+	result := executorResult(ast, m)
+	jsonSQL := func(attr string, path string) string {
+		return "( JSON_CONTAINS(" + attr + ", JSON_OBJECT(?, ?), " + path + ") AND " +
+			"NOT EXISTS ( SELECT 1 FROM JSON_TABLE(JSON_EXTRACT(" + attr + ", " + path + "), '$[*]' COLUMNS (elem JSON PATH '$')) AS jt0 WHERE NOT COALESCE(( " +
+			"JSON_EXTRACT(jt0.elem, CONCAT('$.', '\"', ?, '\"', '')) COLLATE utf8mb4_0900_ai_ci > ? AND " +
+			"EXISTS ( SELECT 1 FROM JSON_TABLE(JSON_EXTRACT(jt0.elem, CONCAT('$.', '\"', ?, '\"', '')), '$[*]' COLUMNS (elem JSON PATH '$')) AS jt1 WHERE " +
+			"JSON_SEARCH(jt1.elem, 'one', CONCAT(?, '%'), null, CONCAT('$.', '\"', ?, '\"', '')) IS NOT NULL ) ), FALSE) ) )"
+	}
+	need := map[string]any{
+		"cond_expr": true,
+		"es_expr": map[string]any{
+			"bool": map[string]any{
+				"must": []map[string]any{
+					{"nested": map[string]any{
+						"path":  "ev.processes",
+						"query": map[string]any{"term": map[string]any{"ev.processes.name.keyword": map[string]any{"case_insensitive": true, "value": "sh"}}},
+					}},
+					{"bool": map[string]any{"must_not": []map[string]any{
+						{"nested": map[string]any{
+							"path": "ev.processes",
+							"query": map[string]any{"bool": map[string]any{"must_not": []map[string]any{
+								{"bool": map[string]any{"must": []map[string]any{
+									{"range": map[string]any{"ev.processes.pid": map[string]any{"gt": float64(1)}}},
+									{"nested": map[string]any{
+										"path":  "ev.processes.args",
+										"query": map[string]any{"wildcard": map[string]any{"ev.processes.args.v.keyword": map[string]any{"case_insensitive": true, "value": "-*"}}},
+									}},
+								}}},
+							}}},
+						}},
+					}}},
+				},
+			},
+		},
+		"mysql_expr": []any{"", []any(nil)},
+		"mysql_json_expr": []any{
+			jsonSQL("attr", "CONCAT('$.', '\"', ?, '\"', '.', '\"', ?, '\"', '')"),
+			[]any{"name", "sh", "ev", "processes", "ev", "processes", "pid", float64(1), "args", "-", "v"},
+		},
+		"mysql_mixed_expr": []any{
+			jsonSQL("ev", "CONCAT('$.', '\"', ?, '\"', '')"),
+			[]any{"name", "sh", "processes", "processes", "pid", float64(1), "args", "-", "v"},
+		},
+		"clickhouse_expr": []any{"", []any(nil)},
+	}
+	for name, item := range result {
+		if !reflect.DeepEqual(item, need[name]) {
+			t.Fatalf("executor %s need %#v but got %#v", name, need[name], item)
+		}
+	}
+
+	// 空数组、字段不存在时，any 为 false，all 为 true
+	for expr, need := range map[string]bool{
+		"any(ev.processes, pid > 100) || any(ev.missing, pid > 0)": false,
+		"all(ev.processes, pid > 1) && all(ev.missing, pid > 100)": true,
+		"!all(ev.processes, any(args, v == '-c'))":                 true,
+	} {
+		if result, _ := cond_expr.StdExecutor.DoAst(m, parseAst(t, expr), "", ""); result != need {
+			t.Fatalf("testCase %s need %v but got %v", expr, need, result)
+		}
+	}
+	sql, params, keys := mysql_json_expr.StdExecutor.DoAst(parseAst(t, "any(processes, pid == 1)"), "ev", "", "attr")
+	if sql != "JSON_CONTAINS(attr, JSON_OBJECT(?, ?), CONCAT('$.', '\"', ?, '\"', '.', '\"', ?, '\"', ''))" ||
+		!reflect.DeepEqual(params, []any{"pid", float64(1), "ev", "processes"}) || !reflect.DeepEqual(keys, []string{"ev.processes.pid"}) {
+		t.Fatalf("unexpected JSON_CONTAINS %s %v %v", sql, params, keys)
+	}
+
+	// 不支持 any、all 的执行器返回错误，不能退化为空条件
+	expr := "a == 1 && !any(p, name == 'x')"
+	for name, doExpr := range map[string]func(string) error{
+		"mysql_expr": func(expr string) error {
+			_, _, _, err := mysql_expr.StdExecutor.DoExpr(expr, "", "")
+			return err
+		},
+		"clickhouse_expr": func(expr string) error {
+			_, _, _, err := clickhouse_expr.StdExecutor.DoExpr(expr, "", "")
+			return err
+		},
+	} {
+		if err := doExpr(expr); err == nil || err.Error() != "unsupported quantifier: any" {
+			t.Fatalf("testCase %s executor %s need unsupported quantifier but got %v", expr, name, err)
+		}
+	}
+}
+
+func TestExecutorBuilder(t *testing.T) {
+	m := map[string]any{
+		"user": "it's",
+		"port": float64(443),
+		"ip":   "10.0.0.1",
+	}
+	testCases := []struct {
+		Ast    *expression.AstNode
+		Expr   string
+		Result bool
+	}{
+		{
+			Ast:    expression.And(expression.Cond("user", "==", "it's"), expression.Cond("port", "in", []int{80, 443})),
+			Expr:   `user == "it's" && port in [80, 443]`,
+			Result: true,
+		},
+		{
+			Ast:    expression.Or(expression.Not(expression.Exists("ip")), expression.Cond("port", "<", 100)),
+			Expr:   "!(ip exists) || port < 100",
+			Result: false,
+		},
+	}
+	for _, testCase := range testCases {
+		if err := expression.CheckAst(testCase.Ast); err != nil {
+			t.Fatalf("testCase %s check failure: %s", testCase.Expr, err)
+		}
+		result := executorResult(testCase.Ast, m)
+		explicit := executorResult(parseAst(t, testCase.Expr), m)
+		for name, item := range result {
+			if !reflect.DeepEqual(item, explicit[name]) {
+				t.Fatalf("testCase %s executor %s need %v but got %v", testCase.Expr, name, explicit[name], item)
+			}
+		}
+		if result["cond_expr"] != testCase.Result {
+			t.Fatalf("testCase %s need %v but got %v", testCase.Expr, testCase.Result, result["cond_expr"])
+		}
+	}
+}
+
+func TestExecutorBuilderIllegal(t *testing.T) {
+	m := map[string]any{
+		"port": float64(443),
+	}
+	ast := expression.Or(expression.Cond("port", "==", 443), expression.Cond("a' OR 1=1 --", "==", 1))
+	if ast.Type != token.ILLEGAL {
+		t.Fatalf("need illegal but got %s", expression.Format(ast))
+	}
+	need := map[string]any{
+		"cond_expr":        false,
+		"es_expr":          map[string]any{"match_none": map[string]any{}},
+		"mysql_expr":       []any{"1 = 0", []any(nil)},
+		"mysql_json_expr":  []any{"1 = 0", []any(nil)},
+		"mysql_mixed_expr": []any{"1 = 0", []any(nil)},
+		"clickhouse_expr":  []any{"1 = 0", []any(nil)},
+	}
+	if result := executorResult(ast, m); !reflect.DeepEqual(result, need) {
+		t.Fatalf("need %v but got %v", need, result)
+	}
+	if _, err := cond_expr.StdExecutor.CompileAst(ast, "", ""); err == nil {
+		t.Fatalf("CompileAst need error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	schema := &expression.Schema{
+		Fields: map[string]*expression.FieldSchema{
+			"port":           {Type: token.NUM},
+			"bytes_in":       {Type: token.NUM},
+			"user":           {Type: token.STRING, Ops: []string{"==", "!=", "in", "reg"}},
+			"enabled":        {Type: token.BOOL},
+			"create_at":      {Type: token.STRING},
+			"ts":             {Type: token.DATE},
+			"processes.name": {Type: token.STRING},
+			"processes.pid":  {Type: token.NUM},
+		},
+		Targets: map[string]expression.Support{
+			"cond_expr":  cond_expr.StdExecutor.Support(),
+			"mysql_expr": expression.NewSupport(mysql_expr.DefaultFnMap),
+			"es_expr":    expression.NewSupport(es_expr.DefaultFnMap),
+		},
+	}
+	testCases := []struct {
+		Expr   string
+		Errors []string
+	}{
+		{
+			Expr: "port in '80,443' && user in ['a', 'b'] && enabled == true || port > $bytes_in && lower(user) == 'a'",
+		},
+		{
+			Expr: "prot == 80 && usr exists",
+			Errors: []string{
+				"prot == 80: unknown field: prot",
+				"usr exists: unknown field: usr",
+			},
+		},
+		{
+			Expr: "port > '80' && port in '80,http' && user contains 'adm' && port == $user",
+			Errors: []string{
+				"port > '80': cannot compare num field with string",
+				"port in '80,http': cannot compare num field with item \"http\"",
+				"user contains 'adm': operator contains is not allowed on user",
+				"port == $user: cannot compare num field with string field user",
+			},
+		},
+		{
+			Expr: "enabled in [true, 1] && port containsBit $bytes_in && len(nick) > 1",
+			Errors: []string{
+				"enabled in [true, 1]: cannot compare bool field with num item 1",
+				"port containsBit $bytes_in: unsupported condition: containsBit field (es_expr)",
+				"port containsBit $bytes_in: unsupported condition: containsBit field (mysql_expr)",
+				"len(nick) > 1: unknown field: nick",
+			},
+		},
+		{
+			Expr: "ts between [now-1d, '2024-01-01'] && ts > 2024-01-01 && ts == 1",
+			Errors: []string{
+				"ts == 1: cannot compare date field with num",
+			},
+		},
+		{
+			Expr: "any(processes, name == 'sh' && pid == 'x' && cmd exists) && all(processes, pid > 1)",
+			Errors: []string{
+				"pid == 'x': cannot compare num field with string",
+				"cmd exists: unknown field: processes.cmd",
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		errs := expression.Validate(parseAst(t, testCase.Expr), schema)
+		msgs := []string{}
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		if len(msgs) != len(testCase.Errors) || (len(msgs) != 0 && !reflect.DeepEqual(msgs, testCase.Errors)) {
+			t.Fatalf("testCase %s need %q but got %q", testCase.Expr, testCase.Errors, msgs)
+		}
+	}
+}
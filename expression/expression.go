@@ -3,25 +3,38 @@
 //      => 消除左递归
 //      expr  => term expr1
 //      expr1 => op term expr1 | null
-// term       => key cond val | '(' expr ')'
+//      => 优先级：'&&' > '||'，同优先级从左到右结合
+// term       => not term | operand cond val | operand unary | quant | '(' expr ')'
+// quant      => ('any' | 'all') '(' key ',' expr ')'    // 数组元素条件，expr 中的字段相对于数组元素
+// operand    => key | call
+// call       => name '(' ')' | name '(' arg (',' arg)* ')'    // arg => key | number | bool | string | null | call
 // op         => '&&' | '||'
 //            => /(&&|\|\|)/
+// not        => '!' | 'not'
+//            => /(!|not(?P<next>[\s(!]))/     // not 后面需要是空字符、( 或者 !，不属于 not
 // cond       => '==' | '!=' | '>=' | '<=' | '>' | '<'
 //            => /(==|!=|>=|<=|>|<)/     // >= <= 要在 > < 前面；不然会匹配不到
+// unary      => 'exists' | 'notExists'
+//            => /(exists|notExists)\b/
 // key        => /[\w_][\w\d_]*/
-// val        => number | bool | string
+// val        => number | bool | string | date | array | null | field | call
 //            // 正负数字
 //            => /(?:\+|-)?\d+(?:\.\d+)?/
 //            // true | false
 //            => /(true|false)/
-//            // 首尾匹配 ' | "
-//            // (?<!\\)' 负后顾，'前面不是单个斜杠
-//            // (?<=(?<!\\)(?:\\\\)+)' 后顾，'前面必须是偶数个斜杠
-//            => /^('|")(.*?)(?:(?<!\\)|(?<=(?<!\\)(?:\\\\)+))(\1)/
+//            // 首尾匹配 ' | "，支持转义 \' \" \\ \n \r \t \uXXXX，其他转义保留 \
+//            // 首尾匹配 `，原始字符串，不处理转义，适合 reg 的正则
+//            => /'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"|`[^`]*`/
+//            // 日期，ISO-8601 或者相对日期（与 Elasticsearch 的 date math 一致），不需要引号
+//            => /\d{4}-\d{2}-\d{2}(?:T\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:Z|[+-]\d{2}:\d{2})?)?/
+//            => /now(?:[+-]\d+[yMwdhHms]|\/[yMwdhHms])*\b/
+// array      => '[' ']' | '[' val1 (',' val1)* ']'    // val1 => number | bool | string | date
+// field      => '$' key                                // 字段引用，与左侧字段比较
 /* ---------------------------------------------------------------- */
 
 /* ---------------------------------------------------------------- */
 // 0. 词法分析，把所有词转换成 token(type, val)
+//    TokensRead 使用手写的扫描器，以下正则作为文档，以及 TokensReadRegexp 的实现
 //
 // 括号
 //    LBT   	/[(\[{]/
@@ -33,18 +46,33 @@
 // 逻辑
 //    OP    	/(&&|\|\|)/
 //
+// 取反
+//    NOT   	/(!|not(?P<next>[\s(!]))/
+//
 // 条件
-//    COND  	/(==|!=|>=|<=|>|<)/
+//    COND  	/(==|!=|>=|<=|>|<|(?:contains|in|like|...)\b)/    // 单词形式的条件需要完整匹配，likes 为 IDENT
+//    UNARY 	/(exists|notExists)\b/
 //
 // 变量
 //    IDENT 	/[a-zA-Z_][\w]*/
+//    FUNC  	/[a-zA-Z_][\w]*\s*\(/    // 函数名，由 LexParse 合并参数直到 ')'
+//    QUANT 	'any(' | 'all('           // 条件位置上的 FUNC，由 LexParse 合并数组字段和子表达式直到 ')'
 //
 // 值
-//    VAL   NUM | BOOL | STRING
+//    VAL   NUM | BOOL | STRING | DATE | ARRAY | NULL | FIELD
 //
 //    NUM   	/(?:\+|-)?\d+(?:\.\d+)?/
 //    BOOL  	/(?:true|false)/
-//    STRING	/('|")(.*?)(?:(?<!\\)|(?<=(?<!\\)(?:\\\\)+))(\1)/
+//    NULL  	/null\b/
+//    FIELD 	/\$[a-zA-Z_][\w]*/
+//    STRING	/'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"|`[^`]*`/    // LexToExpr 时重新转义
+//    DATE  	/\d{4}-\d{2}-\d{2}(?:T...)?/ | /now(?:[+-]\d+[yMwdhHms]|\/[yMwdhHms])*\b/    // ISO-8601 在 NUM 前面，相对日期在 FUNC 后面
+//    ARRAY 	'[' VAL1 (',' VAL1)* ']'    // VAL1 => NUM | BOOL | STRING | DATE，由 LexParse 合并值位置上的 [ ... ]
+//
+//    DATE 由 LexParse 使用 datetime.ParseDateMath 校验；值以外的位置上 now 为 IDENT；between 的值只能是 2 个元素的 ARRAY
+//
+// 逗号
+//    COMMA 	/,/
 //
 // 空字符
 //    DELIM		/\s*/
@@ -52,17 +80,17 @@
 // 无法解析
 //    ILLEGAL	/.+/
 //
-// 优先级 COND = OP > VAL > IDENT > LBT = RBT > (DELIM: 每次匹配前过滤掉空字符) > ILLEGAL
+// 优先级 UNARY > COND = OP > NOT > VAL > FUNC > IDENT > LBT = RBT > (DELIM: 每次匹配前过滤掉空字符) > ILLEGAL
 /* ---------------------------------------------------------------- */
 /* ---------------------------------------------------------------- */
 // 1. 把分词进行解析，生成最终的词法数组 >> 可以转回表达式
 /* ---------------------------------------------------------------- */
 /* ---------------------------------------------------------------- */
-// 2. 把词法数组解析，转成语法树 >> 不可以转回表达式
+// 2. 把词法数组解析，转成语法树 >> 可以通过 Format 转回表达式
 package expression
 
 import (
-	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/jummyliu/pkg/expression/token"
@@ -74,6 +102,8 @@ type LexNode struct {
 	Type    token.Token
 	Value   any
 	SubCond []*LexNode // 不为空，则为子表达式
+	Args    []*LexNode // 函数调用的参数，只有 FUNC 才有；QUANT 为数组字段
+	Sub     []*LexNode // 数组元素条件的子表达式，只有 QUANT 才有
 	Len     int
 	From    int
 }
@@ -81,7 +111,23 @@ type LexNode struct {
 // TokensRead 读取表达式所有分词
 //
 //	正常来说，返回值是个中间状态，一般不直接使用，需要调用 TokensParse 进行解析
+//
+//	使用手写的扫描器单次遍历，结果与 TokensReadRegexp 一致
 func TokensRead(expr string) (tokens []*LexNode) {
+	s := scanner{expr: expr}
+	for {
+		tok := s.next()
+		if tok == nil {
+			return tokens
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+// TokensReadRegexp 使用 token.ParserPriority 中的正则读取表达式所有分词
+//
+//	每个位置依次尝试所有正则，性能较差；自定义 token.ParserPriority 时使用
+func TokensReadRegexp(expr string) (tokens []*LexNode) {
 	var index = 0
 	for len(expr) != 0 {
 		tok := next(expr, token.DelimParser)
@@ -105,7 +151,7 @@ func TokensRead(expr string) (tokens []*LexNode) {
 
 // 获取下一个 token
 func next(expr string, tokenParser token.Parser) *LexNode {
-	result := tokenParser.Reg.FindString(expr)
+	result := tokenParser.Find(expr)
 	if len(result) == 0 {
 		return nil
 	}
@@ -117,6 +163,8 @@ func next(expr string, tokenParser token.Parser) *LexNode {
 }
 
 // LexParse 解析所有的分词
+//
+//	解析失败时，返回的 err 为 *SyntaxError
 func LexParse(tokens []*LexNode) (lexTokens []*LexNode, err error) {
 	// 为空
 	if len(tokens) == 0 {
@@ -126,16 +174,59 @@ func LexParse(tokens []*LexNode) (lexTokens []*LexNode, err error) {
 	stack := []*[]*LexNode{}
 	curState := token.LITERAL_BEGIN
 	var active *[]*LexNode = &newTokens
+	var cond *LexNode
 	last := 0
-	for i, item := range tokens {
+	for i := 0; i < len(tokens); i++ {
+		item := tokens[i]
+		if curState != token.CONDITION {
+			item = dateIdent(item)
+		}
+		if curState == token.CONDITION && item.Type == token.LBT && item.Value == "[" {
+			// 值的位置出现 [，解析为数组
+			arr, end, err := parseArray(tokens, i)
+			if err != nil {
+				newTokens = append(newTokens, illegalNode(err))
+				break
+			}
+			item = arr
+			i = end
+		}
+		if item.Type == token.FUNC && curState != token.CONDITION && token.IsQuant(item.Value.(string)) {
+			// 条件位置上的 any(、all(，解析为数组元素条件
+			quant, end, err := parseQuant(tokens, i)
+			if err != nil {
+				newTokens = append(newTokens, illegalNode(err))
+				break
+			}
+			item = quant
+			i = end
+		}
 		if utils.FindIndex[token.Token](token.StateMatrix[curState], item.Type) == -1 {
 			// 异常，下一状态不匹配
-			newTokens = append(newTokens, &LexNode{
-				Type:  token.ILLEGAL,
-				Value: errors.New("illegal NEXT STATE"),
-			})
+			newTokens = append(newTokens, illegalNode(newSyntaxError(ErrNextState, item.From, item, token.StateMatrix[curState])))
+			break
+		}
+		if err := checkValue(cond, curState, item); err != nil {
+			newTokens = append(newTokens, illegalNode(err))
 			break
 		}
+		if item.Type == token.CONDITION {
+			cond = item
+		}
+		state := item.Type
+		if item.Type == token.FUNC {
+			// 函数调用，合并参数
+			call, end, err := parseCall(tokens, i)
+			if err != nil {
+				newTokens = append(newTokens, illegalNode(err))
+				break
+			}
+			item = call
+			i = end
+			if curState == token.CONDITION {
+				state = token.FUNC_VALUE
+			}
+		}
 		if item.Type == token.LBT {
 			// 左括号，把数据压入栈
 			tmp := &LexNode{
@@ -149,10 +240,7 @@ func LexParse(tokens []*LexNode) (lexTokens []*LexNode, err error) {
 			// 右括号，从栈里取最后一个数据
 			if len(stack) == 0 {
 				// 异常，右括号不匹配
-				newTokens = append(newTokens, &LexNode{
-					Type:  token.ILLEGAL,
-					Value: errors.New("illegal MISS LBT"),
-				})
+				newTokens = append(newTokens, illegalNode(newSyntaxError(ErrMissLBT, item.From, item, token.StateMatrix[curState], token.RBT)))
 				break
 			}
 			tmp := stack[len(stack)-1]
@@ -162,29 +250,21 @@ func LexParse(tokens []*LexNode) (lexTokens []*LexNode, err error) {
 		} else {
 			*active = append(*active, item)
 		}
-		curState = item.Type
+		curState = state
 		last = i + 1
 	}
 	if newTokens[len(newTokens)-1].Type != token.ILLEGAL {
 		// 判断是否解析完成
 		// 最后状态没有 end
+		end := tokens[len(tokens)-1].From + tokens[len(tokens)-1].Len
 		if last != len(tokens) {
 			// 没有解析完 token，异常
-			newTokens = append(newTokens, &LexNode{
-				Type:  token.ILLEGAL,
-				Value: errors.New("illegal EOF"),
-			})
+			newTokens = append(newTokens, illegalNode(newSyntaxError(ErrEOF, tokens[last].From, tokens[last], token.StateMatrix[curState])))
 		} else if utils.FindIndex[token.Token](token.StateMatrix[curState], token.LITERAL_END) == -1 {
-			newTokens = append(newTokens, &LexNode{
-				Type:  token.ILLEGAL,
-				Value: errors.New("illegal MISS END"),
-			})
+			newTokens = append(newTokens, illegalNode(newSyntaxError(ErrMissEnd, end, nil, token.StateMatrix[curState])))
 		} else if len(stack) != 0 {
 			//  括号没闭合
-			newTokens = append(newTokens, &LexNode{
-				Type:  token.ILLEGAL,
-				Value: errors.New("illegal MISS RBT"),
-			})
+			newTokens = append(newTokens, illegalNode(newSyntaxError(ErrMissRBT, end, nil, token.StateMatrix[curState], token.LITERAL_END)))
 		}
 	}
 	// 解析出现了错误，返回异常
@@ -194,6 +274,182 @@ func LexParse(tokens []*LexNode) (lexTokens []*LexNode, err error) {
 	return newTokens, nil
 }
 
+// parseArray 从 i 开始解析数组 [val, val, ...]，返回数组分词，以及 ] 所在的位置
+//
+//	数组元素只能是 NUM、BOOL、STRING、DATE
+func parseArray(tokens []*LexNode, i int) (arr *LexNode, end int, err *SyntaxError) {
+	lbt := tokens[i]
+	values := []any{}
+	state := token.LBT
+	for j := i + 1; j < len(tokens); j++ {
+		item := tokens[j]
+		if utils.FindIndex[token.Token](token.ArrayStateMatrix[state], item.Type) == -1 ||
+			(item.Type == token.RBT && item.Value != "]") {
+			return nil, j, newSyntaxError(ErrNextState, item.From, item, token.ArrayStateMatrix[state])
+		}
+		switch item.Type {
+		case token.RBT:
+			return &LexNode{
+				Type:  token.ARRAY,
+				Value: values,
+				Len:   item.From + item.Len - lbt.From,
+				From:  lbt.From,
+			}, j, nil
+		case token.DATE:
+			if !token.IsDate(string(item.Value.(token.Date))) {
+				return nil, j, newSyntaxError(ErrDate, item.From, item, nil)
+			}
+			values = append(values, item.Value)
+		case token.NUM, token.BOOL, token.STRING:
+			values = append(values, item.Value)
+		}
+		state = item.Type
+	}
+	last := tokens[len(tokens)-1]
+	return nil, len(tokens), newSyntaxError(ErrMissRBT, last.From+last.Len, nil, token.ArrayStateMatrix[state])
+}
+
+// parseCall 从 i 开始解析函数调用 name(arg, arg, ...)，返回函数分词，以及 ) 所在的位置
+//
+//	参数只能是 IDENT、NUM、BOOL、STRING、NULL 或者嵌套的函数调用
+func parseCall(tokens []*LexNode, i int) (call *LexNode, end int, err *SyntaxError) {
+	fn := tokens[i]
+	args := []*LexNode{}
+	state := token.LBT
+	for j := i + 1; j < len(tokens); j++ {
+		item := dateIdent(tokens[j])
+		if utils.FindIndex[token.Token](token.CallStateMatrix[state], item.Type) == -1 ||
+			(item.Type == token.RBT && item.Value != ")") {
+			return nil, j, newSyntaxError(ErrNextState, item.From, item, token.CallStateMatrix[state])
+		}
+		switch item.Type {
+		case token.RBT:
+			return &LexNode{
+				Type:  token.FUNC,
+				Value: fn.Value,
+				Args:  args,
+				Len:   item.From + item.Len - fn.From,
+				From:  fn.From,
+			}, j, nil
+		case token.FUNC:
+			sub, subEnd, err := parseCall(tokens, j)
+			if err != nil {
+				return nil, subEnd, err
+			}
+			args = append(args, sub)
+			j = subEnd
+		case token.COMMA:
+		default:
+			args = append(args, item)
+		}
+		state = item.Type
+	}
+	last := tokens[len(tokens)-1]
+	return nil, len(tokens), newSyntaxError(ErrMissRBT, last.From+last.Len, nil, token.CallStateMatrix[state])
+}
+
+// parseQuant 从 i 开始解析数组元素条件 any(path, expr)、all(path, expr)，返回 QUANT 分词，以及 ) 所在的位置
+//
+//	path 只能是 IDENT；expr 为完整的表达式，递归调用 LexParse 解析
+func parseQuant(tokens []*LexNode, i int) (quant *LexNode, end int, err *SyntaxError) {
+	fn := tokens[i]
+	// path ,
+	for j, expected := range []token.Token{token.IDENT, token.COMMA} {
+		if i+1+j >= len(tokens) {
+			last := tokens[len(tokens)-1]
+			return nil, len(tokens), newSyntaxError(ErrMissRBT, last.From+last.Len, nil, []token.Token{expected})
+		}
+		if item := dateIdent(tokens[i+1+j]); item.Type != expected {
+			return nil, i + 1 + j, newSyntaxError(ErrNextState, item.From, item, []token.Token{expected})
+		}
+	}
+	// 查找匹配的 )，函数调用、括号、数组都会增加深度
+	depth := 0
+	for j := i + 3; j < len(tokens); j++ {
+		item := tokens[j]
+		switch item.Type {
+		case token.LBT, token.FUNC:
+			depth++
+			continue
+		case token.RBT:
+			if depth > 0 {
+				depth--
+				continue
+			}
+		default:
+			continue
+		}
+		if item.Value != ")" || j == i+3 {
+			return nil, j, newSyntaxError(ErrNextState, item.From, item, token.StateMatrix[token.LITERAL_BEGIN])
+		}
+		sub, err := LexParse(tokens[i+3 : j])
+		if err != nil {
+			return nil, j, sub[len(sub)-1].Value.(*SyntaxError)
+		}
+		return &LexNode{
+			Type:  token.QUANT,
+			Value: fn.Value,
+			Args:  []*LexNode{dateIdent(tokens[i+1])},
+			Sub:   sub,
+			Len:   item.From + item.Len - fn.From,
+			From:  fn.From,
+		}, j, nil
+	}
+	last := tokens[len(tokens)-1]
+	return nil, len(tokens), newSyntaxError(ErrMissRBT, last.From+last.Len, nil, nil)
+}
+
+// dateIdent 值以外的位置上，形如字段名的相对日期解析为字段，如：now == 1、lower(now)
+func dateIdent(item *LexNode) *LexNode {
+	if item.Type != token.DATE {
+		return item
+	}
+	name := string(item.Value.(token.Date))
+	if token.REG_IDENT.FindString(name) != name {
+		return item
+	}
+	return &LexNode{
+		Type:  token.IDENT,
+		Value: name,
+		Len:   item.Len,
+		From:  item.From,
+	}
+}
+
+// checkValue 检查条件的值，日期需要能被解析，between 需要 2 个元素的数组，inCidr、notInCidr 需要合法的 CIDR
+func checkValue(cond *LexNode, curState token.Token, item *LexNode) *SyntaxError {
+	if curState != token.CONDITION {
+		return nil
+	}
+	if item.Type == token.DATE && !token.IsDate(string(item.Value.(token.Date))) {
+		return newSyntaxError(ErrDate, item.From, item, nil)
+	}
+	if cond == nil {
+		return nil
+	}
+	switch cond.Value {
+	case "between":
+		if arr, ok := item.Value.([]any); item.Type != token.ARRAY || !ok || len(arr) != 2 {
+			return newSyntaxError(ErrBetween, item.From, item, nil)
+		}
+	case "inCidr", "notInCidr":
+		if _, err := ParseCidrs(item.Value); err != nil {
+			return newSyntaxError(ErrCidr, item.From, item, nil)
+		}
+	}
+	return nil
+}
+
+// illegalNode 把语法错误包装成 ILLEGAL 分词
+func illegalNode(err *SyntaxError) *LexNode {
+	return &LexNode{
+		Type:  token.ILLEGAL,
+		Value: err,
+		Len:   err.Len,
+		From:  err.Offset,
+	}
+}
+
 // LexToExpr 分词转表达式
 func LexToExpr(tokens []*LexNode) (expr string) {
 	if len(tokens) == 0 {
@@ -207,6 +463,20 @@ func LexToExpr(tokens []*LexNode) (expr string) {
 			b.WriteString(LexToExpr(item.SubCond))
 			continue
 		}
+		if item.Type == token.FUNC {
+			b.WriteByte(' ')
+			b.WriteString(callToExpr(item))
+			continue
+		}
+		if item.Type == token.QUANT {
+			b.WriteByte(' ')
+			b.WriteString(token.FuncEncoder.Encode(item.Value))
+			b.WriteString(token.ParserMap[token.IDENT].Encode(item.Args[0].Value))
+			b.WriteString(", ")
+			b.WriteString(LexToExpr(item.Sub))
+			b.WriteByte(')')
+			continue
+		}
 		parser, ok := token.ParserMap[item.Type]
 		if !ok {
 			return ""
@@ -217,64 +487,230 @@ func LexToExpr(tokens []*LexNode) (expr string) {
 	return b.String()[1:]
 }
 
+// callToExpr 函数调用转表达式
+func callToExpr(call *LexNode) string {
+	args := make([]string, 0, len(call.Args))
+	for _, arg := range call.Args {
+		if arg.Type == token.FUNC {
+			args = append(args, callToExpr(arg))
+			continue
+		}
+		args = append(args, token.ParserMap[arg.Type].Encode(arg.Value))
+	}
+	return token.FuncEncoder.Encode(call.Value) + strings.Join(args, ", ") + ")"
+}
+
 // AstNode 语法树节点
 type AstNode struct {
 	Type  token.Token `json:"type"`
 	Value any         `json:"value"`
-	Left  *AstNode    `json:"left"`  // sub left tree, 只有非 term 节点才有, term 节点为 nil
-	Right *AstNode    `json:"right"` // sub right tree, 只有非 term 节点有, term 节点为 nil
+	Left  *AstNode    `json:"left"`           // sub left tree, 只有非 term 节点才有, term 节点为 nil；QUANT 节点为数组字段
+	Right *AstNode    `json:"right"`          // sub right tree, 只有非 term 节点有, term 节点为 nil；QUANT 节点为子表达式
+	Args  []*AstNode  `json:"args,omitempty"` // 函数调用的参数，只有 FUNC 节点才有
+}
+
+// CheckQuant 检查语法树中是否存在数组元素条件，用于不支持 any、all 的执行器
+func CheckQuant(ast *AstNode) error {
+	if ast == nil {
+		return nil
+	}
+	switch ast.Type {
+	case token.QUANT:
+		return fmt.Errorf("unsupported quantifier: %v", ast.Value)
+	case token.OPERATOR:
+		if err := CheckQuant(ast.Left); err != nil {
+			return err
+		}
+		return CheckQuant(ast.Right)
+	case token.NOT:
+		return CheckQuant(ast.Left)
+	}
+	return nil
+}
+
+// AstOption 语法树解析选项
+type AstOption func(opts *AstOptions)
+
+// AstOptions 语法树解析选项
+type AstOptions struct {
+	// LeftAssociative 所有逻辑关系同一优先级，严格从左到右结合
+	//
+	//	兼容旧版本：a || b && c => (a || b) && c
+	LeftAssociative bool
+}
+
+// WithLeftAssociative 使用旧版本的解析方式，&& 和 || 同一优先级，从左到右结合
+func WithLeftAssociative() AstOption {
+	return func(opts *AstOptions) {
+		opts.LeftAssociative = true
+	}
+}
+
+func initAstOptions(opts ...AstOption) *AstOptions {
+	options := &AstOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
 }
 
 // AstParse 把分词解析成语法树
-func AstParse(lexTokens []*LexNode) (tree *AstNode) {
-	var root *AstNode
-	var node *AstNode
-	for i := 0; i < len(lexTokens); i++ {
-		item := lexTokens[i]
-		if len(item.SubCond) != 0 {
-			node = AstParse(item.SubCond)
-			if root == nil {
-				root = node
-			} else {
-				root.Right = node
-			}
+//
+//	默认 && 优先级高于 ||：a || b && c => a || (b && c)
+//	同一优先级从左到右结合：a && b && c => (a && b) && c
+func AstParse(lexTokens []*LexNode, opts ...AstOption) (tree *AstNode) {
+	p := &astParser{
+		tokens:  lexTokens,
+		opts:    opts,
+		options: initAstOptions(opts...),
+	}
+	return p.parseExpr(1)
+}
+
+// astParser 语法树解析器，使用优先级爬升法解析
+type astParser struct {
+	tokens  []*LexNode
+	pos     int
+	opts    []AstOption
+	options *AstOptions
+}
+
+// peek 获取下一个分词，跳过子表达式首尾的括号
+func (p *astParser) peek() *LexNode {
+	for p.pos < len(p.tokens) {
+		item := p.tokens[p.pos]
+		if len(item.SubCond) == 0 && (item.Type == token.LBT || item.Type == token.RBT) {
+			p.pos++
 			continue
 		}
-		switch item.Type {
-		case token.OPERATOR:
-			if root == nil {
-				break
-			}
-			node = &AstNode{
-				Type:  item.Type,
-				Value: item.Value,
-				Left:  root,
-			}
-			root = node
-		case token.IDENT:
-			condition := lexTokens[i+1]
-			value := lexTokens[i+2]
-			node = &AstNode{
-				Type:  condition.Type,
+		return item
+	}
+	return nil
+}
+
+// next 读取下一个分词
+func (p *astParser) next() *LexNode {
+	item := p.peek()
+	if item != nil {
+		p.pos++
+	}
+	return item
+}
+
+// precedence 逻辑关系的优先级
+func (p *astParser) precedence(op any) int {
+	if p.options.LeftAssociative {
+		return 1
+	}
+	if prec, ok := token.OperatorPrecedence[op.(string)]; ok {
+		return prec
+	}
+	return 1
+}
+
+// parseExpr 解析优先级不低于 minPrec 的表达式
+//
+//	expr => term (op term)*
+func (p *astParser) parseExpr(minPrec int) *AstNode {
+	left := p.parseTerm()
+	for {
+		item := p.peek()
+		if item == nil || item.Type != token.OPERATOR || len(item.SubCond) != 0 {
+			return left
+		}
+		prec := p.precedence(item.Value)
+		if prec < minPrec {
+			return left
+		}
+		p.pos++
+		right := p.parseExpr(prec + 1)
+		if left == nil {
+			left = right
+			continue
+		}
+		left = &AstNode{
+			Type:  item.Type,
+			Value: item.Value,
+			Left:  left,
+			Right: right,
+		}
+	}
+}
+
+// parseTerm 解析一个 term
+//
+//	term => not term | operand cond val | operand unary | quant | '(' expr ')'
+//	一元条件 operand unary 解析为 operand unary null
+//	数组元素条件 any(path, expr) 解析为 QUANT 节点，Left 为数组字段，Right 为子表达式的语法树
+func (p *astParser) parseTerm() *AstNode {
+	item := p.next()
+	if item == nil {
+		return nil
+	}
+	if len(item.SubCond) != 0 {
+		return AstParse(item.SubCond, p.opts...)
+	}
+	switch item.Type {
+	case token.NOT:
+		sub := p.parseTerm()
+		if sub == nil {
+			return nil
+		}
+		// 取反节点，只有 left
+		return &AstNode{
+			Type:  item.Type,
+			Value: item.Value,
+			Left:  sub,
+		}
+	case token.QUANT:
+		sub := AstParse(item.Sub, p.opts...)
+		if sub == nil {
+			return nil
+		}
+		return &AstNode{
+			Type:  item.Type,
+			Value: item.Value,
+			Left:  astOperand(item.Args[0]),
+			Right: sub,
+		}
+	case token.IDENT, token.FUNC:
+		condition := p.next()
+		if condition == nil {
+			return nil
+		}
+		if condition.Type == token.UNARY {
+			return &AstNode{
+				Type:  token.CONDITION,
 				Value: condition.Value,
-				Left: &AstNode{
-					Type:  item.Type,
-					Value: item.Value,
-				},
+				Left:  astOperand(item),
 				Right: &AstNode{
-					Type:  value.Type,
-					Value: value.Value,
+					Type:  token.NULL,
+					Value: nil,
 				},
 			}
-			if root == nil {
-				root = node
-			} else {
-				root.Right = node
-			}
-			i += 2
-		default:
 		}
+		value := p.next()
+		if value == nil {
+			return nil
+		}
+		return &AstNode{
+			Type:  condition.Type,
+			Value: condition.Value,
+			Left:  astOperand(item),
+			Right: astOperand(value),
+		}
+	}
+	return nil
+}
 
+// astOperand 把分词转换成条件两侧的节点，函数调用保留参数
+func astOperand(item *LexNode) *AstNode {
+	node := &AstNode{
+		Type:  item.Type,
+		Value: item.Value,
+	}
+	for _, arg := range item.Args {
+		node.Args = append(node.Args, astOperand(arg))
 	}
-	return root
+	return node
 }
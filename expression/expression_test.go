@@ -2,8 +2,13 @@ package expression
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/jummyliu/pkg/expression/token"
 )
 
 func TestTokenRead(t *testing.T) {
@@ -26,6 +31,22 @@ func TestTokenRead(t *testing.T) {
 				{Type: "rbt", Value: ")", Len: 1, From: 42},
 			},
 		},
+		{
+			Expr: "!(a == 1 || not b == 2)",
+			Result: []*LexNode{
+				{Type: "not", Value: "!", Len: 1, From: 0},
+				{Type: "lbt", Value: "(", Len: 1, From: 1},
+				{Type: "ident", Value: "a", Len: 1, From: 2},
+				{Type: "condition", Value: "==", Len: 3, From: 4},
+				{Type: "num", Value: float64(1), Len: 1, From: 7},
+				{Type: "operator", Value: "||", Len: 2, From: 9},
+				{Type: "not", Value: "not", Len: 3, From: 12},
+				{Type: "ident", Value: "b", Len: 1, From: 16},
+				{Type: "condition", Value: "==", Len: 3, From: 18},
+				{Type: "num", Value: float64(2), Len: 1, From: 21},
+				{Type: "rbt", Value: ")", Len: 1, From: 22},
+			},
+		},
 	}
 	for _, testCase := range testCases {
 		results := TokensRead(testCase.Expr)
@@ -61,6 +82,343 @@ func TestLexParse(t *testing.T) {
 	}
 }
 
+func TestLexParseArray(t *testing.T) {
+	testCases := []struct {
+		Expr   string
+		Values [][]any
+		Result string
+	}{
+		{
+			Expr:   "port in [80, 443] && user notIn ['a', \"b,c\", true]",
+			Values: [][]any{{float64(80), float64(443)}, {"a", "b,c", true}},
+			Result: "port in [80.000000, 443.000000] && user notIn ['a', 'b,c', true]",
+		},
+		{
+			Expr:   "(port in [])",
+			Values: [][]any{{}},
+			Result: "( port in [] )",
+		},
+	}
+	for _, testCase := range testCases {
+		results, err := LexParse(TokensRead(testCase.Expr))
+		if err != nil {
+			t.Fatalf("testCase %s parse failure: %s", testCase.Expr, err)
+		}
+		values := [][]any{}
+		var walk func(items []*LexNode)
+		walk = func(items []*LexNode) {
+			for _, item := range items {
+				walk(item.SubCond)
+				if item.Type == token.ARRAY {
+					values = append(values, item.Value.([]any))
+				}
+			}
+		}
+		walk(results)
+		if !reflect.DeepEqual(values, testCase.Values) {
+			t.Fatalf("testCase %s need %v but got %v", testCase.Expr, testCase.Values, values)
+		}
+		if result := LexToExpr(results); result != testCase.Result {
+			t.Fatalf("testCase %s need %s but got %s", testCase.Expr, testCase.Result, result)
+		}
+	}
+}
+
+func TestLexParseSyntaxError(t *testing.T) {
+	testCases := []struct {
+		Expr     string
+		Msg      string
+		Offset   int
+		Expected []token.Token
+		Caret    string
+	}{
+		{
+			Expr:     "a == 1 && b 2",
+			Msg:      ErrNextState,
+			Offset:   12,
+			Expected: token.StateMatrix[token.IDENT],
+			Caret:    "a == 1 && b 2\n            ^",
+		},
+		{
+			Expr:     "a == 1 &&",
+			Msg:      ErrMissEnd,
+			Offset:   9,
+			Expected: token.StateMatrix[token.OPERATOR],
+			Caret:    "a == 1 &&\n         ^",
+		},
+		{
+			Expr:     "(a == 'hello'",
+			Msg:      ErrMissRBT,
+			Offset:   13,
+			Expected: []token.Token{token.OPERATOR, token.RBT},
+			Caret:    "(a == 'hello'\n             ^",
+		},
+		{
+			Expr:     "a == 1)",
+			Msg:      ErrMissLBT,
+			Offset:   6,
+			Expected: []token.Token{token.OPERATOR, token.LITERAL_END},
+			Caret:    "a == 1)\n      ^",
+		},
+		{
+			Expr:     "a == 1 && b == @@",
+			Msg:      ErrNextState,
+			Offset:   15,
+			Expected: token.StateMatrix[token.CONDITION],
+			Caret:    "a == 1 && b == @@\n               ^^",
+		},
+		{
+			Expr:     "a in [1, 2",
+			Msg:      ErrMissRBT,
+			Offset:   10,
+			Expected: []token.Token{token.COMMA, token.RBT},
+			Caret:    "a in [1, 2\n          ^",
+		},
+		{
+			Expr:     "a in [1 2]",
+			Msg:      ErrNextState,
+			Offset:   8,
+			Expected: []token.Token{token.COMMA, token.RBT},
+			Caret:    "a in [1 2]\n        ^",
+		},
+		{
+			Expr:     "a in [1, 2)",
+			Msg:      ErrNextState,
+			Offset:   10,
+			Expected: []token.Token{token.COMMA, token.RBT},
+			Caret:    "a in [1, 2)\n          ^",
+		},
+		{
+			Expr:     "lower(a b) == 'x'",
+			Msg:      ErrNextState,
+			Offset:   8,
+			Expected: token.CallStateMatrix[token.IDENT],
+			Caret:    "lower(a b) == 'x'\n        ^",
+		},
+		{
+			Expr:     "len(tags",
+			Msg:      ErrMissRBT,
+			Offset:   8,
+			Expected: token.CallStateMatrix[token.IDENT],
+			Caret:    "len(tags\n        ^",
+		},
+		{
+			Expr:     "a == 1, b == 2",
+			Msg:      ErrNextState,
+			Offset:   6,
+			Expected: []token.Token{token.OPERATOR, token.RBT, token.LITERAL_END},
+			Caret:    "a == 1, b == 2\n      ^",
+		},
+		{
+			Expr:     "any(processes name == 'sh')",
+			Msg:      ErrNextState,
+			Offset:   14,
+			Expected: []token.Token{token.COMMA},
+			Caret:    "any(processes name == 'sh')\n              ^^^^",
+		},
+		{
+			Expr:     "any(processes, name == )",
+			Msg:      ErrMissEnd,
+			Offset:   23,
+			Expected: token.StateMatrix[token.CONDITION],
+			Caret:    "any(processes, name == )\n                       ^",
+		},
+		{
+			Expr:   "all(processes, name == 'sh'",
+			Msg:    ErrMissRBT,
+			Offset: 27,
+			Caret:  "all(processes, name == 'sh'\n                           ^",
+		},
+		{
+			Expr:     "any(processes, ) && a == 1",
+			Msg:      ErrNextState,
+			Offset:   15,
+			Expected: token.StateMatrix[token.LITERAL_BEGIN],
+			Caret:    "any(processes, ) && a == 1\n               ^",
+		},
+		{
+			Expr:   "a == 1 && ts > 2024-13-45",
+			Msg:    ErrDate,
+			Offset: 15,
+			Caret:  "a == 1 && ts > 2024-13-45\n               ^^^^^^^^^^",
+		},
+		{
+			Expr:   "ts between [now-1d, 2024-02-30]",
+			Msg:    ErrDate,
+			Offset: 20,
+			Caret:  "ts between [now-1d, 2024-02-30]\n                    ^^^^^^^^^^",
+		},
+		{
+			Expr:   "ts between [now-1d] && a == 1",
+			Msg:    ErrBetween,
+			Offset: 11,
+			Caret:  "ts between [now-1d] && a == 1\n           ^^^^^^^^",
+		},
+		{
+			Expr:   "ts between now",
+			Msg:    ErrBetween,
+			Offset: 11,
+			Caret:  "ts between now\n           ^^^",
+		},
+		{
+			Expr:   "a inCidr 'bad'",
+			Msg:    ErrCidr,
+			Offset: 9,
+			Caret:  "a inCidr 'bad'\n         ^^^^^",
+		},
+		{
+			Expr:   "a notInCidr ['10.0.0.0/8', 'x'] || b == 1",
+			Msg:    ErrCidr,
+			Offset: 12,
+			Caret:  "a notInCidr ['10.0.0.0/8', 'x'] || b == 1\n            ^^^^^^^^^^^^^^^^^^^",
+		},
+	}
+	for _, testCase := range testCases {
+		_, err := LexParse(TokensRead(testCase.Expr))
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Fatalf("testCase %s need *SyntaxError but got %v", testCase.Expr, err)
+		}
+		if syntaxErr.Msg != testCase.Msg || syntaxErr.Offset != testCase.Offset {
+			t.Fatalf("testCase %s need %s at %d but got %s at %d", testCase.Expr, testCase.Msg, testCase.Offset, syntaxErr.Msg, syntaxErr.Offset)
+		}
+		if !reflect.DeepEqual(syntaxErr.Expected, testCase.Expected) {
+			t.Fatalf("testCase %s need expected %v but got %v", testCase.Expr, testCase.Expected, syntaxErr.Expected)
+		}
+		if caret := syntaxErr.Caret(testCase.Expr); caret != testCase.Caret {
+			t.Fatalf("testCase %s need caret:\n%s\nbut got:\n%s", testCase.Expr, testCase.Caret, caret)
+		}
+	}
+}
+
+func TestLexParseNow(t *testing.T) {
+	// now 只在值的位置上解析为日期，其他位置为字段名
+	expr := "now == now && lower(now) == 'x' && any(now, now > now-1d) && ts in [now, now-1d]"
+	lexTokens, err := LexParse(TokensRead(expr))
+	if err != nil {
+		t.Fatalf("testCase %s parse failure: %s", expr, err)
+	}
+	ast := AstParse(lexTokens)
+	if result := Format(ast); result != expr {
+		t.Fatalf("need %s but got %s", expr, result)
+	}
+	term := ast.Left.Left.Left
+	if term.Left.Type != token.IDENT || term.Right.Type != token.DATE {
+		t.Fatalf("need ident == date but got %s == %s", term.Left.Type, term.Right.Type)
+	}
+	if arg := ast.Left.Left.Right.Left.Args[0]; arg.Type != token.IDENT {
+		t.Fatalf("need ident argument but got %s", arg.Type)
+	}
+	quant := ast.Left.Right
+	if quant.Left.Type != token.IDENT || quant.Right.Left.Type != token.IDENT || quant.Right.Right.Type != token.DATE {
+		t.Fatalf("need quant path and condition key ident but got %s %s", quant.Left.Type, quant.Right.Left.Type)
+	}
+}
+
+func TestLexParseConditionBoundary(t *testing.T) {
+	// 单词形式的条件需要完整匹配，以条件开头的字段名解析为 IDENT
+	expr := "likes == 1 && icontainsX == 1 && likeCount > 0 && betweenness < 1 && inCidrList exists && index in [1] && regex reg 'x'"
+	lexTokens, err := LexParse(TokensRead(expr))
+	if err != nil {
+		t.Fatalf("testCase %s parse failure: %s", expr, err)
+	}
+	if result := Format(AstParse(lexTokens)); result != expr {
+		t.Fatalf("need %s but got %s", expr, result)
+	}
+}
+
+func TestLexParseNot(t *testing.T) {
+	// not 后面需要是空字符、( 或者 !，以 not 开头的字段名解析为 IDENT
+	expr := "not.x == 1 && not-found == 1 && !not (a == 1) && !!(b exists)"
+	lexTokens, err := LexParse(TokensRead(expr))
+	if err != nil {
+		t.Fatalf("testCase %s parse failure: %s", expr, err)
+	}
+	if lexTokens[0].Type != token.IDENT || lexTokens[4].Type != token.IDENT {
+		t.Fatalf("need ident but got %s %s", lexTokens[0].Type, lexTokens[4].Type)
+	}
+	if result := Format(AstParse(lexTokens)); result != expr {
+		t.Fatalf("need %s but got %s", expr, result)
+	}
+	for _, expr := range []string{"not(a == 1)", "not!(a == 1)", "not\ta == 1"} {
+		if lexTokens, err := LexParse(TokensRead(expr)); err != nil || lexTokens[0].Type != token.NOT {
+			t.Fatalf("testCase %s need not but got %v", expr, err)
+		}
+	}
+}
+
+func TestLexParseCall(t *testing.T) {
+	testCases := []struct {
+		Expr   string
+		Lex    string
+		Result string
+	}{
+		{
+			Expr:   "lower(user) == 'admin'",
+			Lex:    "lower(user) == 'admin'",
+			Result: "(lower(user) == admin)",
+		},
+		{
+			Expr:   "len( tags ) > 3 && ts > now ('-1h')",
+			Lex:    "len(tags) > 3.000000 && ts > now('-1h')",
+			Result: "((len(tags) > 3) && (ts > now(-1h)))",
+		},
+		{
+			Expr:   "(now() > '2020-01-01') || len(lower(user), 1) exists",
+			Lex:    "( now() > '2020-01-01' ) || len(lower(user), 1.000000) exists",
+			Result: "((now() > 2020-01-01) || (len(lower(user), 1) exists <nil>))",
+		},
+	}
+	for _, testCase := range testCases {
+		results, err := LexParse(TokensRead(testCase.Expr))
+		if err != nil {
+			t.Fatalf("testCase %s parse failure: %s", testCase.Expr, err)
+		}
+		if lex := LexToExpr(results); lex != testCase.Lex {
+			t.Fatalf("testCase %s need %s but got %s", testCase.Expr, testCase.Lex, lex)
+		}
+		if result := printAst(AstParse(results)); result != testCase.Result {
+			t.Fatalf("testCase %s need %s but got %s", testCase.Expr, testCase.Result, result)
+		}
+	}
+}
+
+func TestLexParseQuant(t *testing.T) {
+	testCases := []struct {
+		Expr   string
+		Lex    string
+		Result string
+	}{
+		{
+			Expr:   "any(processes, name == 'sh') && user == 'root'",
+			Lex:    "any(processes, name == 'sh') && user == 'root'",
+			Result: "(any(processes, (name == sh)) && (user == root))",
+		},
+		{
+			Expr:   "!all( processes , (pid > 1 || lower(name) in ['a']) && any(args, v == '-c'))",
+			Lex:    "! all(processes, ( pid > 1.000000 || lower(name) in ['a'] ) && any(args, v == '-c'))",
+			Result: "!all(processes, (((pid > 1) || (lower(name) in [a])) && any(args, (v == -c))))",
+		},
+		{
+			Expr:   "a == any(b)",
+			Lex:    "a == any(b)",
+			Result: "(a == any(b))",
+		},
+	}
+	for _, testCase := range testCases {
+		results, err := LexParse(TokensRead(testCase.Expr))
+		if err != nil {
+			t.Fatalf("testCase %s parse failure: %s", testCase.Expr, err)
+		}
+		if lex := LexToExpr(results); lex != testCase.Lex {
+			t.Fatalf("testCase %s need %s but got %s", testCase.Expr, testCase.Lex, lex)
+		}
+		if result := printAst(AstParse(results)); result != testCase.Result {
+			t.Fatalf("testCase %s need %s but got %s", testCase.Expr, testCase.Result, result)
+		}
+	}
+}
+
 func TestAstParse(t *testing.T) {
 	testCases := []struct {
 		Expr   string
@@ -84,6 +442,123 @@ func TestAstParse(t *testing.T) {
 	}
 }
 
+func TestAstParseNot(t *testing.T) {
+	testCases := []struct {
+		Expr   string
+		Result string
+	}{
+		{
+			Expr:   "!a == 1",
+			Result: "!(a == 1)",
+		},
+		{
+			Expr:   "not (a == 1 || b == 2) && c == 3",
+			Result: "(not((a == 1) || (b == 2)) && (c == 3))",
+		},
+		{
+			Expr:   "!!(a == 1)",
+			Result: "!!(a == 1)",
+		},
+		{
+			Expr:   "!a exists && b == null",
+			Result: "(!(a exists <nil>) && (b == <nil>))",
+		},
+	}
+	for _, testCase := range testCases {
+		results, err := LexParse(TokensRead(testCase.Expr))
+		if err != nil {
+			t.Fatalf("testCase %s parse failure: %s", testCase.Expr, err)
+		}
+		result := printAst(AstParse(results))
+		if result != testCase.Result {
+			t.Fatalf("testCase %s need %s but got %s", testCase.Expr, testCase.Result, result)
+		}
+	}
+}
+
+func TestAstParsePrecedence(t *testing.T) {
+	testCases := []struct {
+		Expr   string
+		Opts   []AstOption
+		Result string
+	}{
+		{
+			Expr:   "a == 1 || b == 2 && c == 3",
+			Result: "((a == 1) || ((b == 2) && (c == 3)))",
+		},
+		{
+			Expr:   "a == 1 && b == 2 || c == 3",
+			Result: "(((a == 1) && (b == 2)) || (c == 3))",
+		},
+		{
+			Expr:   "a == 1 || b == 2 && c == 3 || d == 4",
+			Result: "(((a == 1) || ((b == 2) && (c == 3))) || (d == 4))",
+		},
+		{
+			Expr:   "a == 1 && b == 2 && c == 3",
+			Result: "(((a == 1) && (b == 2)) && (c == 3))",
+		},
+		{
+			Expr:   "(a == 1 || b == 2) && c == 3",
+			Result: "(((a == 1) || (b == 2)) && (c == 3))",
+		},
+		{
+			Expr:   "!a == 1 || b == 2 && !(c == 3 || d == 4)",
+			Result: "(!(a == 1) || ((b == 2) && !((c == 3) || (d == 4))))",
+		},
+		{
+			Expr:   "a == 1 || b == 2 && c == 3",
+			Opts:   []AstOption{WithLeftAssociative()},
+			Result: "(((a == 1) || (b == 2)) && (c == 3))",
+		},
+		{
+			Expr:   "a == 1 || (b == 2 || c == 3 && d == 4)",
+			Opts:   []AstOption{WithLeftAssociative()},
+			Result: "((a == 1) || (((b == 2) || (c == 3)) && (d == 4)))",
+		},
+	}
+	for _, testCase := range testCases {
+		results, err := LexParse(TokensRead(testCase.Expr))
+		if err != nil {
+			t.Fatalf("testCase %s parse failure: %s", testCase.Expr, err)
+		}
+		result := printAst(AstParse(results, testCase.Opts...))
+		if result != testCase.Result {
+			t.Fatalf("testCase %s need %s but got %s", testCase.Expr, testCase.Result, result)
+		}
+	}
+}
+
+// printAst 把语法树打印成带括号的表达式，用于比较语法树结构
+func printAst(ast *AstNode) string {
+	if ast == nil {
+		return ""
+	}
+	switch ast.Type {
+	case token.OPERATOR:
+		return fmt.Sprintf("(%s %v %s)", printAst(ast.Left), ast.Value, printAst(ast.Right))
+	case token.NOT:
+		return fmt.Sprintf("%v%s", ast.Value, printAst(ast.Left))
+	case token.CONDITION:
+		return fmt.Sprintf("(%s %v %s)", printOperand(ast.Left), ast.Value, printOperand(ast.Right))
+	case token.QUANT:
+		return fmt.Sprintf("%v(%v, %s)", ast.Value, ast.Left.Value, printAst(ast.Right))
+	}
+	return ""
+}
+
+// printOperand 打印条件两侧的节点，函数调用打印参数
+func printOperand(ast *AstNode) string {
+	if ast.Type != token.FUNC {
+		return fmt.Sprintf("%v", ast.Value)
+	}
+	args := make([]string, 0, len(ast.Args))
+	for _, arg := range ast.Args {
+		args = append(args, printOperand(arg))
+	}
+	return fmt.Sprintf("%v(%s)", ast.Value, strings.Join(args, ", "))
+}
+
 func compareTokenObj(from, to []*LexNode) bool {
 	if from == nil && to == nil {
 		return true
@@ -113,3 +588,57 @@ func PrintTokenObj(t *testing.T, obj []*LexNode) {
 		}
 	}
 }
+
+func TestLexToExprEscape(t *testing.T) {
+	testCases := []struct {
+		Expr   string
+		Value  any
+		Result string
+	}{
+		{
+			Expr:   `msg == 'it\'s "ok"'`,
+			Value:  `it's "ok"`,
+			Result: `msg == 'it\'s "ok"'`,
+		},
+		{
+			Expr:   `msg == "line\nnext!"`,
+			Value:  "line\nnext!",
+			Result: `msg == 'line\nnext!'`,
+		},
+		{
+			Expr:   "host reg `^web-\\d+\\.local$`",
+			Value:  `^web-\d+\.local$`,
+			Result: "host reg `^web-\\d+\\.local$`",
+		},
+		{
+			Expr:   `host reg '^web-\d+\\'`,
+			Value:  `^web-\d+\`,
+			Result: "host reg `^web-\\d+\\`",
+		},
+		{
+			Expr:   "user in ['a`b\\\\', \"it's\"]",
+			Value:  []any{"a`b\\", "it's"},
+			Result: `user in ['a` + "`" + `b\\', "it's"]`,
+		},
+	}
+	for _, testCase := range testCases {
+		results, err := LexParse(TokensRead(testCase.Expr))
+		if err != nil {
+			t.Fatalf("testCase %s parse failure: %s", testCase.Expr, err)
+		}
+		if value := results[2].Value; !reflect.DeepEqual(value, testCase.Value) {
+			t.Fatalf("testCase %s need %q but got %q", testCase.Expr, testCase.Value, value)
+		}
+		result := LexToExpr(results)
+		if result != testCase.Result {
+			t.Fatalf("testCase %s need %s but got %s", testCase.Expr, testCase.Result, result)
+		}
+		again, err := LexParse(TokensRead(result))
+		if err != nil {
+			t.Fatalf("testCase %s parse %s failure: %s", testCase.Expr, result, err)
+		}
+		if !reflect.DeepEqual(again[2].Value, testCase.Value) {
+			t.Fatalf("testCase %s round trip need %q but got %q", testCase.Expr, testCase.Value, again[2].Value)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package expression
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+// Format 把语法树转换回表达式
+//
+//	只在需要时添加括号，按默认优先级（&& 高于 ||）输出；取反的子表达式总是带括号，如：!(a == 1)、not (a == 1)
+func Format(ast *AstNode) string {
+	if ast == nil {
+		return ""
+	}
+	var b strings.Builder
+	formatNode(&b, ast)
+	return b.String()
+}
+
+// formatNode 格式化节点
+func formatNode(b *strings.Builder, ast *AstNode) {
+	switch ast.Type {
+	case token.OPERATOR:
+		prec := token.OperatorPrecedence[ast.Value.(string)]
+		// 左侧优先级低时加括号；右侧优先级不高于当前时加括号，保证解析出相同的语法树
+		formatChild(b, ast.Left, func(childPrec int) bool { return childPrec < prec })
+		b.WriteByte(' ')
+		b.WriteString(ast.Value.(string))
+		b.WriteByte(' ')
+		formatChild(b, ast.Right, func(childPrec int) bool { return childPrec <= prec })
+	case token.NOT:
+		if ast.Value == "not" {
+			b.WriteString("not ")
+		} else {
+			b.WriteByte('!')
+		}
+		if ast.Left != nil && ast.Left.Type == token.NOT {
+			formatNode(b, ast.Left)
+			return
+		}
+		b.WriteByte('(')
+		if ast.Left != nil {
+			formatNode(b, ast.Left)
+		}
+		b.WriteByte(')')
+	case token.CONDITION:
+		if ast.Left != nil {
+			b.WriteString(formatOperand(ast.Left))
+		}
+		b.WriteByte(' ')
+		b.WriteString(ast.Value.(string))
+		// 一元条件没有值
+		if ast.Right == nil || token.REG_UNARY.MatchString(ast.Value.(string)) {
+			return
+		}
+		b.WriteByte(' ')
+		b.WriteString(formatOperand(ast.Right))
+	case token.QUANT:
+		b.WriteString(token.FuncEncoder.Encode(ast.Value))
+		if ast.Left != nil {
+			b.WriteString(formatOperand(ast.Left))
+		}
+		b.WriteString(", ")
+		if ast.Right != nil {
+			formatNode(b, ast.Right)
+		}
+		b.WriteByte(')')
+	}
+}
+
+// formatChild 格式化逻辑关系的子节点，needParen 判断是否需要括号
+func formatChild(b *strings.Builder, child *AstNode, needParen func(childPrec int) bool) {
+	if child == nil {
+		return
+	}
+	if child.Type == token.OPERATOR && needParen(token.OperatorPrecedence[child.Value.(string)]) {
+		b.WriteByte('(')
+		formatNode(b, child)
+		b.WriteByte(')')
+		return
+	}
+	formatNode(b, child)
+}
+
+// formatOperand 格式化条件两侧的节点
+func formatOperand(node *AstNode) string {
+	switch node.Type {
+	case token.IDENT:
+		return node.Value.(string)
+	case token.FUNC:
+		args := make([]string, 0, len(node.Args))
+		for _, arg := range node.Args {
+			args = append(args, formatOperand(arg))
+		}
+		return token.FuncEncoder.Encode(node.Value) + strings.Join(args, ", ") + ")"
+	case token.ARRAY:
+		arr, _ := node.Value.([]any)
+		items := make([]string, 0, len(arr))
+		for _, item := range arr {
+			items = append(items, formatValue(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case token.NULL:
+		return token.NullEncoder.Encode(node.Value)
+	case token.FIELD:
+		return token.FieldEncoder.Encode(node.Value)
+	}
+	return formatValue(node.Value)
+}
+
+// formatValue 格式化字面量，数字使用最短表示
+func formatValue(val any) string {
+	switch v := val.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return token.BoolEncoder.Encode(v)
+	case string:
+		return token.StringEncoder.Encode(v)
+	case token.Date:
+		return token.DateEncoder.Encode(v)
+	}
+	return ""
+}
+
+// Canonicalize 规范化语法树，用于去重、比较
+//
+//	合并相同逻辑关系的嵌套：(a && b) && c => a && b && c
+//	逻辑关系的操作数按格式化后的表达式排序：b && a => a && b
+//	取反统一使用 !：not a == 1 => !(a == 1)
+//	数组元素条件只规范化子表达式
+//
+//	返回新的语法树，不修改原来的语法树
+func Canonicalize(ast *AstNode) *AstNode {
+	if ast == nil {
+		return nil
+	}
+	switch ast.Type {
+	case token.OPERATOR:
+		op := ast.Value.(string)
+		operands := flatten(ast, op, nil)
+		items := make([]*AstNode, 0, len(operands))
+		keys := make(map[*AstNode]string, len(operands))
+		for _, operand := range operands {
+			item := Canonicalize(operand)
+			items = append(items, item)
+			keys[item] = Format(item)
+		}
+		sort.SliceStable(items, func(i, j int) bool {
+			return keys[items[i]] < keys[items[j]]
+		})
+		// 重新组装成左结合的语法树
+		tree := items[0]
+		for _, item := range items[1:] {
+			tree = &AstNode{
+				Type:  ast.Type,
+				Value: ast.Value,
+				Left:  tree,
+				Right: item,
+			}
+		}
+		return tree
+	case token.NOT:
+		return &AstNode{
+			Type:  ast.Type,
+			Value: "!",
+			Left:  Canonicalize(ast.Left),
+		}
+	case token.QUANT:
+		return &AstNode{
+			Type:  ast.Type,
+			Value: ast.Value,
+			Left:  ast.Left,
+			Right: Canonicalize(ast.Right),
+		}
+	}
+	return ast
+}
+
+// flatten 展开相同逻辑关系的操作数
+func flatten(ast *AstNode, op string, operands []*AstNode) []*AstNode {
+	if ast != nil && ast.Type == token.OPERATOR && ast.Value == op {
+		operands = flatten(ast.Left, op, operands)
+		return flatten(ast.Right, op, operands)
+	}
+	return append(operands, ast)
+}
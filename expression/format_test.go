@@ -0,0 +1,102 @@
+package expression
+
+import (
+	"reflect"
+	"testing"
+)
+
+func parseAst(t *testing.T, expr string) *AstNode {
+	lexTokens, err := LexParse(TokensRead(expr))
+	if err != nil {
+		t.Fatalf("testCase %s parse failure: %s", expr, err)
+	}
+	return AstParse(lexTokens)
+}
+
+func TestFormat(t *testing.T) {
+	testCases := []struct {
+		Expr   string
+		Result string
+	}{
+		{
+			Expr:   "((a == 1)) && (b != 'x' || c >= 2.50)",
+			Result: "a == 1 && (b != 'x' || c >= 2.5)",
+		},
+		{
+			Expr:   "ts between [now-1d/d,2024-01-01T08:00:00.5+08:00] && ts > now",
+			Result: "ts between [now-1d/d, 2024-01-01T08:00:00.5+08:00] && ts > now",
+		},
+		{
+			Expr:   "a == 1 || b == 2 && c == 3",
+			Result: "a == 1 || b == 2 && c == 3",
+		},
+		{
+			Expr:   "(a == 1 || b == 2) && c == 3",
+			Result: "(a == 1 || b == 2) && c == 3",
+		},
+		{
+			Expr:   "a == 1 && (b == 2 && c == 3)",
+			Result: "a == 1 && (b == 2 && c == 3)",
+		},
+		{
+			Expr:   "not a == 1 && !(b == 2 || c exists) && !!(d notExists)",
+			Result: "not (a == 1) && !(b == 2 || c exists) && !!(d notExists)",
+		},
+		{
+			Expr:   `lower(user) in ['a', "it's", true, -1] && x == null && y > $z && ts < now('-1h')`,
+			Result: `lower(user) in ['a', "it's", true, -1] && x == null && y > $z && ts < now('-1h')`,
+		},
+		{
+			Expr:   "any( processes ,(name == 'sh' || pid < 10) ) || !all(processes, any(args, v == '-c'))",
+			Result: "any(processes, name == 'sh' || pid < 10) || !(all(processes, any(args, v == '-c')))",
+		},
+	}
+	for _, testCase := range testCases {
+		ast := parseAst(t, testCase.Expr)
+		result := Format(ast)
+		if result != testCase.Result {
+			t.Fatalf("testCase %s need %s but got %s", testCase.Expr, testCase.Result, result)
+		}
+		if !reflect.DeepEqual(parseAst(t, result), ast) {
+			t.Fatalf("testCase %s round trip %s got a different ast", testCase.Expr, result)
+		}
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	testCases := []struct {
+		Exprs  []string
+		Result string
+	}{
+		{
+			Exprs: []string{
+				"c == 3 && (b == 2 && a == 1)",
+				"(a == 1 && b == 2) && c == 3",
+				"b == 2 && c == 3 && a == 1",
+			},
+			Result: "a == 1 && b == 2 && c == 3",
+		},
+		{
+			Exprs: []string{
+				"!(y == 1 || x == 1) || b == 2 && a == 1",
+				"a == 1 && b == 2 || not (x == 1 || y == 1)",
+			},
+			Result: "!(x == 1 || y == 1) || a == 1 && b == 2",
+		},
+		{
+			Exprs: []string{
+				"any(processes, pid > 1 && name == 'sh') && a == 1",
+				"a == 1 && any(processes, name == 'sh' && pid > 1)",
+			},
+			Result: "a == 1 && any(processes, name == 'sh' && pid > 1)",
+		},
+	}
+	for _, testCase := range testCases {
+		for _, expr := range testCase.Exprs {
+			result := Format(Canonicalize(parseAst(t, expr)))
+			if result != testCase.Result {
+				t.Fatalf("testCase %s need %s but got %s", expr, testCase.Result, result)
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Package sqlitetest 测试用的内存 sqlite，供 sqlite 相关执行器的测试共用
+package sqlitetest
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jummyliu/pkg/db/sqlitebuilder"
+)
+
+// regDBName 数据库名称中不能使用的字符
+var regDBName = regexp.MustCompile(`\W`)
+
+// New 创建内存数据库，注册 regexp 函数，并使用 ddl 建表，测试结束时关闭
+//
+//	数据库名称为测试名称，子测试的名称包含 / #，不能直接用于 uri
+func New(t testing.TB, ddl string) *sqlitebuilder.DBConnect {
+	db, err := sqlitebuilder.New(
+		sqlitebuilder.WithRegexp(),
+		sqlitebuilder.WithDBFilePath(fmt.Sprintf("file:%s?mode=memory&cache=shared", regDBName.ReplaceAllString(t.Name(), "_"))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, _, err := db.Exec(context.Background(), ddl); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Insert 把 docs 写入 table，按 columns 的顺序取值，不存在的字段写入 NULL
+func Insert(t testing.TB, db *sqlitebuilder.DBConnect, table string, columns []string, docs []map[string]any) {
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		table,
+		strings.Join(columns, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "),
+	)
+	ctx := context.Background()
+	for _, doc := range docs {
+		params := make([]any, 0, len(columns))
+		for _, column := range columns {
+			params = append(params, doc[column])
+		}
+		if _, _, err := db.Exec(ctx, query, params...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// QueryIDs 执行 SQL 条件，返回匹配的 id，按 id 排序
+func QueryIDs(t testing.TB, db *sqlitebuilder.DBConnect, table string, sql string, params []any) []int {
+	results, _, err := db.Query(context.Background(), fmt.Sprintf("SELECT id FROM %s WHERE %s ORDER BY id", table, sql), params...)
+	if err != nil {
+		t.Fatalf("sql %s %v query failure: %s", sql, params, err)
+	}
+	ids := []int{}
+	for _, item := range results {
+		var id int
+		fmt.Sscan(fmt.Sprint(item["id"]), &id)
+		ids = append(ids, id)
+	}
+	return ids
+}
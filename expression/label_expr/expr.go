@@ -0,0 +1,141 @@
+package label_expr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+// regLabel label 名，只能包含字母、数字、下划线
+var regLabel = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+type Executor struct {
+	FnMap map[string]map[token.Token]ConditionFn
+
+	// KeyMap 字段映射
+	// 	存在映射 => key 转换为映射值，如：host => instance
+	KeyMap map[string]string
+}
+
+var StdExecutor = New(nil, nil)
+
+func New(fnMap map[string]map[token.Token]ConditionFn, keyMap map[string]string) *Executor {
+	if fnMap == nil {
+		fnMap = DefaultFnMap
+	}
+	return &Executor{
+		FnMap:  fnMap,
+		KeyMap: keyMap,
+	}
+}
+
+// DoExpr 执行表达式，返回 PromQL/LogQL 的 label 选择器，如：{a="x", b=~"y.*"}
+//
+//	只支持 && 连接的条件，以及对单个条件取反；|| 等无法转换的表达式返回错误
+//	字符串的 ==、!= 区分大小写，与 cond_expr 不同，见 DefaultFnMap
+func (e *Executor) DoExpr(expr string, prefix, suffix string) (selector string, keys []string, err error) {
+	lexTokens, err := expression.LexParse(expression.TokensRead(expr))
+	if err != nil {
+		return "", nil, err
+	}
+	ast := expression.AstParse(lexTokens)
+	matchers, err := e.DoAst(ast, prefix, suffix)
+	if err != nil {
+		return "", nil, err
+	}
+	items := make([]string, 0, len(matchers))
+	for _, matcher := range matchers {
+		items = append(items, matcher.String())
+		keys = append(keys, matcher.Name)
+	}
+	return fmt.Sprintf("{%s}", strings.Join(items, ", ")), keys, nil
+}
+
+// DoAst 执行 ast，返回 matcher 列表
+func (e *Executor) DoAst(ast *expression.AstNode, prefix, suffix string) ([]*Matcher, error) {
+	if ast == nil {
+		return nil, fmt.Errorf("empty expression")
+	}
+	switch ast.Type {
+	case token.OPERATOR:
+		// label 选择器只能表示 &&
+		if ast.Value != "&&" {
+			return nil, fmt.Errorf("unsupported operator: %v", ast.Value)
+		}
+		left, err := e.DoAst(ast.Left, prefix, suffix)
+		if err != nil {
+			return nil, err
+		}
+		right, err := e.DoAst(ast.Right, prefix, suffix)
+		if err != nil {
+			return nil, err
+		}
+		return append(left, right...), nil
+	case token.NOT:
+		// 只支持对单个条件取反
+		if ast.Left == nil || ast.Left.Type != token.CONDITION {
+			return nil, fmt.Errorf("unsupported not: only a single condition can be negated")
+		}
+		matcher, err := e.DoTerm(ast.Left, prefix, suffix)
+		if err != nil {
+			return nil, err
+		}
+		matcher.Type = matcher.Type.Negate()
+		return []*Matcher{matcher}, nil
+	case token.ILLEGAL:
+		return nil, expression.CheckAst(ast)
+	case token.CONDITION:
+		matcher, err := e.DoTerm(ast, prefix, suffix)
+		if err != nil {
+			return nil, err
+		}
+		return []*Matcher{matcher}, nil
+	}
+	return nil, fmt.Errorf("unsupported node: %s", ast.Type)
+}
+
+// DoTerm 执行 term
+func (e *Executor) DoTerm(term *expression.AstNode, prefix, suffix string) (*Matcher, error) {
+	// 判断是否有 left 和 right
+	if term.Left == nil || term.Right == nil {
+		return nil, fmt.Errorf("illegal term: %v", term.Value)
+	}
+	// 判断 left，即 key 的类型，不支持函数调用
+	key, ok := term.Left.Value.(string)
+	if !ok || term.Left.Type == token.FUNC {
+		return nil, fmt.Errorf("illegal key: %v", term.Left.Value)
+	}
+	// 判断 condition 的类型
+	op, ok := term.Value.(string)
+	if !ok {
+		return nil, fmt.Errorf("illegal condition: %v", term.Value)
+	}
+	fn, ok := e.FnMap[op][term.Right.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported condition: %s %s", op, term.Right.Type)
+	}
+	key = e.buildKey(key, prefix, suffix)
+	if !regLabel.MatchString(key) {
+		return nil, fmt.Errorf("illegal label: %s", key)
+	}
+	return fn(key, term.Right.Value)
+}
+
+// buildKey 字段映射，并拼接前缀、后缀
+//
+//	label 名不能包含 .，前缀、后缀使用 _ 拼接
+func (e *Executor) buildKey(key string, prefix, suffix string) string {
+	if _, ok := e.KeyMap[key]; ok {
+		key = e.KeyMap[key]
+	}
+	if len(prefix) > 0 {
+		key = fmt.Sprintf("%s_%s", prefix, key)
+	}
+	if len(suffix) > 0 {
+		key = fmt.Sprintf("%s_%s", key, suffix)
+	}
+	return key
+}
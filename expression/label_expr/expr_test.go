@@ -0,0 +1,84 @@
+package label_expr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDoExpr(t *testing.T) {
+	testCases := []struct {
+		Expr     string
+		Selector string
+		Keys     []string
+	}{
+		{
+			Expr:     `job == "api" && host reg 'web-\d+' && code != 200`,
+			Selector: `{job="api", instance=~"web-\\d+", code!="200"}`,
+			Keys:     []string{"job", "instance", "code"},
+		},
+		{
+			Expr:     "level in 'error,warn' && env notIn ['dev', 'a.b'] && !(path reg '/health.*')",
+			Selector: `{level=~"error|warn", env!~"dev|a\\.b", path!~"/health.*"}`,
+			Keys:     []string{"level", "env", "path"},
+		},
+		{
+			Expr:     `cluster exists && pod == null && !(msg == "it's")`,
+			Selector: `{cluster!="", pod="", msg!="it's"}`,
+			Keys:     []string{"cluster", "pod", "msg"},
+		},
+		{
+			Expr:     "job iequals 'API' && host like 'web-*.?' && !(path icontains '/v1.')",
+			Selector: `{job=~"(?i)API", instance=~"(?i)(?s)^web-.*\\..$", path!~"(?i).*/v1\\..*"}`,
+			Keys:     []string{"job", "instance", "path"},
+		},
+	}
+	executor := New(nil, map[string]string{"host": "instance"})
+	for _, testCase := range testCases {
+		selector, keys, err := executor.DoExpr(testCase.Expr, "", "")
+		if err != nil {
+			t.Fatalf("testCase %s DoExpr failure: %s", testCase.Expr, err)
+		}
+		if selector != testCase.Selector {
+			t.Fatalf("testCase %s need %s but got %s", testCase.Expr, testCase.Selector, selector)
+		}
+		if !reflect.DeepEqual(keys, testCase.Keys) {
+			t.Fatalf("testCase %s need keys %v but got %v", testCase.Expr, testCase.Keys, keys)
+		}
+	}
+}
+
+// TestDoExprCaseSensitive 字符串的 ==、!=、in 区分大小写，与 cond_expr 的 EqualFold 不同
+func TestDoExprCaseSensitive(t *testing.T) {
+	testCases := map[string]string{
+		"job == 'API' && env != 'Prod'":              `{job="API", env!="Prod"}`,
+		"level in ['Error', 'WARN']":                 `{level=~"Error|WARN"}`,
+		"job iequals 'API' && !(env iequals 'Prod')": `{job=~"(?i)API", env!~"(?i)Prod"}`,
+	}
+	for expr, need := range testCases {
+		selector, _, err := StdExecutor.DoExpr(expr, "", "")
+		if err != nil {
+			t.Fatalf("testCase %s DoExpr failure: %s", expr, err)
+		}
+		if selector != need {
+			t.Fatalf("testCase %s need %s but got %s", expr, need, selector)
+		}
+	}
+}
+
+func TestDoExprUnsupported(t *testing.T) {
+	testCases := []string{
+		"job == 'a' || job == 'b'",
+		"!(job == 'a' && env == 'b')",
+		"job contains 'a'",
+		"code > 200",
+		"job == $env",
+		"lower(job) == 'a'",
+		"job in []",
+		"job reg '('",
+	}
+	for _, expr := range testCases {
+		if selector, _, err := StdExecutor.DoExpr(expr, "", ""); err == nil {
+			t.Fatalf("testCase %s need error but got %s", expr, selector)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package label_expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+// MatchType label 匹配类型
+type MatchType string
+
+const (
+	MatchEqual     MatchType = "="
+	MatchNotEqual  MatchType = "!="
+	MatchRegexp    MatchType = "=~"
+	MatchNotRegexp MatchType = "!~"
+)
+
+// Negate 取反
+func (t MatchType) Negate() MatchType {
+	switch t {
+	case MatchEqual:
+		return MatchNotEqual
+	case MatchNotEqual:
+		return MatchEqual
+	case MatchRegexp:
+		return MatchNotRegexp
+	case MatchNotRegexp:
+		return MatchRegexp
+	}
+	return t
+}
+
+// Matcher label 匹配器
+type Matcher struct {
+	Name  string
+	Type  MatchType
+	Value string
+}
+
+// String 如：a="x"、b=~"y.*"
+func (m *Matcher) String() string {
+	return fmt.Sprintf("%s%s%s", m.Name, m.Type, strconv.Quote(m.Value))
+}
+
+// ConditionFn 条件函数，返回 label 匹配器，不支持时返回错误
+type ConditionFn func(key string, value any) (*Matcher, error)
+
+// DefaultFnMap 默认的条件函数
+//
+//	==、!=、in、notIn 转换为 label 的等值、正则匹配，区分大小写，与 cond_expr 不同；忽略大小写时使用 iequals 等
+var DefaultFnMap = map[string]map[token.Token]ConditionFn{
+	"==": {
+		token.NUM:    match(MatchEqual),
+		token.BOOL:   match(MatchEqual),
+		token.STRING: match(MatchEqual),
+		token.NULL:   notExists,
+	},
+	"!=": {
+		token.NUM:    match(MatchNotEqual),
+		token.BOOL:   match(MatchNotEqual),
+		token.STRING: match(MatchNotEqual),
+		token.NULL:   exists,
+	},
+	"iequals": {
+		token.STRING: foldMatch("%s"),
+	},
+	"icontains": {
+		token.STRING: foldMatch(".*%s.*"),
+	},
+	"istartsWith": {
+		token.STRING: foldMatch("%s.*"),
+	},
+	"iendsWith": {
+		token.STRING: foldMatch(".*%s"),
+	},
+	"like": {
+		token.STRING: wildcard(MatchRegexp),
+	},
+	"notLike": {
+		token.STRING: wildcard(MatchNotRegexp),
+	},
+	"reg": {
+		token.STRING: reg,
+	},
+	"in": {
+		token.STRING: in(MatchRegexp),
+		token.ARRAY:  inArray(MatchRegexp),
+	},
+	"notIn": {
+		token.STRING: in(MatchNotRegexp),
+		token.ARRAY:  inArray(MatchNotRegexp),
+	},
+	"is": {
+		token.NULL: notExists,
+	},
+	"isNot": {
+		token.NULL: exists,
+	},
+	"exists": {
+		token.NULL: exists,
+	},
+	"notExists": {
+		token.NULL: notExists,
+	},
+}
+
+// formatValue 把值转换为 label 值
+func formatValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("unsupported value: %T", value)
+}
+
+// match 相等、不等
+func match(t MatchType) ConditionFn {
+	return func(key string, value any) (*Matcher, error) {
+		val, err := formatValue(value)
+		if err != nil {
+			return nil, err
+		}
+		return &Matcher{Name: key, Type: t, Value: val}, nil
+	}
+}
+
+// exists label 不存在时等价于空字符串，使用 key!=""
+func exists(key string, value any) (*Matcher, error) {
+	return &Matcher{Name: key, Type: MatchNotEqual, Value: ""}, nil
+}
+
+// notExists 使用 key=""
+func notExists(key string, value any) (*Matcher, error) {
+	return &Matcher{Name: key, Type: MatchEqual, Value: ""}, nil
+}
+
+// reg 正则匹配，label 的正则是全匹配的
+func reg(key string, value any) (*Matcher, error) {
+	val, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("reg need a string but got %T", value)
+	}
+	if _, err := regexp.Compile(val); err != nil {
+		return nil, err
+	}
+	return &Matcher{Name: key, Type: MatchRegexp, Value: val}, nil
+}
+
+// foldMatch 忽略大小写的匹配，转换为正则，format 中的 %s 为转义后的值
+func foldMatch(format string) ConditionFn {
+	return func(key string, value any) (*Matcher, error) {
+		val, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("need a string but got %T", value)
+		}
+		return &Matcher{Name: key, Type: MatchRegexp, Value: "(?i)" + fmt.Sprintf(format, regexp.QuoteMeta(val))}, nil
+	}
+}
+
+// wildcard 通配符匹配，忽略大小写，转换为正则
+func wildcard(t MatchType) ConditionFn {
+	return func(key string, value any) (*Matcher, error) {
+		val, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("like need a string but got %T", value)
+		}
+		return &Matcher{Name: key, Type: t, Value: "(?i)" + expression.WildcardToRegexp(val)}, nil
+	}
+}
+
+// in 逗号分隔的值，转换为正则 a|b
+func in(t MatchType) ConditionFn {
+	fn := inArray(t)
+	return func(key string, value any) (*Matcher, error) {
+		val, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("in need a string but got %T", value)
+		}
+		values := strings.Split(val, ",")
+		arr := make([]any, 0, len(values))
+		for _, item := range values {
+			arr = append(arr, item)
+		}
+		return fn(key, arr)
+	}
+}
+
+// inArray 数组，转换为正则 a|b
+func inArray(t MatchType) ConditionFn {
+	return func(key string, value any) (*Matcher, error) {
+		arr, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("in need an array but got %T", value)
+		}
+		if len(arr) == 0 {
+			return nil, fmt.Errorf("in need a non-empty array")
+		}
+		items := make([]string, 0, len(arr))
+		for _, item := range arr {
+			val, err := formatValue(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, regexp.QuoteMeta(val))
+		}
+		return &Matcher{Name: key, Type: t, Value: strings.Join(items, "|")}, nil
+	}
+}
@@ -0,0 +1,149 @@
+package ldap_expr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+// regAttr ldap 属性名，不能包含过滤器中的特殊字符
+var regAttr = regexp.MustCompile(`^[a-zA-Z0-9][\w\-;.]*$`)
+
+type Executor struct {
+	FnMap map[string]map[token.Token]ConditionFn
+
+	// KeyMap 字段映射
+	// 	存在映射 => key 转换为映射值，如：user => sAMAccountName
+	KeyMap map[string]string
+}
+
+var StdExecutor = New(nil, nil)
+
+func New(fnMap map[string]map[token.Token]ConditionFn, keyMap map[string]string) *Executor {
+	if fnMap == nil {
+		fnMap = DefaultFnMap
+	}
+	return &Executor{
+		FnMap:  fnMap,
+		KeyMap: keyMap,
+	}
+}
+
+// DoExpr 执行表达式，返回 RFC 4515 过滤器，可以直接用于 ldapbuilder.DoSearch
+//
+//	不支持数组元素条件 any、all
+func (e *Executor) DoExpr(expr string, prefix, suffix string) (filter string, keys []string, err error) {
+	lexTokens, err := expression.LexParse(expression.TokensRead(expr))
+	if err != nil {
+		return "", nil, err
+	}
+	ast := expression.AstParse(lexTokens)
+	if err := expression.CheckQuant(ast); err != nil {
+		return "", nil, err
+	}
+	filter, keys = e.DoAst(ast, prefix, suffix)
+	return filter, keys, nil
+}
+
+// DoAst 执行 ast
+func (e *Executor) DoAst(ast *expression.AstNode, prefix, suffix string) (filter string, keys []string) {
+	if ast == nil {
+		return "", nil
+	}
+	switch ast.Type {
+	case token.OPERATOR:
+		// 复合转换
+		leftFilter, leftKeys := e.DoAst(ast.Left, prefix, suffix)
+		rightFilter, rightKeys := e.DoAst(ast.Right, prefix, suffix)
+		if len(leftFilter) == 0 || len(rightFilter) == 0 {
+			return "", nil
+		}
+		keys = append(keys, leftKeys...)
+		keys = append(keys, rightKeys...)
+		switch ast.Value.(string) {
+		case "&&":
+			return and(leftFilter, rightFilter), keys
+		case "||":
+			return or(leftFilter, rightFilter), keys
+		}
+	case token.NOT:
+		// 取反
+		subFilter, subKeys := e.DoAst(ast.Left, prefix, suffix)
+		if len(subFilter) == 0 {
+			return "", nil
+		}
+		return not(subFilter), subKeys
+	case token.ILLEGAL:
+		// 不合法的条件，不能退化为查询全部
+		return not("(objectClass=*)"), nil
+	case token.CONDITION:
+		// 单个表达式转换
+		return e.DoTerm(ast, prefix, suffix)
+	}
+	return "", nil
+}
+
+// DoTerm 执行 term
+func (e *Executor) DoTerm(term *expression.AstNode, prefix, suffix string) (filter string, keys []string) {
+	// 判断是否有 left 和 right
+	if term.Left == nil || term.Right == nil {
+		return "", nil
+	}
+	// 判断 left，即 key 的类型，不支持函数调用
+	if _, ok := term.Left.Value.(string); !ok || term.Left.Type == token.FUNC {
+		return "", nil
+	}
+	// 判断 condition 的类型
+	if _, ok := term.Value.(string); !ok {
+		return "", nil
+	}
+	fns, ok := e.FnMap[term.Value.(string)]
+	if !ok {
+		return "", nil
+	}
+	fn, ok := fns[term.Right.Type]
+	if !ok {
+		return "", nil
+	}
+	key := e.buildKey(term.Left.Value.(string), prefix, suffix)
+	if !regAttr.MatchString(key) {
+		return "", nil
+	}
+	filter = fn(key, term.Right.Value)
+	if len(filter) == 0 {
+		return "", nil
+	}
+	return filter, []string{key}
+}
+
+// buildKey 字段映射，并拼接前缀、后缀
+func (e *Executor) buildKey(key string, prefix, suffix string) string {
+	if _, ok := e.KeyMap[key]; ok {
+		key = e.KeyMap[key]
+	}
+	if len(prefix) > 0 {
+		key = fmt.Sprintf("%s.%s", prefix, key)
+	}
+	if len(suffix) > 0 {
+		key = fmt.Sprintf("%s.%s", key, suffix)
+	}
+	return key
+}
+
+// and (&(a)(b))
+func and(filters ...string) string {
+	return fmt.Sprintf("(&%s)", strings.Join(filters, ""))
+}
+
+// or (|(a)(b))
+func or(filters ...string) string {
+	return fmt.Sprintf("(|%s)", strings.Join(filters, ""))
+}
+
+// not (!(a))
+func not(filter string) string {
+	return fmt.Sprintf("(!%s)", filter)
+}
@@ -0,0 +1,72 @@
+package ldap_expr
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+func TestDoExpr(t *testing.T) {
+	testCases := []struct {
+		Expr   string
+		Filter string
+		Keys   []string
+	}{
+		{
+			Expr:   "user == 'a*b(c)' && mail endsWith '@example.com'",
+			Filter: `(&(sAMAccountName=a\2ab\28c\29)(mail=*@example.com))`,
+			Keys:   []string{"sAMAccountName", "mail"},
+		},
+		{
+			Expr:   "cn contains 'web' || cn startsWith 'db' || !(cn unEndsWith '-01')",
+			Filter: `(|(|(cn=*web*)(cn=db*))(!(!(cn=*-01))))`,
+			Keys:   []string{"cn", "cn", "cn"},
+		},
+		{
+			Expr:   "uidNumber >= 1000 && uidNumber < 2000 && enabled != false",
+			Filter: `(&(&(uidNumber>=1000)(&(uidNumber<=2000)(!(uidNumber=2000))))(!(enabled=FALSE)))`,
+			Keys:   []string{"uidNumber", "uidNumber", "enabled"},
+		},
+		{
+			Expr:   "ou in 'dev,ops' && user notIn ['admin', 'guest'] && cn in []",
+			Filter: `(&(&(|(ou=dev)(ou=ops))(!(|(sAMAccountName=admin)(sAMAccountName=guest))))(!(objectClass=*)))`,
+			Keys:   []string{"ou", "sAMAccountName", "cn"},
+		},
+		{
+			Expr:   "userAccountControl unContainsBit 2 && mail exists && manager == null",
+			Filter: `(&(&(!(userAccountControl:1.2.840.113556.1.4.803:=2))(mail=*))(!(manager=*)))`,
+			Keys:   []string{"userAccountControl", "mail", "manager"},
+		},
+		{
+			Expr: "cn reg '^web' || cn == $sn",
+		},
+		{
+			Expr:   "cn like 'web-**.exa\\*mple*' && mail notLike '*@*.org' && user iequals 'Admin'",
+			Filter: `(&(&(cn=web-*.exa\2ample*)(!(mail=*@*.org)))(sAMAccountName=Admin))`,
+			Keys:   []string{"cn", "mail", "sAMAccountName"},
+		},
+		{
+			Expr: "cn like 'web-0?'",
+		},
+	}
+	executor := New(nil, map[string]string{"user": "sAMAccountName"})
+	for _, testCase := range testCases {
+		filter, keys, err := executor.DoExpr(testCase.Expr, "", "")
+		if err != nil {
+			t.Fatalf("testCase %s DoExpr failure: %s", testCase.Expr, err)
+		}
+		if filter != testCase.Filter {
+			t.Fatalf("testCase %s need %s but got %s", testCase.Expr, testCase.Filter, filter)
+		}
+		if !reflect.DeepEqual(keys, testCase.Keys) {
+			t.Fatalf("testCase %s need keys %v but got %v", testCase.Expr, testCase.Keys, keys)
+		}
+		if len(filter) == 0 {
+			continue
+		}
+		if _, err := ldap.CompileFilter(filter); err != nil {
+			t.Fatalf("testCase %s compile filter %s failure: %s", testCase.Expr, filter, err)
+		}
+	}
+}
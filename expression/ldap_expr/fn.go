@@ -0,0 +1,316 @@
+package ldap_expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/token"
+	"github.com/jummyliu/pkg/number"
+)
+
+// ConditionFn 条件函数，返回 RFC 4515 过滤器，不支持时返回 ""
+type ConditionFn func(key string, value any) string
+
+// 位运算匹配规则
+const (
+	MatchingRuleBitAnd = "1.2.840.113556.1.4.803" // LDAP_MATCHING_RULE_BIT_AND，所有位都存在
+	MatchingRuleBitOr  = "1.2.840.113556.1.4.804" // LDAP_MATCHING_RULE_BIT_OR，任意一位存在
+)
+
+// 恒为 true、false 的过滤器，用于空数组
+const (
+	filterTrue  = "(objectClass=*)"
+	filterFalse = "(!(objectClass=*))"
+)
+
+var DefaultFnMap = map[string]map[token.Token]ConditionFn{
+	"==": {
+		token.NUM:    equal,
+		token.BOOL:   equal,
+		token.STRING: equal,
+		token.NULL:   notExists,
+	},
+	"!=": {
+		token.NUM:    unEqual,
+		token.BOOL:   unEqual,
+		token.STRING: unEqual,
+		token.NULL:   exists,
+	},
+	">=": {
+		token.NUM:    gte,
+		token.STRING: gte,
+	},
+	"<=": {
+		token.NUM:    lte,
+		token.STRING: lte,
+	},
+	">": {
+		token.NUM:    gt,
+		token.STRING: gt,
+	},
+	"<": {
+		token.NUM:    lt,
+		token.STRING: lt,
+	},
+	"contains": {
+		token.STRING: substring("*%s*", false),
+	},
+	"unContains": {
+		token.STRING: substring("*%s*", true),
+	},
+	"startsWith": {
+		token.STRING: substring("%s*", false),
+	},
+	"unStartsWith": {
+		token.STRING: substring("%s*", true),
+	},
+	"endsWith": {
+		token.STRING: substring("*%s", false),
+	},
+	"unEndsWith": {
+		token.STRING: substring("*%s", true),
+	},
+	// 大小写由属性的匹配规则决定，常用的字符串属性（cn、mail 等）均忽略大小写
+	"iequals": {
+		token.STRING: equal,
+	},
+	"icontains": {
+		token.STRING: substring("*%s*", false),
+	},
+	"istartsWith": {
+		token.STRING: substring("%s*", false),
+	},
+	"iendsWith": {
+		token.STRING: substring("*%s", false),
+	},
+	"like": {
+		token.STRING: wildcard(false),
+	},
+	"notLike": {
+		token.STRING: wildcard(true),
+	},
+	"in": {
+		token.STRING: in,
+		token.ARRAY:  inArray,
+	},
+	"notIn": {
+		token.STRING: notIn,
+		token.ARRAY:  notInArray,
+	},
+	"containsBit": {
+		token.NUM:    containsBit,
+		token.STRING: containsBit,
+	},
+	"unContainsBit": {
+		token.NUM:    unContainsBit,
+		token.STRING: unContainsBit,
+	},
+	"is": {
+		token.NULL: notExists,
+	},
+	"isNot": {
+		token.NULL: exists,
+	},
+	"exists": {
+		token.NULL: exists,
+	},
+	"notExists": {
+		token.NULL: notExists,
+	},
+	"&": {},
+	"|": {},
+}
+
+// encodeValue 把值转换为转义后的断言值，bool 为 TRUE、FALSE
+func encodeValue(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return ldap.EscapeFilter(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(v)), true
+	}
+	return "", false
+}
+
+// exists (key=*)
+func exists(key string, value any) string {
+	return fmt.Sprintf("(%s=*)", key)
+}
+
+// notExists (!(key=*))
+func notExists(key string, value any) string {
+	return not(exists(key, value))
+}
+
+func equal(key string, value any) string {
+	val, ok := encodeValue(value)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("(%s=%s)", key, val)
+}
+
+func unEqual(key string, value any) string {
+	filter := equal(key, value)
+	if len(filter) == 0 {
+		return ""
+	}
+	return not(filter)
+}
+
+func gte(key string, value any) string {
+	val, ok := encodeValue(value)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("(%s>=%s)", key, val)
+}
+
+func lte(key string, value any) string {
+	val, ok := encodeValue(value)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("(%s<=%s)", key, val)
+}
+
+// gt ldap 没有 >，使用 (&(key>=v)(!(key=v)))
+func gt(key string, value any) string {
+	filter := gte(key, value)
+	if len(filter) == 0 {
+		return ""
+	}
+	return and(filter, unEqual(key, value))
+}
+
+// lt ldap 没有 <，使用 (&(key<=v)(!(key=v)))
+func lt(key string, value any) string {
+	filter := lte(key, value)
+	if len(filter) == 0 {
+		return ""
+	}
+	return and(filter, unEqual(key, value))
+}
+
+// substring 子串匹配，format 中的 %s 为转义后的值，* 为通配符；not 为 true 时取反
+func substring(format string, isNot bool) ConditionFn {
+	return func(key string, value any) string {
+		val, ok := value.(string)
+		if !ok {
+			return ""
+		}
+		filter := fmt.Sprintf("(%s=%s)", key, fmt.Sprintf(format, ldap.EscapeFilter(val)))
+		if isNot {
+			return not(filter)
+		}
+		return filter
+	}
+}
+
+// wildcard 通配符转换为子串匹配，not 为 true 时取反
+//
+//	LDAP 只有 * 通配符，包含 ? 时不支持
+func wildcard(isNot bool) ConditionFn {
+	return func(key string, value any) string {
+		val, ok := value.(string)
+		if !ok {
+			return ""
+		}
+		var b strings.Builder
+		supported := true
+		expression.ScanWildcard(val, func(s string) {
+			b.WriteString(ldap.EscapeFilter(s))
+		}, func(c byte) {
+			if c == '?' {
+				supported = false
+				return
+			}
+			// 连续的 * 合并为一个，字面值中的 * 已转义为 \2a
+			if !strings.HasSuffix(b.String(), "*") {
+				b.WriteByte('*')
+			}
+		})
+		if !supported {
+			return ""
+		}
+		filter := fmt.Sprintf("(%s=%s)", key, b.String())
+		if isNot {
+			return not(filter)
+		}
+		return filter
+	}
+}
+
+// in 与逗号分隔的任意一个值相等
+func in(key string, value any) string {
+	val, ok := value.(string)
+	if !ok {
+		return ""
+	}
+	values := strings.Split(val, ",")
+	arr := make([]any, 0, len(values))
+	for _, item := range values {
+		arr = append(arr, item)
+	}
+	return inArray(key, arr)
+}
+
+// notIn 与逗号分隔的所有值都不相等
+func notIn(key string, value any) string {
+	filter := in(key, value)
+	if len(filter) == 0 {
+		return ""
+	}
+	return not(filter)
+}
+
+// inArray 与数组中任意一个值相等，空数组恒为 false
+func inArray(key string, value any) string {
+	val, ok := value.([]any)
+	if !ok {
+		return ""
+	}
+	if len(val) == 0 {
+		return filterFalse
+	}
+	filters := make([]string, 0, len(val))
+	for _, item := range val {
+		filter := equal(key, item)
+		if len(filter) == 0 {
+			return ""
+		}
+		filters = append(filters, filter)
+	}
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return or(filters...)
+}
+
+// notInArray 与数组中所有值都不相等，空数组恒为 true
+func notInArray(key string, value any) string {
+	val, ok := value.([]any)
+	if !ok {
+		return ""
+	}
+	if len(val) == 0 {
+		return filterTrue
+	}
+	return not(inArray(key, val))
+}
+
+// containsBit 位运算不进行类型判断，直接转成 int64，使用 LDAP_MATCHING_RULE_BIT_AND
+func containsBit(key string, value any) string {
+	intVal := number.ParseInt[int64](value)
+	return fmt.Sprintf("(%s:%s:=%d)", key, MatchingRuleBitAnd, intVal)
+}
+
+// unContainsBit 位运算不进行类型判断，直接转成 int64
+func unContainsBit(key string, value any) string {
+	return not(containsBit(key, value))
+}
@@ -0,0 +1,144 @@
+package mongo_expr
+
+import (
+	"fmt"
+
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/token"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type Executor struct {
+	FnMap map[string]map[token.Token]ConditionFn
+
+	// KeyMap 字段映射
+	// 	存在映射 => key 转换为映射值
+	KeyMap map[string]string
+}
+
+var StdExecutor = New(nil, nil)
+
+func New(fnMap map[string]map[token.Token]ConditionFn, keyMap map[string]string) *Executor {
+	if fnMap == nil {
+		fnMap = DefaultFnMap
+	}
+	return &Executor{
+		FnMap:  fnMap,
+		KeyMap: keyMap,
+	}
+}
+
+// DoExpr 执行表达式，不支持数组元素条件 any、all
+func (e *Executor) DoExpr(expr string, prefix, suffix string) (filter bson.M, keys []string, err error) {
+	lexTokens, err := expression.LexParse(expression.TokensRead(expr))
+	if err != nil {
+		return nil, nil, err
+	}
+	ast := expression.AstParse(lexTokens)
+	if err := expression.CheckQuant(ast); err != nil {
+		return nil, nil, err
+	}
+	filter, keys = e.DoAst(ast, prefix, suffix)
+	return filter, keys, nil
+}
+
+// DoAst 执行 ast
+func (e *Executor) DoAst(ast *expression.AstNode, prefix, suffix string) (filter bson.M, keys []string) {
+	if ast == nil {
+		return nil, nil
+	}
+	switch ast.Type {
+	case token.OPERATOR:
+		// 复合转换
+		leftResult, leftKeys := e.DoAst(ast.Left, prefix, suffix)
+		rightResult, rightKeys := e.DoAst(ast.Right, prefix, suffix)
+		if leftResult == nil || rightResult == nil {
+			return nil, nil
+		}
+		keys = append(keys, leftKeys...)
+		keys = append(keys, rightKeys...)
+		switch ast.Value.(string) {
+		case "&&":
+			return bson.M{
+				"$and": bson.A{
+					leftResult,
+					rightResult,
+				},
+			}, keys
+		case "||":
+			return bson.M{
+				"$or": bson.A{
+					leftResult,
+					rightResult,
+				},
+			}, keys
+		}
+	case token.NOT:
+		// 取反，$not 只能用于单个字段，使用 $nor
+		subResult, subKeys := e.DoAst(ast.Left, prefix, suffix)
+		if subResult == nil {
+			return nil, nil
+		}
+		return bson.M{
+			"$nor": bson.A{
+				subResult,
+			},
+		}, subKeys
+	case token.ILLEGAL:
+		// 不合法的条件，不能退化为查询全部
+		return bson.M{"$expr": false}, nil
+	case token.CONDITION:
+		// 单个表达式转换
+		return e.DoTerm(ast, prefix, suffix)
+	}
+	return nil, nil
+}
+
+// DoTerm 执行 term
+func (e *Executor) DoTerm(term *expression.AstNode, prefix, suffix string) (filter bson.M, keys []string) {
+	// 判断是否有 left 和 right
+	if term.Left == nil || term.Right == nil {
+		return nil, nil
+	}
+	// 判断 left，即 key 的类型，不支持函数调用
+	if _, ok := term.Left.Value.(string); !ok || term.Left.Type == token.FUNC {
+		return nil, nil
+	}
+	// 判断 condition 的类型
+	if _, ok := term.Value.(string); !ok {
+		return nil, nil
+	}
+	fns, ok := e.FnMap[term.Value.(string)]
+	if !ok {
+		return nil, nil
+	}
+	fn, ok := fns[term.Right.Type]
+	if !ok {
+		return nil, nil
+	}
+	key := e.buildKey(term.Left.Value.(string), prefix, suffix)
+	if term.Right.Type == token.FIELD {
+		// 字段与字段比较，值为映射后的字段名
+		field, ok := term.Right.Value.(string)
+		if !ok {
+			return nil, nil
+		}
+		field = e.buildKey(field, prefix, suffix)
+		return fn(key, field), []string{key, field}
+	}
+	return fn(key, term.Right.Value), []string{key}
+}
+
+// buildKey 字段映射，并拼接前缀、后缀
+func (e *Executor) buildKey(key string, prefix, suffix string) string {
+	if _, ok := e.KeyMap[key]; ok {
+		key = e.KeyMap[key]
+	}
+	if len(prefix) > 0 {
+		key = fmt.Sprintf("%s.%s", prefix, key)
+	}
+	if len(suffix) > 0 {
+		key = fmt.Sprintf("%s.%s", key, suffix)
+	}
+	return key
+}
@@ -0,0 +1,86 @@
+package mongo_expr
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDoExpr(t *testing.T) {
+	testCases := []struct {
+		Expr   string
+		Filter bson.M
+		Keys   []string
+	}{
+		{
+			Expr: "port >= 400 && port < 500 || user == 'a.b'",
+			Filter: bson.M{"$or": bson.A{
+				bson.M{"$and": bson.A{
+					bson.M{"port": bson.M{"$gte": float64(400)}},
+					bson.M{"port": bson.M{"$lt": float64(500)}},
+				}},
+				bson.M{"user": bson.M{"$regex": "^a\\.b$", "$options": "i"}},
+			}},
+			Keys: []string{"port", "port", "user"},
+		},
+		{
+			Expr: "!(host reg '^web-\\d+') && name unStartsWith 'tmp'",
+			Filter: bson.M{"$and": bson.A{
+				bson.M{"$nor": bson.A{bson.M{"host": bson.M{"$regex": "^web-\\d+"}}}},
+				bson.M{"name": bson.M{"$not": bson.M{"$regex": "^tmp", "$options": "i"}}},
+			}},
+			Keys: []string{"host", "name"},
+		},
+		{
+			Expr: "port in [80, 443] && user notIn 'admin,guest'",
+			Filter: bson.M{"$and": bson.A{
+				bson.M{"port": bson.M{"$in": bson.A{float64(80), float64(443)}}},
+				bson.M{"user": bson.M{"$nin": []string{"admin", "guest"}}},
+			}},
+			Keys: []string{"port", "user"},
+		},
+		{
+			Expr: "deleted == null && ip exists && flags containsBit 4",
+			Filter: bson.M{"$and": bson.A{
+				bson.M{"$and": bson.A{
+					bson.M{"deleted": nil},
+					bson.M{"ip": bson.M{"$exists": true}},
+				}},
+				bson.M{"flags": bson.M{"$bitsAllSet": int64(4)}},
+			}},
+			Keys: []string{"deleted", "ip", "flags"},
+		},
+		{
+			Expr: "bytes_out > $bytes_in",
+			Filter: bson.M{"$expr": bson.M{
+				"$gt": bson.A{"$attr.bytes_out", "$attr.bytes_in"},
+			}},
+			Keys: []string{"attr.bytes_out", "attr.bytes_in"},
+		},
+		{
+			Expr: "host like 'web-*.?' && user notLike 'a.*'",
+			Filter: bson.M{"$and": bson.A{
+				bson.M{"host": bson.M{"$regex": "(?s)^web-.*\\..$", "$options": "i"}},
+				bson.M{"user": bson.M{"$not": bson.M{"$regex": "(?s)^a\\..*$", "$options": "i"}}},
+			}},
+			Keys: []string{"host", "user"},
+		},
+		{
+			Expr: "port contains 1 && enabled == true",
+		},
+	}
+	executor := New(nil, map[string]string{"bytes_out": "attr.bytes_out", "bytes_in": "attr.bytes_in"})
+	for _, testCase := range testCases {
+		filter, keys, err := executor.DoExpr(testCase.Expr, "", "")
+		if err != nil {
+			t.Fatalf("testCase %s DoExpr failure: %s", testCase.Expr, err)
+		}
+		if !reflect.DeepEqual(filter, testCase.Filter) {
+			t.Fatalf("testCase %s need %#v but got %#v", testCase.Expr, testCase.Filter, filter)
+		}
+		if !reflect.DeepEqual(keys, testCase.Keys) {
+			t.Fatalf("testCase %s need keys %v but got %v", testCase.Expr, testCase.Keys, keys)
+		}
+	}
+}
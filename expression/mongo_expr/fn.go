@@ -0,0 +1,318 @@
+package mongo_expr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/token"
+	"github.com/jummyliu/pkg/number"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type ConditionFn func(key string, value any) bson.M
+
+var DefaultFnMap = map[string]map[token.Token]ConditionFn{
+	"==": {
+		token.NUM:    compare[float64]("$eq"),
+		token.BOOL:   compare[bool]("$eq"),
+		token.STRING: equalStr,
+		token.NULL:   isNull,
+		token.FIELD:  fieldCompare("$eq"),
+	},
+	"!=": {
+		token.NUM:    compare[float64]("$ne"),
+		token.BOOL:   compare[bool]("$ne"),
+		token.STRING: unEqualStr,
+		token.NULL:   isNotNull,
+		token.FIELD:  fieldCompare("$ne"),
+	},
+	">=": {
+		token.NUM:    compare[float64]("$gte"),
+		token.STRING: compare[string]("$gte"),
+		token.FIELD:  fieldCompare("$gte"),
+	},
+	"<=": {
+		token.NUM:    compare[float64]("$lte"),
+		token.STRING: compare[string]("$lte"),
+		token.FIELD:  fieldCompare("$lte"),
+	},
+	">": {
+		token.NUM:    compare[float64]("$gt"),
+		token.STRING: compare[string]("$gt"),
+		token.FIELD:  fieldCompare("$gt"),
+	},
+	"<": {
+		token.NUM:    compare[float64]("$lt"),
+		token.STRING: compare[string]("$lt"),
+		token.FIELD:  fieldCompare("$lt"),
+	},
+	"contains": {
+		token.STRING: match("%s", false),
+	},
+	"unContains": {
+		token.STRING: match("%s", true),
+	},
+	"startsWith": {
+		token.STRING: match("^%s", false),
+	},
+	"unStartsWith": {
+		token.STRING: match("^%s", true),
+	},
+	"endsWith": {
+		token.STRING: match("%s$", false),
+	},
+	"unEndsWith": {
+		token.STRING: match("%s$", true),
+	},
+	"iequals": {
+		token.STRING: equalStr,
+	},
+	"icontains": {
+		token.STRING: match("%s", false),
+	},
+	"istartsWith": {
+		token.STRING: match("^%s", false),
+	},
+	"iendsWith": {
+		token.STRING: match("%s$", false),
+	},
+	"like": {
+		token.STRING: wildcard(false),
+	},
+	"notLike": {
+		token.STRING: wildcard(true),
+	},
+	"reg": {
+		token.STRING: reg,
+	},
+	"in": {
+		token.STRING: in,
+		token.ARRAY:  inArray,
+	},
+	"notIn": {
+		token.STRING: notIn,
+		token.ARRAY:  notInArray,
+	},
+	"containsBit": {
+		token.NUM:    containsBit,
+		token.STRING: containsBit,
+	},
+	"unContainsBit": {
+		token.NUM:    unContainsBit,
+		token.STRING: unContainsBit,
+	},
+	"is": {
+		token.NULL: isNull,
+	},
+	"isNot": {
+		token.NULL: isNotNull,
+	},
+	"exists": {
+		token.NULL: exists,
+	},
+	"notExists": {
+		token.NULL: notExists,
+	},
+	"&": {},
+	"|": {},
+}
+
+// compare 比较运算，$eq、$ne、$gte、$lte、$gt、$lt
+func compare[T int64 | float64 | bool | string](op string) ConditionFn {
+	return func(key string, value any) bson.M {
+		val, ok := value.(T)
+		if !ok {
+			return nil
+		}
+		return bson.M{
+			key: bson.M{
+				op: val,
+			},
+		}
+	}
+}
+
+// fieldCompare 字段与字段比较，使用 $expr，value 为映射后的字段名
+func fieldCompare(op string) ConditionFn {
+	return func(key string, value any) bson.M {
+		field, ok := value.(string)
+		if !ok {
+			return nil
+		}
+		return bson.M{
+			"$expr": bson.M{
+				op: bson.A{"$" + key, "$" + field},
+			},
+		}
+	}
+}
+
+// isNull 值为 null，或者字段不存在
+func isNull(key string, value any) bson.M {
+	return bson.M{
+		key: nil,
+	}
+}
+
+// isNotNull 字段存在，并且值不为 null
+func isNotNull(key string, value any) bson.M {
+	return bson.M{
+		key: bson.M{
+			"$ne": nil,
+		},
+	}
+}
+
+// exists 字段存在
+func exists(key string, value any) bson.M {
+	return bson.M{
+		key: bson.M{
+			"$exists": true,
+		},
+	}
+}
+
+// notExists 字段不存在
+func notExists(key string, value any) bson.M {
+	return bson.M{
+		key: bson.M{
+			"$exists": false,
+		},
+	}
+}
+
+// equalStr 字符串相等，忽略大小写
+func equalStr(key string, value any) bson.M {
+	return match("^%s$", false)(key, value)
+}
+
+// unEqualStr 字符串不相等，忽略大小写
+func unEqualStr(key string, value any) bson.M {
+	return match("^%s$", true)(key, value)
+}
+
+// match 使用转义后的值生成正则进行匹配，忽略大小写
+//
+//	format 中的 %s 为转义后的值，not 为 true 时取反
+func match(format string, not bool) ConditionFn {
+	return func(key string, value any) bson.M {
+		val, ok := value.(string)
+		if !ok {
+			return nil
+		}
+		return matchRegex(key, fmt.Sprintf(format, regexp.QuoteMeta(val)), not)
+	}
+}
+
+// wildcard 通配符转换为正则进行匹配，忽略大小写，not 为 true 时取反
+func wildcard(not bool) ConditionFn {
+	return func(key string, value any) bson.M {
+		val, ok := value.(string)
+		if !ok {
+			return nil
+		}
+		return matchRegex(key, expression.WildcardToRegexp(val), not)
+	}
+}
+
+// matchRegex 使用正则进行匹配，忽略大小写，not 为 true 时取反
+func matchRegex(key string, pattern string, not bool) bson.M {
+	cond := bson.M{
+		"$regex":   pattern,
+		"$options": "i",
+	}
+	if not {
+		return bson.M{
+			key: bson.M{
+				"$not": cond,
+			},
+		}
+	}
+	return bson.M{
+		key: cond,
+	}
+}
+
+func reg(key string, value any) bson.M {
+	val, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	return bson.M{
+		key: bson.M{
+			"$regex": val,
+		},
+	}
+}
+
+func in(key string, value any) bson.M {
+	val, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	return bson.M{
+		key: bson.M{
+			"$in": strings.Split(val, ","),
+		},
+	}
+}
+
+func notIn(key string, value any) bson.M {
+	val, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	return bson.M{
+		key: bson.M{
+			"$nin": strings.Split(val, ","),
+		},
+	}
+}
+
+func inArray(key string, value any) bson.M {
+	val, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+	return bson.M{
+		key: bson.M{
+			"$in": bson.A(val),
+		},
+	}
+}
+
+func notInArray(key string, value any) bson.M {
+	val, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+	return bson.M{
+		key: bson.M{
+			"$nin": bson.A(val),
+		},
+	}
+}
+
+// containsBit 位运算不进行类型判断，直接转成 int64
+func containsBit(key string, value any) bson.M {
+	intVal := number.ParseInt[int64](value)
+	return bson.M{
+		key: bson.M{
+			"$bitsAllSet": intVal,
+		},
+	}
+}
+
+// unContainsBit 位运算不进行类型判断，直接转成 int64
+func unContainsBit(key string, value any) bson.M {
+	intVal := number.ParseInt[int64](value)
+	return bson.M{
+		key: bson.M{
+			"$not": bson.M{
+				"$bitsAllSet": intVal,
+			},
+		},
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/sqlfunc"
 	"github.com/jummyliu/pkg/expression/token"
 )
 
@@ -13,9 +14,13 @@ const (
 	rbt         = " )"
 	operatorAnd = " AND "
 	operatorOr  = " OR "
+	operatorNot = "NOT "
+	// matchNone 不匹配任何数据，用于 builder 生成的 ILLEGAL 节点
+	matchNone = "1 = 0"
 
 	lenAnd = len(lbt) + len(rbt) + len(operatorAnd)
 	lenOr  = len(lbt) + len(rbt) + len(operatorOr)
+	lenNot = len(lbt) + len(rbt) + len(operatorNot)
 )
 
 type Executor struct {
@@ -39,13 +44,16 @@ func New(fnMap map[string]map[token.Token]ConditionFn, keyMap map[string]string)
 	}
 }
 
-// DoExpr 执行表达式
+// DoExpr 执行表达式，不支持函数调用
 func (e *Executor) DoExpr(expr string, prefix, suffix string, jsonAttr string) (sqls string, params []any, keys []string, err error) {
 	lexTokens, err := expression.LexParse(expression.TokensRead(expr))
 	if err != nil {
 		return "", nil, nil, err
 	}
 	ast := expression.AstParse(lexTokens)
+	if err := sqlfunc.Check(nil, ast); err != nil {
+		return "", nil, nil, err
+	}
 	sqls, params, keys = e.DoAst(ast, prefix, suffix, jsonAttr)
 	return sqls, params, keys, nil
 }
@@ -91,21 +99,116 @@ func (e *Executor) DoAst(ast *expression.AstNode, prefix, suffix string, jsonAtt
 			keys = append(keys, rightKeys...)
 			return b.String(), params, keys
 		}
+	case token.NOT:
+		// 取反
+		subSQL, subParams, subKeys := e.DoAst(ast.Left, prefix, suffix, jsonAttr)
+		if len(subSQL) == 0 {
+			return "", nil, nil
+		}
+		var b strings.Builder
+		b.Grow(len(subSQL) + lenNot)
+		b.WriteString(operatorNot)
+		b.WriteString(lbt)
+		b.WriteString(subSQL)
+		b.WriteString(rbt)
+		return b.String(), subParams, subKeys
+	case token.ILLEGAL:
+		// 不合法的条件，不能退化为查询全部
+		return matchNone, nil, nil
 	case token.CONDITION:
 		// 单个表达式转换
 		return e.DoTerm(ast, prefix, suffix, jsonAttr)
+	case token.QUANT:
+		// 数组元素条件
+		return e.DoQuant(ast, prefix, suffix, jsonAttr)
 	}
 	return "", nil, nil
 }
 
+// DoQuant 执行数组元素条件，使用 JSON_TABLE 把数组展开为行，子表达式作用在每一行的元素上
+//
+//	子表达式中的字段相对于数组元素，只拼接后缀，不进行字段映射
+//	any(path, key == value)，value 为数字、字符串时，使用 JSON_CONTAINS 判断
+//	all 在空数组、字段不存在时为 true
+func (e *Executor) DoQuant(quant *expression.AstNode, prefix, suffix string, jsonAttr string) (sql string, params []any, keys []string) {
+	if quant.Left == nil || quant.Right == nil {
+		return "", nil, nil
+	}
+	path, ok := quant.Left.Value.(string)
+	if !ok {
+		return "", nil, nil
+	}
+	name, _ := quant.Value.(string)
+	if !token.IsQuant(name) {
+		return "", nil, nil
+	}
+	path = e.buildKey(path, prefix, "")
+	pathSQL, pathParams := buildKey(path)
+	sub := New(e.FnMap, nil)
+	if name == "any" {
+		if sql, params, keys, ok := sub.containsElem(quant.Right, suffix, jsonAttr, pathSQL, pathParams); ok {
+			return sql, params, prefixKeys(path, keys)
+		}
+	}
+	alias := elemAlias(jsonAttr)
+	subSQL, subParams, subKeys := sub.DoAst(quant.Right, "", suffix, alias+".elem")
+	if len(subSQL) == 0 {
+		return "", nil, nil
+	}
+	params = append(params, pathParams...)
+	params = append(params, subParams...)
+	table := fmt.Sprintf(
+		"SELECT 1 FROM JSON_TABLE(JSON_EXTRACT(%s, %s), '$[*]' COLUMNS (elem JSON PATH '$')) AS %s WHERE ",
+		jsonAttr,
+		pathSQL,
+		alias,
+	)
+	if name == "any" {
+		return "EXISTS " + lbt + table + subSQL + rbt, params, prefixKeys(path, subKeys)
+	}
+	return "NOT EXISTS " + lbt + table + "NOT COALESCE(" + subSQL + ", FALSE)" + rbt, params, prefixKeys(path, subKeys)
+}
+
+// containsElem 子表达式为 key == value，并且 value 为数字、字符串时，使用 JSON_CONTAINS 判断数组中是否有包含该属性的元素
+func (e *Executor) containsElem(term *expression.AstNode, suffix string, jsonAttr string, pathSQL string, pathParams []any) (sql string, params []any, keys []string, ok bool) {
+	if term.Type != token.CONDITION || term.Value != "==" || term.Left == nil || term.Right == nil ||
+		term.Left.Type != token.IDENT || (term.Right.Type != token.NUM && term.Right.Type != token.STRING) {
+		return "", nil, nil, false
+	}
+	key := e.buildKey(term.Left.Value.(string), "", suffix)
+	if strings.Contains(key, ".") {
+		return "", nil, nil, false
+	}
+	params = append(params, key, term.Right.Value)
+	params = append(params, pathParams...)
+	return fmt.Sprintf("JSON_CONTAINS(%s, JSON_OBJECT(?, ?), %s)", jsonAttr, pathSQL), params, []string{key}, true
+}
+
+// elemAlias 数组元素子查询的表别名，嵌套的数组元素条件使用不同的别名
+func elemAlias(jsonAttr string) string {
+	var depth int
+	if _, err := fmt.Sscanf(jsonAttr, "jt%d.elem", &depth); err == nil {
+		return fmt.Sprintf("jt%d", depth+1)
+	}
+	return "jt0"
+}
+
+// prefixKeys 子表达式中的字段拼接数组字段
+func prefixKeys(path string, keys []string) []string {
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%s.%s", path, keys[i])
+	}
+	return keys
+}
+
 // DoTerm 执行 term
 func (e *Executor) DoTerm(term *expression.AstNode, prefix, suffix string, jsonAttr string) (sql string, params []any, keys []string) {
 	// 判断是否有 left 和 right
 	if term.Left == nil || term.Right == nil {
 		return "", nil, nil
 	}
-	// 判断 left，即 key 的类型
-	if _, ok := term.Left.Value.(string); !ok {
+	// 判断 left，即 key 的类型，不支持函数调用
+	if _, ok := term.Left.Value.(string); !ok || term.Left.Type == token.FUNC {
 		return "", nil, nil
 	}
 	// 判断 condition 的类型
@@ -120,7 +223,23 @@ func (e *Executor) DoTerm(term *expression.AstNode, prefix, suffix string, jsonA
 	if !ok {
 		return "", nil, nil
 	}
-	key := term.Left.Value.(string)
+	key := e.buildKey(term.Left.Value.(string), prefix, suffix)
+	if term.Right.Type == token.FIELD {
+		// 字段与字段比较，值为映射后的字段名
+		field, ok := term.Right.Value.(string)
+		if !ok {
+			return "", nil, nil
+		}
+		field = e.buildKey(field, prefix, suffix)
+		sql, params = fn(key, field, jsonAttr)
+		return sql, params, []string{key, field}
+	}
+	sql, params = fn(key, term.Right.Value, jsonAttr)
+	return sql, params, []string{key}
+}
+
+// buildKey 字段映射，并拼接前缀、后缀
+func (e *Executor) buildKey(key string, prefix, suffix string) string {
 	if _, ok := e.KeyMap[key]; ok {
 		key = e.KeyMap[key]
 	}
@@ -130,6 +249,5 @@ func (e *Executor) DoTerm(term *expression.AstNode, prefix, suffix string, jsonA
 	if len(suffix) > 0 {
 		key = fmt.Sprintf("%s.%s", key, suffix)
 	}
-	sql, params = fn(key, term.Right.Value, jsonAttr)
-	return sql, params, []string{key}
+	return key
 }
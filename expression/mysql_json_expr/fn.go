@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/sqlfunc"
 	"github.com/jummyliu/pkg/expression/token"
 	"github.com/jummyliu/pkg/number"
 )
@@ -29,27 +31,41 @@ var DefaultFnMap = map[string]map[token.Token]ConditionFn{
 		token.NUM:    equal[float64],
 		token.BOOL:   equal[bool],
 		token.STRING: equalStr,
+		token.NULL:   isNull,
+		token.FIELD:  fieldCompare("="),
+		token.DATE:   equalDate,
 	},
 	"!=": {
 		token.NUM:    unEqual[float64],
 		token.BOOL:   unEqual[bool],
 		token.STRING: unEqualStr,
+		token.NULL:   isNotNull,
+		token.FIELD:  fieldCompare("!="),
+		token.DATE:   unEqualDate,
 	},
 	">=": {
 		token.NUM:    gte[float64],
 		token.STRING: gte[string],
+		token.FIELD:  fieldCompare(">="),
+		token.DATE:   dateCompare(">=", false),
 	},
 	"<=": {
 		token.NUM:    lte[float64],
 		token.STRING: lte[string],
+		token.FIELD:  fieldCompare("<="),
+		token.DATE:   dateCompare("<=", true),
 	},
 	">": {
 		token.NUM:    gt[float64],
-		token.STRING: lt[string],
+		token.STRING: gt[string],
+		token.FIELD:  fieldCompare(">"),
+		token.DATE:   dateCompare(">", true),
 	},
 	"<": {
 		token.NUM:    lt[float64],
 		token.STRING: lt[string],
+		token.FIELD:  fieldCompare("<"),
+		token.DATE:   dateCompare("<", false),
 	},
 	"contains": {
 		token.STRING: contains,
@@ -69,14 +85,45 @@ var DefaultFnMap = map[string]map[token.Token]ConditionFn{
 	"unEndsWith": {
 		token.STRING: unEndsWith,
 	},
+	"iequals": {
+		token.STRING: equalFold,
+	},
+	"icontains": {
+		token.STRING: ilike("%%%s%%"),
+	},
+	"istartsWith": {
+		token.STRING: ilike("%s%%"),
+	},
+	"iendsWith": {
+		token.STRING: ilike("%%%s"),
+	},
+	"like": {
+		token.STRING: wildcard("LIKE"),
+	},
+	"notLike": {
+		token.STRING: wildcard("NOT LIKE"),
+	},
 	"reg": {
 		token.STRING: reg,
 	},
 	"in": {
 		token.STRING: in,
+		token.ARRAY:  inArray,
 	},
 	"notIn": {
 		token.STRING: notIn,
+		token.ARRAY:  notInArray,
+	},
+	"between": {
+		token.ARRAY: between,
+	},
+	"inCidr": {
+		token.STRING: cidr(false),
+		token.ARRAY:  cidr(false),
+	},
+	"notInCidr": {
+		token.STRING: cidr(true),
+		token.ARRAY:  cidr(true),
 	},
 	"containsBit": {
 		token.NUM:    containsBit,
@@ -86,10 +133,84 @@ var DefaultFnMap = map[string]map[token.Token]ConditionFn{
 		token.NUM:    unContainsBit,
 		token.STRING: unContainsBit,
 	},
+	"is": {
+		token.NULL: isNull,
+	},
+	"isNot": {
+		token.NULL: isNotNull,
+	},
+	"exists": {
+		token.NULL: exists,
+	},
+	"notExists": {
+		token.NULL: notExists,
+	},
 	"&": {},
 	"|": {},
 }
 
+// fieldCompare 字段与字段比较，value 为映射后的字段名
+func fieldCompare(op string) ConditionFn {
+	return func(key string, value any, jsonAttr string) (sql string, params []any) {
+		field, ok := value.(string)
+		if !ok {
+			return "", nil
+		}
+		keySql, p := buildKey(key)
+		fieldSql, fieldP := buildKey(field)
+		params = append(params, p...)
+		params = append(params, fieldP...)
+		return fmt.Sprintf(
+			"JSON_EXTRACT(%s, %s) %s JSON_EXTRACT(%s, %s)",
+			jsonAttr,
+			keySql,
+			op,
+			jsonAttr,
+			fieldSql,
+		), params
+	}
+}
+
+// isNull 路径不存在，或者值为 json null
+func isNull(key string, value any, jsonAttr string) (sql string, params []any) {
+	keySql, p := buildKey(key)
+	return fmt.Sprintf(
+		"COALESCE(JSON_TYPE(JSON_EXTRACT(%s, %s)), 'NULL') = 'NULL'",
+		jsonAttr,
+		keySql,
+	), p
+}
+
+// isNotNull 路径存在，并且值不为 json null
+func isNotNull(key string, value any, jsonAttr string) (sql string, params []any) {
+	keySql, p := buildKey(key)
+	return fmt.Sprintf(
+		"COALESCE(JSON_TYPE(JSON_EXTRACT(%s, %s)), 'NULL') != 'NULL'",
+		jsonAttr,
+		keySql,
+	), p
+}
+
+// exists 路径存在
+func exists(key string, value any, jsonAttr string) (sql string, params []any) {
+	keySql, p := buildKey(key)
+	return fmt.Sprintf(
+		"JSON_CONTAINS_PATH(%s, 'one', %s) = 1",
+		jsonAttr,
+		keySql,
+	), p
+}
+
+// notExists 路径不存在
+func notExists(key string, value any, jsonAttr string) (sql string, params []any) {
+	keySql, p := buildKey(key)
+	return fmt.Sprintf(
+		"JSON_CONTAINS_PATH(%s, 'one', %s) = 0",
+		jsonAttr,
+		keySql,
+	), p
+}
+
 func equal[T comparable](key string, value any, jsonAttr string) (sql string, params []any) {
 	val, ok := value.(T)
 	if !ok {
@@ -300,6 +421,98 @@ func unEndsWith(key string, value any, jsonAttr string) (sql string, params []an
 	), params
 }
 
+// equalFold 相等，忽略大小写，与排序规则无关
+func equalFold(key string, value any, jsonAttr string) (sql string, params []any) {
+	val, ok := value.(string)
+	if !ok {
+		return "", nil
+	}
+	keySql, p := buildKey(key)
+	params = append(params, p...)
+	params = append(params, val)
+	return fmt.Sprintf(
+		"LOWER(JSON_UNQUOTE(JSON_EXTRACT(%s, %s))) = LOWER(?)",
+		jsonAttr,
+		keySql,
+	), params
+}
+
+// ilike 忽略大小写的 LIKE 匹配，format 中的 %s 为转义后的值
+func ilike(format string) ConditionFn {
+	return func(key string, value any, jsonAttr string) (sql string, params []any) {
+		val, ok := value.(string)
+		if !ok {
+			return "", nil
+		}
+		keySql, p := buildKey(key)
+		params = append(params, p...)
+		params = append(params, fmt.Sprintf(format, expression.EscapeLike(val)))
+		return fmt.Sprintf(
+			"LOWER(JSON_UNQUOTE(JSON_EXTRACT(%s, %s))) LIKE LOWER(?)",
+			jsonAttr,
+			keySql,
+		), params
+	}
+}
+
+// wildcard 通配符匹配，忽略大小写，通配符转换为 LIKE 的匹配模式
+func wildcard(op string) ConditionFn {
+	return func(key string, value any, jsonAttr string) (sql string, params []any) {
+		val, ok := value.(string)
+		if !ok {
+			return "", nil
+		}
+		keySql, p := buildKey(key)
+		params = append(params, p...)
+		params = append(params, expression.WildcardToLike(val))
+		return fmt.Sprintf(
+			"LOWER(JSON_UNQUOTE(JSON_EXTRACT(%s, %s))) %s LOWER(?)",
+			jsonAttr,
+			keySql,
+			op,
+		), params
+	}
+}
+
+// cidr 在任意一个 CIDR 范围内，not 为 true 时取反
+//
+//	使用 INET6_ATON 转换为二进制后按范围比较，IPv4 为 4 字节，IPv6 为 16 字节，需要同时比较长度
+//	空数组时 inCidr 恒为 false，notInCidr 恒为 true
+func cidr(not bool) ConditionFn {
+	return func(key string, value any, jsonAttr string) (sql string, params []any) {
+		prefixes, err := expression.ParseCidrs(value)
+		if err != nil {
+			// LexParse、builder 已校验，无法解析时不匹配任何数据，不能退化为查询全部
+			return "1 = 0", nil
+		}
+		if len(prefixes) == 0 {
+			if not {
+				return "1 = 1", nil
+			}
+			return "1 = 0", nil
+		}
+		keySql, p := buildKey(key)
+		addr := fmt.Sprintf("INET6_ATON(JSON_UNQUOTE(JSON_EXTRACT(%s, %s)))", jsonAttr, keySql)
+		sqls := make([]string, 0, len(prefixes))
+		for _, prefix := range prefixes {
+			from, to := expression.CidrRange(prefix)
+			sqls = append(sqls, fmt.Sprintf("( LENGTH(%s) = ? AND %s BETWEEN ? AND ? )", addr, addr))
+			params = append(params, p...)
+			params = append(params, from.BitLen()/8)
+			params = append(params, p...)
+			params = append(params, from.AsSlice(), to.AsSlice())
+		}
+		sql = strings.Join(sqls, " OR ")
+		if len(sqls) > 1 {
+			sql = fmt.Sprintf("( %s )", sql)
+		}
+		if not {
+			sql = fmt.Sprintf("NOT %s", sql)
+		}
+		return sql, params
+	}
+}
+
 func reg(key string, value any, jsonAttr string) (sql string, params []any) {
 	val, ok := value.(string)
 	if !ok {
@@ -345,6 +558,126 @@ func notIn(key string, value any, jsonAttr string) (sql string, params []any) {
 	), params
 }
 
+// inArray 与数组中任意一个值相等，空数组恒为 false
+func inArray(key string, value any, jsonAttr string) (sql string, params []any) {
+	val, ok := value.([]any)
+	if !ok {
+		return "", nil
+	}
+	if len(val) == 0 {
+		return "1 = 0", nil
+	}
+	sqls := make([]string, 0, len(val))
+	for _, item := range val {
+		var itemSQL string
+		var itemParams []any
+		switch item.(type) {
+		case float64:
+			itemSQL, itemParams = equal[float64](key, item, jsonAttr)
+		case bool:
+			itemSQL, itemParams = equal[bool](key, item, jsonAttr)
+		case token.Date:
+			itemSQL, itemParams = dateCompare("=", false)(key, item, jsonAttr)
+		default:
+			itemSQL, itemParams = equalStr(key, item, jsonAttr)
+		}
+		sqls = append(sqls, itemSQL)
+		params = append(params, itemParams...)
+	}
+	return fmt.Sprintf("( %s )", strings.Join(sqls, " OR ")), params
+}
+
+// notInArray 与数组中所有值都不相等，空数组恒为 true
+func notInArray(key string, value any, jsonAttr string) (sql string, params []any) {
+	val, ok := value.([]any)
+	if !ok {
+		return "", nil
+	}
+	if len(val) == 0 {
+		return "1 = 1", nil
+	}
+	sqls := make([]string, 0, len(val))
+	for _, item := range val {
+		var itemSQL string
+		var itemParams []any
+		switch item.(type) {
+		case float64:
+			itemSQL, itemParams = unEqual[float64](key, item, jsonAttr)
+		case bool:
+			itemSQL, itemParams = unEqual[bool](key, item, jsonAttr)
+		case token.Date:
+			itemSQL, itemParams = dateCompare("!=", false)(key, item, jsonAttr)
+		default:
+			itemSQL, itemParams = unEqualStr(key, item, jsonAttr)
+		}
+		sqls = append(sqls, itemSQL)
+		params = append(params, itemParams...)
+	}
+	return fmt.Sprintf("( %s )", strings.Join(sqls, " AND ")), params
+}
+
+// dateKey 日期在 json 中保存为字符串，转换为 DATETIME 后比较
+func dateKey(key string, jsonAttr string) (sql string, params []any) {
+	keySql, p := buildKey(key)
+	return fmt.Sprintf("CAST(JSON_UNQUOTE(JSON_EXTRACT(%s, %s)) AS DATETIME(3))", jsonAttr, keySql), p
+}
+
+// dateCompare 日期比较，日期的解析见 sqlfunc.Date
+func dateCompare(op string, roundUp bool) ConditionFn {
+	return func(key string, value any, jsonAttr string) (sql string, params []any) {
+		t, ok := sqlfunc.Date(value, roundUp)
+		if !ok {
+			return "", nil
+		}
+		keySql, p := dateKey(key, jsonAttr)
+		return fmt.Sprintf("%s %s ?", keySql, op), append(p, t)
+	}
+}
+
+// equalDate 在取整的周期内，如：ts == now/d 为今天
+func equalDate(key string, value any, jsonAttr string) (sql string, params []any) {
+	return dateRange(key, value, jsonAttr, "BETWEEN")
+}
+
+// unEqualDate 不在取整的周期内
+func unEqualDate(key string, value any, jsonAttr string) (sql string, params []any) {
+	return dateRange(key, value, jsonAttr, "NOT BETWEEN")
+}
+
+// dateRange 日期取整的周期 [向下取整, 向上取整]
+func dateRange(key string, value any, jsonAttr string, op string) (sql string, params []any) {
+	rangeParams, ok := sqlfunc.DateRange(value)
+	if !ok {
+		return "", nil
+	}
+	keySql, p := dateKey(key, jsonAttr)
+	return fmt.Sprintf("%s %s ? AND ?", keySql, op), append(p, rangeParams...)
+}
+
+// between BETWEEN ? AND ?，包含两端，存在日期时转换为 DATETIME 比较，日期的结束值向上取整
+func between(key string, value any, jsonAttr string) (sql string, params []any) {
+	val, ok := value.([]any)
+	if !ok || len(val) != 2 {
+		return "", nil
+	}
+	_, fromDate := val[0].(token.Date)
+	_, toDate := val[1].(token.Date)
+	if !fromDate && !toDate {
+		keySql, p := buildKey(key)
+		return fmt.Sprintf(
+			"JSON_EXTRACT(%s, %s) COLLATE utf8mb4_0900_ai_ci BETWEEN ? AND ?",
+			jsonAttr,
+			keySql,
+		), append(p, val[0], val[1])
+	}
+	rangeParams, ok := sqlfunc.Between(value)
+	if !ok {
+		return "", nil
+	}
+	keySql, p := dateKey(key, jsonAttr)
+	return fmt.Sprintf("%s BETWEEN ? AND ?", keySql), append(p, rangeParams...)
+}
+
 // containsBit 位运算不进行类型判断，直接转成 int64
 func containsBit(key string, value any, jsonAttr string) (sql string, params []any) {
 	intVal := number.ParseInt[int64](value)
@@ -7,6 +7,7 @@ import (
 	"github.com/jummyliu/pkg/expression"
 	"github.com/jummyliu/pkg/expression/mysql_expr"
 	"github.com/jummyliu/pkg/expression/mysql_json_expr"
+	"github.com/jummyliu/pkg/expression/sqlfunc"
 	"github.com/jummyliu/pkg/expression/token"
 )
 
@@ -15,9 +16,13 @@ const (
 	rbt         = " )"
 	operatorAnd = " AND "
 	operatorOr  = " OR "
+	operatorNot = "NOT "
+	// matchNone 不匹配任何数据，用于 builder 生成的 ILLEGAL 节点
+	matchNone = "1 = 0"
 
 	lenAnd = len(lbt) + len(rbt) + len(operatorAnd)
 	lenOr  = len(lbt) + len(rbt) + len(operatorOr)
+	lenNot = len(lbt) + len(rbt) + len(operatorNot)
 )
 
 type Executor struct {
@@ -49,13 +54,16 @@ func New(mysqlExecutor *mysql_expr.Executor, jsonExecutor *mysql_json_expr.Execu
 	}
 }
 
-// DoExpr 执行表达式
+// DoExpr 执行表达式，不支持函数调用
 func (e *Executor) DoExpr(expr string, prefix, suffix string) (sqls string, params []any, keys []string, err error) {
 	lexTokens, err := expression.LexParse(expression.TokensRead(expr))
 	if err != nil {
 		return "", nil, nil, err
 	}
 	ast := expression.AstParse(lexTokens)
+	if err := sqlfunc.Check(nil, ast); err != nil {
+		return "", nil, nil, err
+	}
 	sqls, params, keys = e.DoAst(ast, prefix, suffix)
 	return sqls, params, keys, nil
 }
@@ -101,21 +109,72 @@ func (e *Executor) DoAst(ast *expression.AstNode, prefix, suffix string) (sqls s
 			keys = append(keys, rightKeys...)
 			return b.String(), params, keys
 		}
+	case token.NOT:
+		// 取反
+		subSQL, subParams, subKeys := e.DoAst(ast.Left, prefix, suffix)
+		if len(subSQL) == 0 {
+			return "", nil, nil
+		}
+		var b strings.Builder
+		b.Grow(len(subSQL) + lenNot)
+		b.WriteString(operatorNot)
+		b.WriteString(lbt)
+		b.WriteString(subSQL)
+		b.WriteString(rbt)
+		return b.String(), subParams, subKeys
+	case token.ILLEGAL:
+		// 不合法的条件，不能退化为查询全部
+		return matchNone, nil, nil
 	case token.CONDITION:
 		// 单个表达式转换
 		return e.DoTerm(ast, prefix, suffix)
+	case token.QUANT:
+		// 数组元素条件
+		return e.DoQuant(ast, prefix, suffix)
 	}
 	return "", nil, nil
 }
 
+// DoQuant 执行数组元素条件，只支持 json 字段中的数组
+func (e *Executor) DoQuant(quant *expression.AstNode, prefix, suffix string) (sql string, params []any, keys []string) {
+	if quant.Left == nil || quant.Right == nil {
+		return "", nil, nil
+	}
+	path, ok := quant.Left.Value.(string)
+	if !ok {
+		return "", nil, nil
+	}
+	if _, ok := e.KeyMap[path]; ok {
+		path = e.KeyMap[path]
+	}
+	splitKeys := strings.SplitN(path, ".", 2)
+	if len(splitKeys) != 2 {
+		return "", nil, nil
+	}
+	sql, params, keys = e.jsonExecutor.DoQuant(&expression.AstNode{
+		Type:  quant.Type,
+		Value: quant.Value,
+		Left: &expression.AstNode{
+			Type:  quant.Left.Type,
+			Value: splitKeys[1],
+		},
+		Right: quant.Right,
+	}, prefix, suffix, splitKeys[0])
+
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%s.%s", splitKeys[0], keys[i])
+	}
+	return sql, params, keys
+}
+
 // DoTerm 执行 term
 func (e *Executor) DoTerm(term *expression.AstNode, prefix, suffix string) (sql string, params []any, keys []string) {
 	// 判断是否有 left 和 right
 	if term.Left == nil || term.Right == nil {
 		return "", nil, nil
 	}
-	// 判断 left，即 key 的类型
-	if _, ok := term.Left.Value.(string); !ok {
+	// 判断 left，即 key 的类型，不支持函数调用
+	if _, ok := term.Left.Value.(string); !ok || term.Left.Type == token.FUNC {
 		return "", nil, nil
 	}
 	// 判断 condition 的类型
@@ -128,6 +187,29 @@ func (e *Executor) DoTerm(term *expression.AstNode, prefix, suffix string) (sql
 	}
 
 	splitKeys := strings.SplitN(key, ".", 2)
+	right := term.Right
+	var splitFields []string
+	if term.Right.Type == token.FIELD {
+		// 字段与字段比较，只支持两个普通字段，或者同一个 json 字段中的两个属性
+		field, ok := term.Right.Value.(string)
+		if !ok {
+			return "", nil, nil
+		}
+		if _, ok := e.KeyMap[field]; ok {
+			field = e.KeyMap[field]
+		}
+		splitFields = strings.SplitN(field, ".", 2)
+		if len(splitFields) != len(splitKeys) {
+			return "", nil, nil
+		}
+		if len(splitKeys) == 2 && splitFields[0] != splitKeys[0] {
+			return "", nil, nil
+		}
+		right = &expression.AstNode{
+			Type:  term.Right.Type,
+			Value: splitFields[len(splitFields)-1],
+		}
+	}
 	if len(splitKeys) == 1 {
 		// 普通字段
 		return e.mysqlExecutor.DoTerm(&expression.AstNode{
@@ -139,7 +221,7 @@ func (e *Executor) DoTerm(term *expression.AstNode, prefix, suffix string) (sql
 				Left:  term.Left.Left,
 				Right: term.Left.Right,
 			},
-			Right: term.Right,
+			Right: right,
 		}, prefix, suffix)
 	}
 	// json 字段
@@ -152,11 +234,11 @@ func (e *Executor) DoTerm(term *expression.AstNode, prefix, suffix string) (sql
 			Left:  term.Left.Left,
 			Right: term.Left.Right,
 		},
-		Right: term.Right,
+		Right: right,
 	}, prefix, suffix, splitKeys[0])
 
-	if len(keys) == 1 {
-		keys[0] = fmt.Sprintf("%s.%s", splitKeys[0], keys[0])
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%s.%s", splitKeys[0], keys[i])
 	}
 	return sql, params, keys
 }
@@ -0,0 +1,301 @@
+package expression
+
+import (
+	"strings"
+
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+// conditions 条件，顺序与 token.REG_CONDITION 一致，长的要在前面
+var conditions = []string{
+	"==", "!=", ">=", "<=", ">", "<",
+	"containsBit", "unContainsBit", "contains", "unContains",
+	"startsWith", "unStartsWith", "endsWith", "unEndsWith",
+	"iequals", "icontains", "istartsWith", "iendsWith", "like", "notLike",
+	"reg", "notInCidr", "inCidr", "notIn", "in", "isNot", "is", "between",
+}
+
+// unaries 一元条件，顺序与 token.REG_UNARY 一致
+var unaries = []string{"exists", "notExists"}
+
+// scanner 手写的词法扫描器，每个位置按 token.ParserPriority 的优先级判断
+type scanner struct {
+	expr string
+	pos  int
+}
+
+// next 读取下一个分词，到达末尾时返回 nil
+func (s *scanner) next() *LexNode {
+	s.pos += s.spaces(s.pos)
+	if s.pos >= len(s.expr) {
+		return nil
+	}
+	from := s.pos
+	t, n := s.scan(from)
+	s.pos += n
+	return &LexNode{
+		Type:  t,
+		Value: token.ParserMap[t].Decode(strings.TrimRight(s.expr[from:s.pos], " ")),
+		Len:   n,
+		From:  from,
+	}
+}
+
+// scan 判断 i 位置的分词类型和长度，i 位置不是空字符
+func (s *scanner) scan(i int) (t token.Token, n int) {
+	expr := s.expr
+	// UNARY /(exists|notExists)\b\s*/
+	for _, item := range unaries {
+		if strings.HasPrefix(expr[i:], item) && s.boundary(i+len(item)) {
+			return token.UNARY, len(item) + s.spaces(i+len(item))
+		}
+	}
+	// COND /(==|!=|...|(?:containsBit|...|between)\b)\s*/，单词形式的条件需要完整匹配
+	for _, item := range conditions {
+		if strings.HasPrefix(expr[i:], item) && (!isWord(item[0]) || s.boundary(i+len(item))) {
+			return token.CONDITION, len(item) + s.spaces(i+len(item))
+		}
+	}
+	// OP /(&&|\|\|)/
+	if strings.HasPrefix(expr[i:], "&&") || strings.HasPrefix(expr[i:], "||") {
+		return token.OPERATOR, 2
+	}
+	// NOT /(!|not(?P<next>[\s(!]))/，not 后面需要是空字符、( 或者 !，not.x、not-found 为 IDENT
+	if expr[i] == '!' {
+		return token.NOT, 1
+	}
+	if strings.HasPrefix(expr[i:], "not") && i+3 < len(expr) && (isSpace(expr[i+3]) || expr[i+3] == '(' || expr[i+3] == '!') {
+		return token.NOT, 3
+	}
+	// DATE /\d{4}-\d{2}-\d{2}(?:T...)?/
+	if n := s.date(i); n > 0 {
+		return token.DATE, n
+	}
+	// NUM /(?:\+|-)?\d+(?:\.\d+)?/
+	if n := s.number(i); n > 0 {
+		return token.NUM, n
+	}
+	// BOOL /(true|false)/
+	if strings.HasPrefix(expr[i:], "true") {
+		return token.BOOL, 4
+	}
+	if strings.HasPrefix(expr[i:], "false") {
+		return token.BOOL, 5
+	}
+	// NULL /null\b/
+	if strings.HasPrefix(expr[i:], "null") && s.boundary(i+4) {
+		return token.NULL, 4
+	}
+	// STRING
+	if n := s.string(i); n > 0 {
+		return token.STRING, n
+	}
+	// FIELD /\$[a-zA-Z_][\w\\.\-]*/
+	if expr[i] == '$' && i+1 < len(expr) && isIdentStart(expr[i+1]) {
+		return token.FIELD, 1 + s.ident(i+1)
+	}
+	if isIdentStart(expr[i]) {
+		// FUNC /[a-zA-Z_]\w*\s*\(/
+		j := i + 1
+		for j < len(expr) && isWord(expr[j]) {
+			j++
+		}
+		j += s.spaces(j)
+		if j < len(expr) && expr[j] == '(' {
+			return token.FUNC, j + 1 - i
+		}
+		// DATE /now(?:[+-]\d+[yMwdhHms]|\/[yMwdhHms])*\b/
+		if n := s.dateMath(i); n > 0 {
+			return token.DATE, n
+		}
+		// IDENT /[a-zA-Z_][\w\\.\-]*/
+		return token.IDENT, s.ident(i)
+	}
+	switch expr[i] {
+	case '(', '[', '{':
+		return token.LBT, 1
+	case ')', ']', '}':
+		return token.RBT, 1
+	case ',':
+		return token.COMMA, 1
+	}
+	// ILLEGAL /.+/，直到换行
+	if n := strings.IndexByte(expr[i:], '\n'); n >= 0 {
+		return token.ILLEGAL, n
+	}
+	return token.ILLEGAL, len(expr) - i
+}
+
+// spaces i 位置开始的空字符长度 /\s*/
+func (s *scanner) spaces(i int) int {
+	j := i
+	for j < len(s.expr) && isSpace(s.expr[j]) {
+		j++
+	}
+	return j - i
+}
+
+// boundary i 位置是否为单词边界，i 前面是单词字符 /\b/
+func (s *scanner) boundary(i int) bool {
+	return i >= len(s.expr) || !isWord(s.expr[i])
+}
+
+// number 数字的长度，不是数字时返回 0
+func (s *scanner) number(i int) int {
+	expr := s.expr
+	j := i
+	if j < len(expr) && (expr[j] == '+' || expr[j] == '-') {
+		j++
+	}
+	digits := s.digits(j)
+	if digits == 0 {
+		return 0
+	}
+	j += digits
+	if j < len(expr) && expr[j] == '.' {
+		if decimals := s.digits(j + 1); decimals > 0 {
+			j += 1 + decimals
+		}
+	}
+	return j - i
+}
+
+// date ISO-8601 日期的长度，不是日期时返回 0
+//
+//	/\d{4}-\d{2}-\d{2}(?:T\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:Z|[+-]\d{2}:\d{2})?)?/
+func (s *scanner) date(i int) int {
+	j, ok := s.pattern(i, "dddd-dd-dd")
+	if !ok {
+		return 0
+	}
+	k, ok := s.pattern(j, "Tdd:dd")
+	if !ok {
+		return j - i
+	}
+	if l, ok := s.pattern(k, ":dd"); ok {
+		k = l
+		if k < len(s.expr) && s.expr[k] == '.' {
+			if decimals := s.digits(k + 1); decimals > 0 {
+				k += 1 + decimals
+			}
+		}
+	}
+	if k < len(s.expr) && s.expr[k] == 'Z' {
+		k++
+	} else if k < len(s.expr) && (s.expr[k] == '+' || s.expr[k] == '-') {
+		if l, ok := s.pattern(k+1, "dd:dd"); ok {
+			k = l
+		}
+	}
+	return k - i
+}
+
+// pattern 从 i 位置开始匹配 pattern，d 匹配一个数字，其他字符原样匹配，返回匹配后的位置
+func (s *scanner) pattern(i int, pattern string) (int, bool) {
+	if len(s.expr)-i < len(pattern) {
+		return i, false
+	}
+	for k := 0; k < len(pattern); k++ {
+		c := s.expr[i+k]
+		if pattern[k] == 'd' && (c < '0' || c > '9') || pattern[k] != 'd' && c != pattern[k] {
+			return i, false
+		}
+	}
+	return i + len(pattern), true
+}
+
+// dateMath 相对日期的长度，不是相对日期时返回 0
+//
+//	与正则一致，取满足单词边界的最长匹配
+func (s *scanner) dateMath(i int) int {
+	expr := s.expr
+	if !strings.HasPrefix(expr[i:], "now") {
+		return 0
+	}
+	j := i + 3
+	end := i
+	if s.boundary(j) {
+		end = j
+	}
+	for j < len(expr) {
+		k := j
+		switch expr[k] {
+		case '+', '-':
+			digits := s.digits(k + 1)
+			if digits == 0 {
+				return end - i
+			}
+			k += 1 + digits
+		case '/':
+			k++
+		default:
+			return end - i
+		}
+		if k >= len(expr) || !strings.ContainsRune("yMwdhHms", rune(expr[k])) {
+			return end - i
+		}
+		j = k + 1
+		if s.boundary(j) {
+			end = j
+		}
+	}
+	return end - i
+}
+
+// digits i 位置开始的数字长度 /\d*/
+func (s *scanner) digits(i int) int {
+	j := i
+	for j < len(s.expr) && s.expr[j] >= '0' && s.expr[j] <= '9' {
+		j++
+	}
+	return j - i
+}
+
+// string 字符串的长度，没有结束的引号时返回 0
+//
+//	/'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"|`[^`]*`/
+func (s *scanner) string(i int) int {
+	expr := s.expr
+	quote := expr[i]
+	switch quote {
+	case '`':
+		if n := strings.IndexByte(expr[i+1:], '`'); n >= 0 {
+			return n + 2
+		}
+		return 0
+	case '\'', '"':
+		for j := i + 1; j < len(expr); j++ {
+			switch expr[j] {
+			case '\\':
+				j++
+			case quote:
+				return j + 1 - i
+			}
+		}
+	}
+	return 0
+}
+
+// ident 字段名的长度 /[a-zA-Z_][\w\\.\-]*/
+func (s *scanner) ident(i int) int {
+	j := i + 1
+	for j < len(s.expr) && (isWord(s.expr[j]) || s.expr[j] == '\\' || s.expr[j] == '.' || s.expr[j] == '-') {
+		j++
+	}
+	return j - i
+}
+
+// isSpace /\s/
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+// isWord /\w/
+func isWord(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// isIdentStart /[a-zA-Z_]/
+func isIdentStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_'
+}
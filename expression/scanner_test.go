@@ -0,0 +1,72 @@
+package expression
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var scannerSeeds = []string{
+	"",
+	"   ",
+	"test == 123 && ( keyword contains 'hello' && a unContainsBit 10)",
+	"port in [80, 443] && user notIn ['a', \"b,c\", true] || !(ip exists\t) && not x isNot null",
+	"lower (user) == `^web-\\d+$` && bytes_out > $bytes_in && ts < now('-1h')",
+	"index == 1 && regex reg 'it\\'s \\u4e2d' && trueish == falsey && nullable is null",
+	"a.b-c\\d == -1.5 && +2 >= 3. && x == 'unterminated",
+	"@@ illegal   \n next == \"line\\\nbreak\" \r\f\v",
+	"中文 == '中文' && a == \xff\xfe",
+	"notExists\texists notExistsX existsnot not!",
+	"ts between [now-1d/d, now/d] && ts >= 2024-01-01 && ts < 2024-01-01T08:30:00.5+08:00 && ts > now",
+	"now() == nowhere && a == now-15mx && b == now/ && c == now+1 && d == 2024-01-01T1 && e == 2024-1-01 && now.x == now-1y-2M+3w/H",
+	"user iequals 'Admin' && host like 'web-*.?' && path notLike '*\\*' && is istartsWith 'a' && in icontains 'b' || likes iendsWith 'c'",
+	"src_ip inCidr '10.0.0.0/8' && dst_ip notInCidr ['fd00::/8'] && incidr notIn [1] && inCidrs in 'a'",
+	"likes == 1 && icontainsX == 1 && likeCount > 0 && betweenness < 1 && inCidrList exists && index in[1] && isNot isNot null",
+	"not.x == 1 && not-found == 1 && not\\y == 1 && not(a == 1) && not!b exists && not\ta && not",
+}
+
+func TestTokensRead(t *testing.T) {
+	for _, expr := range scannerSeeds {
+		tokens := TokensRead(expr)
+		regTokens := TokensReadRegexp(expr)
+		if !reflect.DeepEqual(tokens, regTokens) {
+			t.Fatalf("testCase %q need %s but got %s", expr, printTokens(regTokens), printTokens(tokens))
+		}
+	}
+}
+
+func FuzzTokensRead(f *testing.F) {
+	for _, seed := range scannerSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, expr string) {
+		tokens := TokensRead(expr)
+		regTokens := TokensReadRegexp(expr)
+		if !reflect.DeepEqual(tokens, regTokens) {
+			t.Fatalf("expr %q need %s but got %s", expr, printTokens(regTokens), printTokens(tokens))
+		}
+	})
+}
+
+func BenchmarkTokensRead(b *testing.B) {
+	expr := scannerSeeds[3]
+	b.Run("scanner", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			TokensRead(expr)
+		}
+	})
+	b.Run("regexp", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			TokensReadRegexp(expr)
+		}
+	})
+}
+
+// printTokens 打印分词，用于比较
+func printTokens(tokens []*LexNode) string {
+	result := ""
+	for _, item := range tokens {
+		result += fmt.Sprintf("%s(%q)[%d:%d] ", item.Type, fmt.Sprint(item.Value), item.From, item.Len)
+	}
+	return result
+}
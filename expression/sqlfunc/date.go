@@ -0,0 +1,82 @@
+package sqlfunc
+
+import (
+	"time"
+
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+// Date 日期在生成 SQL 时解析为 time.Time，相对日期以生成 SQL 的时间为准
+//
+//	roundUp 为日期的取整方向，> 和 <= 向上取整到周期的最后一毫秒
+func Date(value any, roundUp bool) (t time.Time, ok bool) {
+	date, ok := value.(token.Date)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := date.Time(time.Now(), roundUp)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// DateRange 日期取整的周期 [向下取整, 向上取整]，如：now/d 为今天，2024-01-15 为当天
+func DateRange(value any) (params []any, ok bool) {
+	date, ok := value.(token.Date)
+	if !ok {
+		return nil, false
+	}
+	now := time.Now()
+	from, err := date.Time(now, false)
+	if err != nil {
+		return nil, false
+	}
+	to, err := date.Time(now, true)
+	if err != nil {
+		return nil, false
+	}
+	return []any{from, to}, true
+}
+
+// Between between 的两端，包含两端，日期的结束值向上取整，其他值原样返回
+func Between(value any) (params []any, ok bool) {
+	val, ok := value.([]any)
+	if !ok || len(val) != 2 {
+		return nil, false
+	}
+	now := time.Now()
+	from, ok := DateParam(val[0], now, false)
+	if !ok {
+		return nil, false
+	}
+	to, ok := DateParam(val[1], now, true)
+	if !ok {
+		return nil, false
+	}
+	return []any{from, to}, true
+}
+
+// DateParams 把数组中的日期解析为 time.Time，用于 in、notIn
+func DateParams(val []any, now time.Time) (params []any, ok bool) {
+	params = make([]any, len(val))
+	for i, item := range val {
+		if params[i], ok = DateParam(item, now, false); !ok {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// DateParam 日期解析为 time.Time，其他值原样返回
+func DateParam(item any, now time.Time, roundUp bool) (param any, ok bool) {
+	date, ok := item.(token.Date)
+	if !ok {
+		return item, true
+	}
+	t, err := date.Time(now, roundUp)
+	if err != nil {
+		return nil, false
+	}
+	return t, true
+}
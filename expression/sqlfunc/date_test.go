@@ -0,0 +1,32 @@
+package sqlfunc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+func TestDateParams(t *testing.T) {
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local)
+	dayEnd := time.Date(2024, 1, 10, 23, 59, 59, 999e6, time.Local)
+	if params, ok := DateRange(token.Date("2024-01-10")); !ok || !reflect.DeepEqual(params, []any{day, dayEnd}) {
+		t.Fatalf("DateRange need %v but got %v", []any{day, dayEnd}, params)
+	}
+	if params, ok := Between([]any{token.Date("2024-01-10"), token.Date("2024-01-10")}); !ok || !reflect.DeepEqual(params, []any{day, dayEnd}) {
+		t.Fatalf("Between need %v but got %v", []any{day, dayEnd}, params)
+	}
+	if params, ok := Between([]any{float64(1), float64(2)}); !ok || !reflect.DeepEqual(params, []any{float64(1), float64(2)}) {
+		t.Fatalf("Between need [1 2] but got %v", params)
+	}
+	if params, ok := DateParams([]any{token.Date("2024-01-10"), "x"}, time.Now()); !ok || !reflect.DeepEqual(params, []any{day, "x"}) {
+		t.Fatalf("DateParams need %v but got %v", []any{day, "x"}, params)
+	}
+	if _, ok := Date("2024-01-10", false); ok {
+		t.Fatalf("Date need a token.Date")
+	}
+	if _, ok := Between([]any{token.Date("2024-13-45"), token.Date("now")}); ok {
+		t.Fatalf("Between need failure on illegal date")
+	}
+}
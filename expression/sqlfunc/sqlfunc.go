@@ -0,0 +1,179 @@
+// Package sqlfunc SQL 执行器共用的函数调用转换、日期参数解析，各执行器只需要提供函数名到 sql 的映射及 SQL 的格式
+package sqlfunc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jummyliu/pkg/datetime"
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+// Arg 函数参数
+//
+//	字段参数的 SQL 为映射后的字段名；值参数的 SQL 为 ?，参数为表达式中的值
+type Arg struct {
+	Type   token.Token
+	Value  any
+	SQL    string
+	Params []any
+}
+
+// Func 表达式中的函数，根据参数生成 sql 片段及参数，不支持时返回 ""
+type Func func(args []Arg) (sql string, params []any)
+
+// Call 直接转换为 sql 函数，n 为参数个数，如：Call("LOWER", 1)
+func Call(name string, n int) Func {
+	return func(args []Arg) (sql string, params []any) {
+		if len(args) != n {
+			return "", nil
+		}
+		items := make([]string, 0, len(args))
+		for _, arg := range args {
+			items = append(items, arg.SQL)
+			params = append(params, arg.Params...)
+		}
+		return fmt.Sprintf("%s(%s)", name, strings.Join(items, ", ")), params
+	}
+}
+
+// Now 当前时间，now() 转换为 sql，now('-1h') 使用 offset 根据偏移的秒数生成 sql 片段及参数
+func Now(sql string, offset func(seconds int64) (sql string, params []any)) Func {
+	return func(args []Arg) (string, []any) {
+		if len(args) == 0 {
+			return sql, nil
+		}
+		if len(args) != 1 || args[0].Type != token.STRING {
+			return "", nil
+		}
+		d, err := datetime.ParseDuration(args[0].Value.(string))
+		if err != nil {
+			return "", nil
+		}
+		return offset(int64(d / time.Second))
+	}
+}
+
+// Compare 字段与函数调用比较，value 为函数调用生成的 Arg
+func Compare(op string) func(key string, value any) (sql string, params []any) {
+	return func(key string, value any) (sql string, params []any) {
+		arg, ok := value.(Arg)
+		if !ok {
+			return "", nil
+		}
+		return fmt.Sprintf("%s %s %s", key, op, arg.SQL), arg.Params
+	}
+}
+
+// KeyHolder 条件左侧为函数调用时，key 的占位符
+const KeyHolder = "\x00key\x00"
+
+// Convert 把函数调用转换为 sql 片段，buildKey 把参数中的字段转换为映射后的字段名
+func Convert(funcMap map[string]Func, call *expression.AstNode, buildKey func(key string) string) (arg Arg, keys []string, ok bool) {
+	name, _ := call.Value.(string)
+	fn, ok := funcMap[name]
+	if !ok {
+		return arg, nil, false
+	}
+	args := make([]Arg, 0, len(call.Args))
+	for _, item := range call.Args {
+		switch item.Type {
+		case token.FUNC:
+			sub, subKeys, ok := Convert(funcMap, item, buildKey)
+			if !ok {
+				return arg, nil, false
+			}
+			args = append(args, sub)
+			keys = append(keys, subKeys...)
+		case token.IDENT:
+			key, ok := item.Value.(string)
+			if !ok {
+				return arg, nil, false
+			}
+			key = buildKey(key)
+			args = append(args, Arg{Type: item.Type, Value: item.Value, SQL: key})
+			keys = append(keys, key)
+		case token.NULL:
+			args = append(args, Arg{Type: item.Type, SQL: "NULL"})
+		default:
+			args = append(args, Arg{Type: item.Type, Value: item.Value, SQL: "?", Params: []any{item.Value}})
+		}
+	}
+	sql, params := fn(args)
+	if len(sql) == 0 {
+		return arg, nil, false
+	}
+	return Arg{Type: token.FUNC, Value: name, SQL: sql, Params: params}, keys, true
+}
+
+// Check 检查语法树中的函数调用，返回第一个不支持的函数，funcMap 为 nil 时不支持任何函数调用
+//
+//	函数名不存在、参数个数或类型不支持时都会返回错误，避免生成空的条件
+func Check(funcMap map[string]Func, ast *expression.AstNode) error {
+	if ast == nil {
+		return nil
+	}
+	switch ast.Type {
+	case token.OPERATOR:
+		if err := Check(funcMap, ast.Left); err != nil {
+			return err
+		}
+		return Check(funcMap, ast.Right)
+	case token.NOT:
+		return Check(funcMap, ast.Left)
+	case token.QUANT:
+		return Check(funcMap, ast.Right)
+	case token.CONDITION:
+		for _, node := range []*expression.AstNode{ast.Left, ast.Right} {
+			if node == nil || node.Type != token.FUNC {
+				continue
+			}
+			if _, _, ok := Convert(funcMap, node, func(key string) string { return key }); !ok {
+				return fmt.Errorf("unsupported function: %s", unsupported(funcMap, node))
+			}
+		}
+	}
+	return nil
+}
+
+// unsupported 函数调用中第一个不支持的函数名，参数都支持时为 call 本身
+func unsupported(funcMap map[string]Func, call *expression.AstNode) string {
+	name, _ := call.Value.(string)
+	if _, ok := funcMap[name]; !ok {
+		return name
+	}
+	for _, arg := range call.Args {
+		if arg.Type != token.FUNC {
+			continue
+		}
+		if _, _, ok := Convert(funcMap, arg, func(key string) string { return key }); !ok {
+			return unsupported(funcMap, arg)
+		}
+	}
+	return name
+}
+
+// ReplaceKey 把 sql 中的 key 占位符替换为函数调用，并把函数调用的参数插入到对应的位置
+func ReplaceKey(sql string, params []any, left Arg) (string, []any) {
+	var b strings.Builder
+	newParams := make([]any, 0, len(params)+len(left.Params))
+	used := 0
+	for {
+		idx := strings.Index(sql, KeyHolder)
+		if idx == -1 {
+			break
+		}
+		n := min(strings.Count(sql[:idx], "?"), len(params)-used)
+		newParams = append(newParams, params[used:used+n]...)
+		newParams = append(newParams, left.Params...)
+		used += n
+		b.WriteString(sql[:idx])
+		b.WriteString(left.SQL)
+		sql = sql[idx+len(KeyHolder):]
+	}
+	b.WriteString(sql)
+	newParams = append(newParams, params[used:]...)
+	return b.String(), newParams
+}
@@ -0,0 +1,16 @@
+package sqlfunc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReplaceKey(t *testing.T) {
+	left := Arg{SQL: "DATE_ADD(NOW(), INTERVAL ? SECOND)", Params: []any{int64(-3600)}}
+	sql, params := ReplaceKey("? < "+KeyHolder+" AND "+KeyHolder+" < ?", []any{"a", "b"}, left)
+	needSQL := "? < DATE_ADD(NOW(), INTERVAL ? SECOND) AND DATE_ADD(NOW(), INTERVAL ? SECOND) < ?"
+	needParams := []any{"a", int64(-3600), int64(-3600), "b"}
+	if sql != needSQL || !reflect.DeepEqual(params, needParams) {
+		t.Fatalf("need %s %v but got %s %v", needSQL, needParams, sql, params)
+	}
+}
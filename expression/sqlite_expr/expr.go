@@ -0,0 +1,209 @@
+package sqlite_expr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/sqlfunc"
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+const (
+	lbt         = "( "
+	rbt         = " )"
+	operatorAnd = " AND "
+	operatorOr  = " OR "
+	operatorNot = "NOT "
+	// matchNone 不匹配任何数据，用于 builder 生成的 ILLEGAL 节点
+	matchNone = "1 = 0"
+
+	lenAnd = len(lbt) + len(rbt) + len(operatorAnd)
+	lenOr  = len(lbt) + len(rbt) + len(operatorOr)
+	lenNot = len(lbt) + len(rbt) + len(operatorNot)
+)
+
+type Executor struct {
+	FnMap map[string]map[token.Token]ConditionFn
+
+	// KeyMap 字段映射
+	// 	存在映射 => key 转换为映射值
+	KeyMap map[string]string
+
+	// FuncMap 表达式中可以调用的函数
+	FuncMap map[string]Func
+}
+
+var StdExecutor = New(nil, nil)
+
+func New(fnMap map[string]map[token.Token]ConditionFn, keyMap map[string]string) *Executor {
+	if fnMap == nil {
+		fnMap = DefaultFnMap
+	}
+	return &Executor{
+		FnMap: fnMap,
+
+		KeyMap:  keyMap,
+		FuncMap: DefaultFuncMap,
+	}
+}
+
+// DoExpr 执行表达式，不支持数组元素条件 any、all，存在不支持的函数调用时返回错误
+func (e *Executor) DoExpr(expr string, prefix, suffix string) (sqls string, params []any, keys []string, err error) {
+	lexTokens, err := expression.LexParse(expression.TokensRead(expr))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	ast := expression.AstParse(lexTokens)
+	if err := sqlfunc.Check(e.FuncMap, ast); err != nil {
+		return "", nil, nil, err
+	}
+	if err := expression.CheckQuant(ast); err != nil {
+		return "", nil, nil, err
+	}
+	sqls, params, keys = e.DoAst(ast, prefix, suffix)
+	return sqls, params, keys, nil
+}
+
+// DoAst 执行 ast
+func (e *Executor) DoAst(ast *expression.AstNode, prefix, suffix string) (sqls string, params []any, keys []string) {
+	if ast == nil {
+		return "", nil, nil
+	}
+	switch ast.Type {
+	case token.OPERATOR:
+		// 复合转换
+		leftSQL, leftParams, leftKeys := e.DoAst(ast.Left, prefix, suffix)
+		rightSQL, rightParams, rightKeys := e.DoAst(ast.Right, prefix, suffix)
+		if len(leftSQL) == 0 || len(rightSQL) == 0 {
+			return "", nil, nil
+		}
+		switch ast.Value.(string) {
+		case "&&":
+			var b strings.Builder
+			b.Grow(len(leftSQL) + len(rightSQL) + lenAnd)
+			b.WriteString(lbt)
+			b.WriteString(leftSQL)
+			b.WriteString(operatorAnd)
+			b.WriteString(rightSQL)
+			b.WriteString(rbt)
+			params = append(params, leftParams...)
+			params = append(params, rightParams...)
+			keys = append(keys, leftKeys...)
+			keys = append(keys, rightKeys...)
+			return b.String(), params, keys
+		case "||":
+			var b strings.Builder
+			b.Grow(len(leftSQL) + len(rightSQL) + lenOr)
+			b.WriteString(lbt)
+			b.WriteString(leftSQL)
+			b.WriteString(operatorOr)
+			b.WriteString(rightSQL)
+			b.WriteString(rbt)
+			params = append(params, leftParams...)
+			params = append(params, rightParams...)
+			keys = append(keys, leftKeys...)
+			keys = append(keys, rightKeys...)
+			return b.String(), params, keys
+		}
+	case token.NOT:
+		// 取反
+		subSQL, subParams, subKeys := e.DoAst(ast.Left, prefix, suffix)
+		if len(subSQL) == 0 {
+			return "", nil, nil
+		}
+		var b strings.Builder
+		b.Grow(len(subSQL) + lenNot)
+		b.WriteString(operatorNot)
+		b.WriteString(lbt)
+		b.WriteString(subSQL)
+		b.WriteString(rbt)
+		return b.String(), subParams, subKeys
+	case token.ILLEGAL:
+		// 不合法的条件，不能退化为查询全部
+		return matchNone, nil, nil
+	case token.CONDITION:
+		// 单个表达式转换
+		return e.DoTerm(ast, prefix, suffix)
+	}
+	return "", nil, nil
+}
+
+// DoTerm 执行 term
+func (e *Executor) DoTerm(term *expression.AstNode, prefix, suffix string) (sql string, params []any, keys []string) {
+	// 判断是否有 left 和 right
+	if term.Left == nil || term.Right == nil {
+		return "", nil, nil
+	}
+	// 判断 left，即 key 的类型
+	if _, ok := term.Left.Value.(string); !ok {
+		return "", nil, nil
+	}
+	// 判断 condition 的类型
+	if _, ok := term.Value.(string); !ok {
+		return "", nil, nil
+	}
+	fns, ok := e.FnMap[term.Value.(string)]
+	if !ok {
+		return "", nil, nil
+	}
+	fn, ok := fns[term.Right.Type]
+	if !ok {
+		return "", nil, nil
+	}
+	var key string
+	var left Arg
+	if term.Left.Type == token.FUNC {
+		// 函数调用，先使用占位符生成 sql，再替换为函数调用
+		left, keys, ok = e.call(term.Left, prefix, suffix)
+		if !ok {
+			return "", nil, nil
+		}
+		key = sqlfunc.KeyHolder
+	} else {
+		key = e.buildKey(term.Left.Value.(string), prefix, suffix)
+		keys = []string{key}
+	}
+	value := term.Right.Value
+	switch term.Right.Type {
+	case token.FIELD:
+		// 字段与字段比较，值为映射后的字段名
+		field, ok := term.Right.Value.(string)
+		if !ok {
+			return "", nil, nil
+		}
+		field = e.buildKey(field, prefix, suffix)
+		keys = append(keys, field)
+		value = field
+	case token.FUNC:
+		// 字段与函数调用比较，值为函数调用生成的 Arg
+		right, rightKeys, ok := e.call(term.Right, prefix, suffix)
+		if !ok {
+			return "", nil, nil
+		}
+		keys = append(keys, rightKeys...)
+		value = right
+	}
+	sql, params = fn(key, value)
+	if len(sql) == 0 {
+		return "", nil, nil
+	}
+	if term.Left.Type == token.FUNC {
+		sql, params = sqlfunc.ReplaceKey(sql, params, left)
+	}
+	return sql, params, keys
+}
+
+// buildKey 字段映射，并拼接前缀、后缀
+func (e *Executor) buildKey(key string, prefix, suffix string) string {
+	if _, ok := e.KeyMap[key]; ok {
+		key = e.KeyMap[key]
+	}
+	if len(prefix) > 0 {
+		key = fmt.Sprintf("%s.%s", prefix, key)
+	}
+	if len(suffix) > 0 {
+		key = fmt.Sprintf("%s.%s", key, suffix)
+	}
+	return key
+}
@@ -0,0 +1,87 @@
+package sqlite_expr
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jummyliu/pkg/db/sqlitebuilder"
+	"github.com/jummyliu/pkg/expression/cond_expr"
+	"github.com/jummyliu/pkg/expression/internal/sqlitetest"
+)
+
+var testDocs = []map[string]any{
+	{"id": float64(1), "port": float64(443), "user": "Admin", "host": "web-01", "flags": float64(6), "deleted": nil, "bytes_in": float64(100), "bytes_out": float64(200)},
+	{"id": float64(2), "port": float64(80), "user": "root", "host": "db_1", "flags": float64(1), "deleted": "yes", "bytes_in": float64(300), "bytes_out": float64(200)},
+	{"id": float64(3), "port": float64(8080), "user": "guest", "host": "dbx1", "flags": float64(4), "deleted": nil, "bytes_in": nil, "bytes_out": float64(10)},
+	{"id": float64(4), "port": float64(22), "user": "ADMIN", "host": "web-100%", "flags": float64(0), "deleted": "no", "bytes_in": float64(1), "bytes_out": float64(1)},
+}
+
+var testColumns = []string{"id", "port", "user", "host", "flags", "deleted", "bytes_in", "bytes_out"}
+
+// newTestDB 创建内存数据库，写入 testDocs
+func newTestDB(t *testing.T) *sqlitebuilder.DBConnect {
+	db := sqlitetest.New(t, "CREATE TABLE docs (id INTEGER, port REAL, user TEXT, host TEXT, flags INTEGER, deleted TEXT, bytes_in REAL, bytes_out REAL)")
+	sqlitetest.Insert(t, db, "docs", testColumns, testDocs)
+	return db
+}
+
+func TestDoExpr(t *testing.T) {
+	db := newTestDB(t)
+	testCases := []string{
+		"port == 443",
+		"port >= 80 && port < 8080",
+		"user == 'admin'",
+		"user != 'admin'",
+		"host startsWith 'WEB' || host endsWith '_1'",
+		"host contains '0%'",
+		"host reg '^web-\\d+$'",
+		"user in 'root,guest' || user notIn 'Admin,root,guest'",
+		"port in [80, 443] && port notIn [443]",
+		"flags containsBit 4 && flags unContainsBit 2",
+		"deleted == null",
+		"deleted != null && deleted isNot null",
+		"bytes_out > $bytes_in",
+		"lower(user) == 'admin' && len(host) > 5",
+		"!(port == 443) && user contains 'O'",
+		"host < 'dbz' && host >= 'db'",
+		"user iequals 'ADMIN' || host icontains '_'",
+		"host istartsWith 'WEB-1' || host iendsWith 'X1'",
+		"host like 'web-*%' || host like 'DB?1'",
+		"host notLike 'web-*' && user like '*o*'",
+	}
+	for _, testCase := range testCases {
+		sql, params, _, err := StdExecutor.DoExpr(testCase, "", "")
+		if err != nil {
+			t.Fatalf("testCase %s DoExpr failure: %s", testCase, err)
+		}
+		if sql == "" {
+			t.Fatalf("testCase %s need sql but got empty", testCase)
+		}
+		got := sqlitetest.QueryIDs(t, db, "docs", sql, params)
+		need := []int{}
+		for _, doc := range testDocs {
+			if result, _, _ := cond_expr.StdExecutor.DoExpr(doc, testCase, "", ""); result {
+				need = append(need, int(doc["id"].(float64)))
+			}
+		}
+		if !reflect.DeepEqual(got, need) {
+			t.Fatalf("testCase %s\nsql: %s %v\nneed %v but got %v", testCase, sql, params, need, got)
+		}
+	}
+}
+
+func TestDoExprNow(t *testing.T) {
+	db := newTestDB(t)
+	sql, params, _, err := StdExecutor.DoExpr("now('-1h') < now() && now('+1d') > now()", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, _, err := db.Query(context.Background(), "SELECT id FROM docs WHERE "+sql, params...)
+	if err != nil {
+		t.Fatalf("query failure: %s", err)
+	}
+	if len(results) != len(testDocs) {
+		t.Fatalf("sql %s %v need %d rows but got %d", sql, params, len(testDocs), len(results))
+	}
+}
@@ -0,0 +1,281 @@
+package sqlite_expr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/sqlfunc"
+	"github.com/jummyliu/pkg/expression/token"
+	"github.com/jummyliu/pkg/number"
+)
+
+type ConditionFn func(key string, value any) (sqls string, params []any)
+
+var DefaultFnMap = map[string]map[token.Token]ConditionFn{
+	"==": {
+		token.NUM:    equal[float64],
+		token.BOOL:   equal[bool],
+		token.STRING: equalStr,
+		token.NULL:   isNull,
+		token.FIELD:  fieldCompare("="),
+		token.FUNC:   sqlfunc.Compare("="),
+	},
+	"!=": {
+		token.NUM:    unEqual[float64],
+		token.BOOL:   unEqual[bool],
+		token.STRING: unEqualStr,
+		token.NULL:   isNotNull,
+		token.FIELD:  fieldCompare("!="),
+		token.FUNC:   sqlfunc.Compare("!="),
+	},
+	">=": {
+		token.NUM:    compare[float64](">="),
+		token.STRING: compare[string](">="),
+		token.FIELD:  fieldCompare(">="),
+		token.FUNC:   sqlfunc.Compare(">="),
+	},
+	"<=": {
+		token.NUM:    compare[float64]("<="),
+		token.STRING: compare[string]("<="),
+		token.FIELD:  fieldCompare("<="),
+		token.FUNC:   sqlfunc.Compare("<="),
+	},
+	">": {
+		token.NUM:    compare[float64](">"),
+		token.STRING: compare[string](">"),
+		token.FIELD:  fieldCompare(">"),
+		token.FUNC:   sqlfunc.Compare(">"),
+	},
+	"<": {
+		token.NUM:    compare[float64]("<"),
+		token.STRING: compare[string]("<"),
+		token.FIELD:  fieldCompare("<"),
+		token.FUNC:   sqlfunc.Compare("<"),
+	},
+	"contains": {
+		token.STRING: like("LIKE", "%%%s%%"),
+	},
+	"unContains": {
+		token.STRING: like("NOT LIKE", "%%%s%%"),
+	},
+	"startsWith": {
+		token.STRING: like("LIKE", "%s%%"),
+	},
+	"unStartsWith": {
+		token.STRING: like("NOT LIKE", "%s%%"),
+	},
+	"endsWith": {
+		token.STRING: like("LIKE", "%%%s"),
+	},
+	"unEndsWith": {
+		token.STRING: like("NOT LIKE", "%%%s"),
+	},
+	"iequals": {
+		token.STRING: equalStr,
+	},
+	"icontains": {
+		token.STRING: like("LIKE", "%%%s%%"),
+	},
+	"istartsWith": {
+		token.STRING: like("LIKE", "%s%%"),
+	},
+	"iendsWith": {
+		token.STRING: like("LIKE", "%%%s"),
+	},
+	"like": {
+		token.STRING: wildcard("LIKE"),
+	},
+	"notLike": {
+		token.STRING: wildcard("NOT LIKE"),
+	},
+	"reg": {
+		token.STRING: reg,
+	},
+	"in": {
+		token.STRING: in,
+		token.ARRAY:  inArray,
+	},
+	"notIn": {
+		token.STRING: notIn,
+		token.ARRAY:  notInArray,
+	},
+	"containsBit": {
+		token.NUM:    containsBit,
+		token.STRING: containsBit,
+	},
+	"unContainsBit": {
+		token.NUM:    unContainsBit,
+		token.STRING: unContainsBit,
+	},
+	"is": {
+		token.NULL: isNull,
+	},
+	"isNot": {
+		token.NULL: isNotNull,
+	},
+	"exists": {
+		token.NULL: isNotNull,
+	},
+	"notExists": {
+		token.NULL: isNull,
+	},
+	"&": {},
+	"|": {},
+}
+
+// fieldCompare 字段与字段比较，value 为映射后的字段名
+func fieldCompare(op string) ConditionFn {
+	return func(key string, value any) (sql string, params []any) {
+		field, ok := value.(string)
+		if !ok {
+			return "", nil
+		}
+		return fmt.Sprintf("%s %s %s", key, op, field), nil
+	}
+}
+
+// isNull IS NULL
+func isNull(key string, value any) (sql string, params []any) {
+	return fmt.Sprintf("%s IS NULL", key), nil
+}
+
+// isNotNull IS NOT NULL
+func isNotNull(key string, value any) (sql string, params []any) {
+	return fmt.Sprintf("%s IS NOT NULL", key), nil
+}
+
+func equal[T comparable](key string, value any) (sql string, params []any) {
+	val, ok := value.(T)
+	if !ok {
+		return "", nil
+	}
+	return fmt.Sprintf("%s = ?", key), []any{val}
+}
+
+// equalStr 字符串相等，忽略大小写
+func equalStr(key string, value any) (sql string, params []any) {
+	val, ok := value.(string)
+	if !ok {
+		return "", nil
+	}
+	return fmt.Sprintf("%s = ? COLLATE NOCASE", key), []any{val}
+}
+
+func unEqual[T comparable](key string, value any) (sql string, params []any) {
+	val, ok := value.(T)
+	if !ok {
+		return "", nil
+	}
+	return fmt.Sprintf("%s != ?", key), []any{val}
+}
+
+// unEqualStr 字符串不相等，忽略大小写
+func unEqualStr(key string, value any) (sql string, params []any) {
+	val, ok := value.(string)
+	if !ok {
+		return "", nil
+	}
+	return fmt.Sprintf("%s != ? COLLATE NOCASE", key), []any{val}
+}
+
+// compare 比较运算，>=、<=、>、<
+func compare[T int64 | float64 | string](op string) ConditionFn {
+	return func(key string, value any) (sql string, params []any) {
+		val, ok := value.(T)
+		if !ok {
+			return "", nil
+		}
+		return fmt.Sprintf("%s %s ?", key, op), []any{val}
+	}
+}
+
+// like LIKE 匹配，忽略大小写（ASCII），format 中的 %s 为转义后的值
+func like(op string, format string) ConditionFn {
+	return func(key string, value any) (sql string, params []any) {
+		val, ok := value.(string)
+		if !ok {
+			return "", nil
+		}
+		return fmt.Sprintf("%s %s ? ESCAPE '\\'", key, op), []any{fmt.Sprintf(format, expression.EscapeLike(val))}
+	}
+}
+
+// reg REGEXP，需要注册 regexp 函数，见 sqlitebuilder.WithRegexp
+func reg(key string, value any) (sql string, params []any) {
+	val, ok := value.(string)
+	if !ok {
+		return "", nil
+	}
+	return fmt.Sprintf("%s REGEXP ?", key), []any{val}
+}
+
+// wildcard 通配符匹配，忽略大小写（ASCII），通配符转换为 LIKE 的匹配模式
+func wildcard(op string) ConditionFn {
+	return func(key string, value any) (sql string, params []any) {
+		val, ok := value.(string)
+		if !ok {
+			return "", nil
+		}
+		return fmt.Sprintf("%s %s ? ESCAPE '\\'", key, op), []any{expression.WildcardToLike(val)}
+	}
+}
+
+// in 逗号分隔的字符串中包含字段的值
+func in(key string, value any) (sql string, params []any) {
+	val, ok := value.(string)
+	if !ok {
+		return "", nil
+	}
+	return fmt.Sprintf("instr(',' || ? || ',', ',' || %s || ',') > 0", key), []any{val}
+}
+
+// notIn 逗号分隔的字符串中不包含字段的值
+func notIn(key string, value any) (sql string, params []any) {
+	val, ok := value.(string)
+	if !ok {
+		return "", nil
+	}
+	return fmt.Sprintf("instr(',' || ? || ',', ',' || %s || ',') = 0", key), []any{val}
+}
+
+// inArray IN (?, ?)，空数组恒为 false
+func inArray(key string, value any) (sql string, params []any) {
+	val, ok := value.([]any)
+	if !ok {
+		return "", nil
+	}
+	if len(val) == 0 {
+		return "1 = 0", nil
+	}
+	return fmt.Sprintf("%s IN (%s)", key, placeholders(len(val))), val
+}
+
+// notInArray NOT IN (?, ?)，空数组恒为 true
+func notInArray(key string, value any) (sql string, params []any) {
+	val, ok := value.([]any)
+	if !ok {
+		return "", nil
+	}
+	if len(val) == 0 {
+		return "1 = 1", nil
+	}
+	return fmt.Sprintf("%s NOT IN (%s)", key, placeholders(len(val))), val
+}
+
+// placeholders n 个占位符，?, ?, ?
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// containsBit 位运算不进行类型判断，直接转成 int64
+func containsBit(key string, value any) (sql string, params []any) {
+	intVal := number.ParseInt[int64](value)
+	return fmt.Sprintf("%s & ? = ?", key), []any{intVal, intVal}
+}
+
+// unContainsBit 位运算不进行类型判断，直接转成 int64
+func unContainsBit(key string, value any) (sql string, params []any) {
+	intVal := number.ParseInt[int64](value)
+	return fmt.Sprintf("%s & ? != ?", key), []any{intVal, intVal}
+}
@@ -0,0 +1,36 @@
+package sqlite_expr
+
+import (
+	"fmt"
+
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/sqlfunc"
+)
+
+// Arg 函数参数
+type Arg = sqlfunc.Arg
+
+// Func 表达式中的函数，根据参数生成 sql 片段及参数，不支持时返回 ""
+type Func = sqlfunc.Func
+
+// DefaultFuncMap 默认函数
+var DefaultFuncMap = map[string]Func{
+	"lower": sqlfunc.Call("lower", 1),
+	"upper": sqlfunc.Call("upper", 1),
+	"len":   sqlfunc.Call("length", 1),
+	"now":   sqlfunc.Now("datetime('now', 'localtime')", nowOffset),
+}
+
+// nowOffset 本地时间，格式与 datetime.FormatDate 一致
+//
+//	now() => datetime('now', 'localtime')，now('-1h') => datetime('now', 'localtime', ?)
+func nowOffset(seconds int64) (sql string, params []any) {
+	return "datetime('now', 'localtime', ?)", []any{fmt.Sprintf("%+d seconds", seconds)}
+}
+
+// call 把函数调用转换为 sql 片段
+func (e *Executor) call(call *expression.AstNode, prefix, suffix string) (arg Arg, keys []string, ok bool) {
+	return sqlfunc.Convert(e.FuncMap, call, func(key string) string {
+		return e.buildKey(key, prefix, suffix)
+	})
+}
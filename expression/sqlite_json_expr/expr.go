@@ -0,0 +1,177 @@
+package sqlite_json_expr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jummyliu/pkg/expression"
+	"github.com/jummyliu/pkg/expression/sqlfunc"
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+const (
+	lbt         = "( "
+	rbt         = " )"
+	operatorAnd = " AND "
+	operatorOr  = " OR "
+	operatorNot = "NOT "
+	// matchNone 不匹配任何数据，用于 builder 生成的 ILLEGAL 节点
+	matchNone = "1 = 0"
+
+	lenAnd = len(lbt) + len(rbt) + len(operatorAnd)
+	lenOr  = len(lbt) + len(rbt) + len(operatorOr)
+	lenNot = len(lbt) + len(rbt) + len(operatorNot)
+)
+
+type Executor struct {
+	FnMap map[string]map[token.Token]ConditionFn
+
+	// KeyMap 字段映射
+	// 	存在映射 => key 转换为映射值
+	KeyMap map[string]string
+}
+
+var StdExecutor = New(nil, nil)
+
+func New(fnMap map[string]map[token.Token]ConditionFn, keyMap map[string]string) *Executor {
+	if fnMap == nil {
+		fnMap = DefaultFnMap
+	}
+	return &Executor{
+		FnMap: fnMap,
+
+		KeyMap: keyMap,
+	}
+}
+
+// DoExpr 执行表达式，不支持数组元素条件 any、all，不支持函数调用
+func (e *Executor) DoExpr(expr string, prefix, suffix string, jsonAttr string) (sqls string, params []any, keys []string, err error) {
+	lexTokens, err := expression.LexParse(expression.TokensRead(expr))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	ast := expression.AstParse(lexTokens)
+	if err := sqlfunc.Check(nil, ast); err != nil {
+		return "", nil, nil, err
+	}
+	if err := expression.CheckQuant(ast); err != nil {
+		return "", nil, nil, err
+	}
+	sqls, params, keys = e.DoAst(ast, prefix, suffix, jsonAttr)
+	return sqls, params, keys, nil
+}
+
+// DoAst 执行 ast
+func (e *Executor) DoAst(ast *expression.AstNode, prefix, suffix string, jsonAttr string) (sqls string, params []any, keys []string) {
+	if ast == nil {
+		return "", nil, nil
+	}
+	switch ast.Type {
+	case token.OPERATOR:
+		// 复合转换
+		leftSQL, leftParams, leftKeys := e.DoAst(ast.Left, prefix, suffix, jsonAttr)
+		rightSQL, rightParams, rightKeys := e.DoAst(ast.Right, prefix, suffix, jsonAttr)
+		if len(leftSQL) == 0 || len(rightSQL) == 0 {
+			return "", nil, nil
+		}
+		switch ast.Value.(string) {
+		case "&&":
+			var b strings.Builder
+			b.Grow(len(leftSQL) + len(rightSQL) + lenAnd)
+			b.WriteString(lbt)
+			b.WriteString(leftSQL)
+			b.WriteString(operatorAnd)
+			b.WriteString(rightSQL)
+			b.WriteString(rbt)
+			params = append(params, leftParams...)
+			params = append(params, rightParams...)
+			keys = append(keys, leftKeys...)
+			keys = append(keys, rightKeys...)
+			return b.String(), params, keys
+		case "||":
+			var b strings.Builder
+			b.Grow(len(leftSQL) + len(rightSQL) + lenOr)
+			b.WriteString(lbt)
+			b.WriteString(leftSQL)
+			b.WriteString(operatorOr)
+			b.WriteString(rightSQL)
+			b.WriteString(rbt)
+			params = append(params, leftParams...)
+			params = append(params, rightParams...)
+			keys = append(keys, leftKeys...)
+			keys = append(keys, rightKeys...)
+			return b.String(), params, keys
+		}
+	case token.NOT:
+		// 取反
+		subSQL, subParams, subKeys := e.DoAst(ast.Left, prefix, suffix, jsonAttr)
+		if len(subSQL) == 0 {
+			return "", nil, nil
+		}
+		var b strings.Builder
+		b.Grow(len(subSQL) + lenNot)
+		b.WriteString(operatorNot)
+		b.WriteString(lbt)
+		b.WriteString(subSQL)
+		b.WriteString(rbt)
+		return b.String(), subParams, subKeys
+	case token.ILLEGAL:
+		// 不合法的条件，不能退化为查询全部
+		return matchNone, nil, nil
+	case token.CONDITION:
+		// 单个表达式转换
+		return e.DoTerm(ast, prefix, suffix, jsonAttr)
+	}
+	return "", nil, nil
+}
+
+// DoTerm 执行 term
+func (e *Executor) DoTerm(term *expression.AstNode, prefix, suffix string, jsonAttr string) (sql string, params []any, keys []string) {
+	// 判断是否有 left 和 right
+	if term.Left == nil || term.Right == nil {
+		return "", nil, nil
+	}
+	// 判断 left，即 key 的类型，不支持函数调用
+	if _, ok := term.Left.Value.(string); !ok || term.Left.Type == token.FUNC {
+		return "", nil, nil
+	}
+	// 判断 condition 的类型
+	if _, ok := term.Value.(string); !ok {
+		return "", nil, nil
+	}
+	fns, ok := e.FnMap[term.Value.(string)]
+	if !ok {
+		return "", nil, nil
+	}
+	fn, ok := fns[term.Right.Type]
+	if !ok {
+		return "", nil, nil
+	}
+	key := e.buildKey(term.Left.Value.(string), prefix, suffix)
+	if term.Right.Type == token.FIELD {
+		// 字段与字段比较，值为映射后的字段名
+		field, ok := term.Right.Value.(string)
+		if !ok {
+			return "", nil, nil
+		}
+		field = e.buildKey(field, prefix, suffix)
+		sql, params = fn(key, field, jsonAttr)
+		return sql, params, []string{key, field}
+	}
+	sql, params = fn(key, term.Right.Value, jsonAttr)
+	return sql, params, []string{key}
+}
+
+// buildKey 字段映射，并拼接前缀、后缀
+func (e *Executor) buildKey(key string, prefix, suffix string) string {
+	if _, ok := e.KeyMap[key]; ok {
+		key = e.KeyMap[key]
+	}
+	if len(prefix) > 0 {
+		key = fmt.Sprintf("%s.%s", prefix, key)
+	}
+	if len(suffix) > 0 {
+		key = fmt.Sprintf("%s.%s", key, suffix)
+	}
+	return key
+}
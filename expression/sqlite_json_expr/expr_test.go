@@ -0,0 +1,67 @@
+package sqlite_json_expr
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/jummyliu/pkg/db/sqlitebuilder"
+	"github.com/jummyliu/pkg/expression/cond_expr"
+	"github.com/jummyliu/pkg/expression/internal/sqlitetest"
+)
+
+var testDocs = []map[string]any{
+	{"id": float64(1), "port": float64(443), "enabled": true, "user": "Admin", "host": map[string]any{"name": "web-01", "ip": "10.0.0.1"}, "deleted": nil, "bytes_in": float64(100), "bytes_out": float64(200)},
+	{"id": float64(2), "port": float64(80), "enabled": false, "user": "root", "host": map[string]any{"name": "db_1"}, "bytes_in": float64(300), "bytes_out": float64(200)},
+	{"id": float64(3), "port": float64(8080), "user": "guest's", "host": map[string]any{"name": "dbx1", "ip": nil}, "deleted": "yes", "bytes_out": float64(10)},
+}
+
+// newTestDB 创建内存数据库，把 testDocs 写入 json 字段 attr
+func newTestDB(t *testing.T) *sqlitebuilder.DBConnect {
+	db := sqlitetest.New(t, "CREATE TABLE docs (id INTEGER, attr TEXT)")
+	rows := make([]map[string]any, 0, len(testDocs))
+	for _, doc := range testDocs {
+		data, _ := json.Marshal(doc)
+		rows = append(rows, map[string]any{"id": doc["id"], "attr": string(data)})
+	}
+	sqlitetest.Insert(t, db, "docs", []string{"id", "attr"}, rows)
+	return db
+}
+
+func TestDoExpr(t *testing.T) {
+	db := newTestDB(t)
+	testCases := []string{
+		"port == 443 || enabled == false",
+		"enabled == true",
+		"user == 'ADMIN' || user == \"guest's\"",
+		"user contains \"'\"",
+		"host.name startsWith 'db' && host.name notIn 'dbx1'",
+		"host.ip exists",
+		"host.ip notExists || host.ip == null",
+		"deleted exists && deleted == null",
+		"deleted notExists",
+		"bytes_out > $bytes_in || bytes_out == $bytes_out",
+		"port in [80, 8080] && user reg '^g'",
+		"!(port > 100) || port <= 443",
+		"host.name like 'DB?1' || user icontains 'DM'",
+	}
+	for _, testCase := range testCases {
+		sql, params, _, err := StdExecutor.DoExpr(testCase, "", "", "attr")
+		if err != nil {
+			t.Fatalf("testCase %s DoExpr failure: %s", testCase, err)
+		}
+		if sql == "" {
+			t.Fatalf("testCase %s need sql but got empty", testCase)
+		}
+		got := sqlitetest.QueryIDs(t, db, "docs", sql, params)
+		need := []int{}
+		for _, doc := range testDocs {
+			if result, _, _ := cond_expr.StdExecutor.DoExpr(doc, testCase, "", ""); result {
+				need = append(need, int(doc["id"].(float64)))
+			}
+		}
+		if !reflect.DeepEqual(got, need) {
+			t.Fatalf("testCase %s\nsql: %s %v\nneed %v but got %v", testCase, sql, params, need, got)
+		}
+	}
+}
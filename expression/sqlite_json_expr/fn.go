@@ -0,0 +1,89 @@
+package sqlite_json_expr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jummyliu/pkg/expression/sqlite_expr"
+	"github.com/jummyliu/pkg/expression/token"
+)
+
+// buildKey 把 json 属性转换为 json_extract(attr, '$."a"."b"')
+//
+//	json_extract 返回 sql 的值，可以直接使用 sqlite_expr 的条件函数
+func buildKey(key string, jsonAttr string) string {
+	return fmt.Sprintf("json_extract(%s, %s)", jsonAttr, buildPath(key))
+}
+
+// buildPath 把 json 属性转换为 json path 字面值，'$."a"."b"'
+func buildPath(key string) string {
+	keys := strings.Split(key, ".")
+	for i, k := range keys {
+		keys[i] = fmt.Sprintf("\"%s\"", k)
+	}
+	path := fmt.Sprintf("$.%s", strings.Join(keys, "."))
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(path, "'", "''"))
+}
+
+type ConditionFn func(key string, value any, jsonAttr string) (sqls string, params []any)
+
+// DefaultFnMap 在 sqlite_expr.DefaultFnMap 的基础上，把字段替换为 json_extract
+//
+//	exists、notExists 判断 json 路径是否存在；不支持函数调用
+var DefaultFnMap = func() map[string]map[token.Token]ConditionFn {
+	fnMap := ToFnMap(sqlite_expr.DefaultFnMap)
+	fnMap["exists"] = map[token.Token]ConditionFn{
+		token.NULL: exists,
+	}
+	fnMap["notExists"] = map[token.Token]ConditionFn{
+		token.NULL: notExists,
+	}
+	return fnMap
+}()
+
+// ToFnMap 把 sqlite_expr 的条件函数映射转换为 json 条件函数映射
+func ToFnMap(fnMap map[string]map[token.Token]sqlite_expr.ConditionFn) map[string]map[token.Token]ConditionFn {
+	result := make(map[string]map[token.Token]ConditionFn, len(fnMap))
+	for op, fns := range fnMap {
+		result[op] = make(map[token.Token]ConditionFn, len(fns))
+		for tok, fn := range fns {
+			switch tok {
+			case token.FUNC:
+				continue
+			case token.FIELD:
+				result[op][tok] = toFieldConditionFn(fn)
+			default:
+				result[op][tok] = ToConditionFn(fn)
+			}
+		}
+	}
+	return result
+}
+
+// ToConditionFn 把 sqlite_expr 的条件函数转换为 json 条件函数
+func ToConditionFn(fn sqlite_expr.ConditionFn) ConditionFn {
+	return func(key string, value any, jsonAttr string) (sqls string, params []any) {
+		return fn(buildKey(key, jsonAttr), value)
+	}
+}
+
+// toFieldConditionFn 字段与字段比较，value 同样替换为 json_extract
+func toFieldConditionFn(fn sqlite_expr.ConditionFn) ConditionFn {
+	return func(key string, value any, jsonAttr string) (sqls string, params []any) {
+		field, ok := value.(string)
+		if !ok {
+			return "", nil
+		}
+		return fn(buildKey(key, jsonAttr), buildKey(field, jsonAttr))
+	}
+}
+
+// exists 路径存在，json_type 在路径不存在时返回 NULL，值为 null 时返回 'null'
+func exists(key string, value any, jsonAttr string) (sql string, params []any) {
+	return fmt.Sprintf("json_type(%s, %s) IS NOT NULL", jsonAttr, buildPath(key)), nil
+}
+
+// notExists 路径不存在
+func notExists(key string, value any, jsonAttr string) (sql string, params []any) {
+	return fmt.Sprintf("json_type(%s, %s) IS NULL", jsonAttr, buildPath(key)), nil
+}
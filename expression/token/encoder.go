@@ -2,8 +2,14 @@ package token
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
 
+	"github.com/jummyliu/pkg/datetime"
 	"github.com/jummyliu/pkg/number"
 )
 
@@ -23,14 +29,120 @@ func (encoder emptyEncoder) Decode(val string) any {
 
 type stringEncoder struct{}
 
+// Encode 编码字符串，Decode 后与原值一致
+//
+//	包含 \ 且不包含 ` 时使用原始字符串：`^web-\d+`
+//	否则优先使用不需要转义的引号，转义 \、引号和控制字符
 func (encoder stringEncoder) Encode(val any) string {
-	return fmt.Sprintf("'%s'", strings.ReplaceAll(val.(string), "'", "\\'"))
+	str := val.(string)
+	if strings.Contains(str, "\\") && !strings.Contains(str, "`") {
+		return "`" + str + "`"
+	}
+	quote := byte('\'')
+	if strings.Contains(str, "'") && !strings.Contains(str, `"`) {
+		quote = '"'
+	}
+	var b strings.Builder
+	b.Grow(len(str) + 2)
+	b.WriteByte(quote)
+	for i := 0; i < len(str); {
+		r, size := utf8.DecodeRuneInString(str[i:])
+		i += size
+		switch {
+		case r == utf8.RuneError && size == 1:
+			// 非法的 utf8 原样保留
+			b.WriteByte(str[i-1])
+		case r == '\\' || r == rune(quote):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			b.WriteString(fmt.Sprintf(`\u%04x`, r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte(quote)
+	return b.String()
 }
+
+// Decode 解码字符串
+//
+//	原始字符串 `...` 不处理转义
+//	引号字符串支持 \' \" \\ \n \r \t \uXXXX，其他转义保留 \，如：'\d+' => \d+
 func (encoder stringEncoder) Decode(val string) any {
 	if len(val) < 2 {
 		return val
 	}
-	return val[1 : len(val)-1]
+	if val[0] == '`' {
+		return val[1 : len(val)-1]
+	}
+	return unescape(val[1 : len(val)-1])
+}
+
+// unescape 处理转义字符
+func unescape(val string) string {
+	if !strings.Contains(val, "\\") {
+		return val
+	}
+	var b strings.Builder
+	b.Grow(len(val))
+	for i := 0; i < len(val); i++ {
+		if val[i] != '\\' || i+1 >= len(val) {
+			b.WriteByte(val[i])
+			continue
+		}
+		i++
+		switch val[i] {
+		case '\'', '"', '\\':
+			b.WriteByte(val[i])
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			r, n := unescapeUnicode(val[i+1:])
+			if n == 0 {
+				b.WriteString(`\u`)
+				continue
+			}
+			b.WriteRune(r)
+			i += n
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(val[i])
+		}
+	}
+	return b.String()
+}
+
+// unescapeUnicode 解析 \u 后面的 4 位十六进制，代理对需要连续的两个 \uXXXX
+//
+//	返回解析出的字符和使用的长度，解析失败时长度为 0
+func unescapeUnicode(val string) (r rune, n int) {
+	if len(val) < 4 {
+		return 0, 0
+	}
+	code, err := strconv.ParseUint(val[:4], 16, 16)
+	if err != nil {
+		return 0, 0
+	}
+	r = rune(code)
+	if utf16.IsSurrogate(r) && len(val) >= 10 && val[4:6] == `\u` {
+		if low, err := strconv.ParseUint(val[6:10], 16, 16); err == nil {
+			if pair := utf16.DecodeRune(r, rune(low)); pair != utf8.RuneError {
+				return pair, 10
+			}
+		}
+	}
+	return r, 4
 }
 
 type numberEncoder struct{}
@@ -51,9 +163,104 @@ func (encoder boolEncoder) Decode(val string) any {
 	return val == "true"
 }
 
+type fieldEncoder struct{}
+
+func (encoder fieldEncoder) Encode(val any) string {
+	return fmt.Sprintf("$%s", val)
+}
+func (encoder fieldEncoder) Decode(val string) any {
+	return strings.TrimPrefix(val, "$")
+}
+
+type funcEncoder struct{}
+
+// Encode 编码函数名，参数由调用方拼接
+func (encoder funcEncoder) Encode(val any) string {
+	return fmt.Sprintf("%s(", val)
+}
+func (encoder funcEncoder) Decode(val string) any {
+	return strings.TrimSpace(strings.TrimSuffix(val, "("))
+}
+
+type nullEncoder struct{}
+
+func (encoder nullEncoder) Encode(val any) string {
+	return "null"
+}
+func (encoder nullEncoder) Decode(val string) any {
+	return nil
+}
+
+// Date 日期字面量，保留原始的表达式，执行时再解析，相对日期在每次执行时重新计算
+type Date string
+
+// Time 使用 datetime.ParseDateMath 解析日期，roundUp 为 true 时取整到周期的最后一毫秒
+//
+//	只有日期的 ISO-8601 表示一整天，roundUp 时为当天的最后一毫秒，如：2024-01-15 => 2024-01-15 23:59:59.999
+func (d Date) Time(now time.Time, roundUp bool) (time.Time, error) {
+	t, err := datetime.ParseDateMath(string(d), now, roundUp)
+	if err != nil || !roundUp || !d.dateOnly() {
+		return t, err
+	}
+	return t.AddDate(0, 0, 1).Add(-time.Millisecond), nil
+}
+
+// dateOnly 是否为只有日期的 ISO-8601，如：2024-01-15
+func (d Date) dateOnly() bool {
+	return len(d) == len("2006-01-02") && !strings.HasPrefix(string(d), "now")
+}
+
+// IsDate 是否为合法的日期字面量，需要能被 datetime.ParseDateMath 解析，如：2024-13-45 不合法
+func IsDate(val string) bool {
+	for _, reg := range []*regexp.Regexp{REG_DATE, REG_DATE_MATH} {
+		if reg.FindString(val) == val {
+			_, err := datetime.ParseDateMath(val, time.Now(), false)
+			return err == nil
+		}
+	}
+	return false
+}
+
+type dateEncoder struct{}
+
+func (encoder dateEncoder) Encode(val any) string {
+	return string(val.(Date))
+}
+func (encoder dateEncoder) Decode(val string) any {
+	return Date(val)
+}
+
+type arrayEncoder struct{}
+
+func (encoder arrayEncoder) Encode(val any) string {
+	arr, _ := val.([]any)
+	items := make([]string, 0, len(arr))
+	for _, item := range arr {
+		switch v := item.(type) {
+		case float64:
+			items = append(items, NumberEncoder.Encode(v))
+		case bool:
+			items = append(items, BoolEncoder.Encode(v))
+		case string:
+			items = append(items, StringEncoder.Encode(v))
+		case Date:
+			items = append(items, DateEncoder.Encode(v))
+		}
+	}
+	return fmt.Sprintf("[%s]", strings.Join(items, ", "))
+}
+func (encoder arrayEncoder) Decode(val string) any {
+	return val
+}
+
 var (
 	EmptyEncoder  emptyEncoder
 	StringEncoder stringEncoder
 	NumberEncoder numberEncoder
+	DateEncoder   dateEncoder
 	BoolEncoder   boolEncoder
+	ArrayEncoder  arrayEncoder
+	NullEncoder   nullEncoder
+	FieldEncoder  fieldEncoder
+	FuncEncoder   funcEncoder
 )
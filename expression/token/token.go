@@ -4,16 +4,24 @@ import (
 	"regexp"
 )
 
-// token 的正则表达式
+// token 的正则表达式，名为 next 的分组只用于判断后面的字符，不属于 token
 var (
 	REG_LBT       = regexp.MustCompile(`^([(\[{])`)
 	REG_RBT       = regexp.MustCompile(`^([)\]}])`)
 	REG_OPERATOR  = regexp.MustCompile(`^(&&|\|\|)`)
-	REG_CONDITION = regexp.MustCompile(`^(?:(==|!=|>=|<=|>|<|containsBit|unContainsBit|contains|unContains|startsWith|unStartsWith|endsWith|unEndsWith|reg|notIn|in|isNot|is)\s*)`)
+	REG_NOT       = regexp.MustCompile(`^(!|not(?P<next>[\s(!]))`)
+	REG_CONDITION = regexp.MustCompile(`^(?:(==|!=|>=|<=|>|<|(?:containsBit|unContainsBit|contains|unContains|startsWith|unStartsWith|endsWith|unEndsWith|iequals|icontains|istartsWith|iendsWith|like|notLike|reg|notInCidr|inCidr|notIn|in|isNot|is|between)\b)\s*)`)
+	REG_UNARY     = regexp.MustCompile(`^(?:(exists|notExists)\b\s*)`)
+	REG_FUNC      = regexp.MustCompile(`^([a-zA-Z_]\w*\s*\()`)
 	REG_IDENT     = regexp.MustCompile(`^([a-zA-Z_][\w\\.\-]*)`)
+	REG_FIELD     = regexp.MustCompile(`^(\$[a-zA-Z_][\w\\.\-]*)`)
 	REG_NUM       = regexp.MustCompile(`^((?:\+|-)?\d+(?:\.\d+)?)`)
+	REG_DATE      = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}(?:T\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:Z|[+-]\d{2}:\d{2})?)?)`)
+	REG_DATE_MATH = regexp.MustCompile(`^(now(?:[+-]\d+[yMwdhHms]|/[yMwdhHms])*)\b`)
 	REG_BOOL      = regexp.MustCompile(`^(true|false)`)
-	REG_STRING    = regexp.MustCompile(`^(?:'(.*?)'|"(.*?)")`)
+	REG_NULL      = regexp.MustCompile(`^(null)\b`)
+	REG_COMMA     = regexp.MustCompile(`^(,)`)
+	REG_STRING    = regexp.MustCompile(`^(?s:'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"|` + "`[^`]*`" + `)`)
 	REG_DELIM     = regexp.MustCompile(`^(\s*)`)
 	REG_ILLEGAL   = regexp.MustCompile(`^(.+)`)
 )
@@ -28,13 +36,24 @@ const (
 	LBT       Token = "lbt"       // 左括号
 	RBT       Token = "rbt"       // 有括号
 	OPERATOR  Token = "operator"  // 逻辑关系
+	NOT       Token = "not"       // 取反
 	CONDITION Token = "condition" // 条件
+	UNARY     Token = "unary"     // 一元条件，没有值
 	IDENT     Token = "ident"     // 变量
+	FUNC      Token = "func"      // 函数调用，name(args...)，由 LexParse 把 name( 和参数合并生成
+	FIELD     Token = "field"     // 字段引用，$field，作为值与左侧字段比较
 	NUM       Token = "num"       // 数字
+	DATE      Token = "date"      // 日期，ISO-8601 或者相对日期 now-15m、now/d
 	BOOL      Token = "bool"      // bool
+	NULL      Token = "null"      // null
 	STRING    Token = "string"    // 字符串
+	ARRAY     Token = "array"     // 数组，由 LexParse 把值位置上的 [ ... ] 合并生成
+	QUANT     Token = "quant"     // 数组元素条件，any(path, expr)、all(path, expr)，由 LexParse 把 any( 、all( 和参数合并生成
+	COMMA     Token = "comma"     // 逗号
 	DELIM     Token = "delim"     // 空字符
 	ILLEGAL   Token = "illegal"   // 无法解析
+
+	FUNC_VALUE Token = "func_value" // 状态：函数调用作为值
 )
 
 // Parser token 解析器
@@ -48,30 +67,59 @@ type Parser struct {
 	Encodable
 }
 
+// Find 匹配 expr 开头的 token，正则中名为 next 的分组只用于判断后面的字符，不属于 token
+func (p Parser) Find(expr string) string {
+	loc := p.Reg.FindStringSubmatchIndex(expr)
+	if loc == nil {
+		return ""
+	}
+	if i := p.Reg.SubexpIndex("next"); i > 0 && loc[2*i] >= 0 {
+		return expr[:loc[2*i]]
+	}
+	return expr[:loc[1]]
+}
+
 // token 解析器
 var (
 	lbtParser       = Parser{Reg: REG_LBT, Token: LBT, Encodable: EmptyEncoder}
 	rbtParser       = Parser{Reg: REG_RBT, Token: RBT, Encodable: EmptyEncoder}
 	operatorParser  = Parser{Reg: REG_OPERATOR, Token: OPERATOR, Encodable: EmptyEncoder}
+	notParser       = Parser{Reg: REG_NOT, Token: NOT, Encodable: EmptyEncoder}
 	conditionParser = Parser{Reg: REG_CONDITION, Token: CONDITION, Encodable: EmptyEncoder}
+	unaryParser     = Parser{Reg: REG_UNARY, Token: UNARY, Encodable: EmptyEncoder}
+	funcParser      = Parser{Reg: REG_FUNC, Token: FUNC, Encodable: FuncEncoder}
 	identParser     = Parser{Reg: REG_IDENT, Token: IDENT, Encodable: EmptyEncoder}
+	fieldParser     = Parser{Reg: REG_FIELD, Token: FIELD, Encodable: FieldEncoder}
 	numParser       = Parser{Reg: REG_NUM, Token: NUM, Encodable: NumberEncoder}
+	dateParser      = Parser{Reg: REG_DATE, Token: DATE, Encodable: DateEncoder}
+	dateMathParser  = Parser{Reg: REG_DATE_MATH, Token: DATE, Encodable: DateEncoder}
 	boolParser      = Parser{Reg: REG_BOOL, Token: BOOL, Encodable: BoolEncoder}
+	nullParser      = Parser{Reg: REG_NULL, Token: NULL, Encodable: NullEncoder}
 	stringParser    = Parser{Reg: REG_STRING, Token: STRING, Encodable: StringEncoder}
+	arrayParser     = Parser{Reg: nil, Token: ARRAY, Encodable: ArrayEncoder}
+	commaParser     = Parser{Reg: REG_COMMA, Token: COMMA, Encodable: EmptyEncoder}
 	DelimParser     = Parser{Reg: REG_DELIM, Token: DELIM, Encodable: EmptyEncoder}
 	illegalParser   = Parser{Reg: REG_ILLEGAL, Token: ILLEGAL, Encodable: EmptyEncoder}
 )
 
 // ParserPriority token 解析器优先级
 var ParserPriority = []Parser{
+	unaryParser,
 	conditionParser,
 	operatorParser,
+	notParser,
+	dateParser,
 	numParser,
 	boolParser,
+	nullParser,
 	stringParser,
+	fieldParser,
+	funcParser,
+	dateMathParser,
 	identParser,
 	lbtParser,
 	rbtParser,
+	commaParser,
 	illegalParser,
 }
 
@@ -80,25 +128,84 @@ var ParserMap = map[Token]Parser{
 	LBT:       lbtParser,
 	RBT:       rbtParser,
 	OPERATOR:  operatorParser,
+	NOT:       notParser,
 	CONDITION: conditionParser,
+	UNARY:     unaryParser,
 	IDENT:     identParser,
+	FUNC:      funcParser,
+	FIELD:     fieldParser,
 	NUM:       numParser,
+	DATE:      dateParser,
 	BOOL:      boolParser,
+	NULL:      nullParser,
 	STRING:    stringParser,
+	ARRAY:     arrayParser,
+	COMMA:     commaParser,
 	DELIM:     DelimParser,
 	ILLEGAL:   illegalParser,
 }
 
+// OperatorPrecedence 逻辑关系优先级，数值越大优先级越高
+var OperatorPrecedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+}
+
 // StateMatrix 状态转移矩阵
 var StateMatrix = map[Token][]Token{
-	LITERAL_BEGIN: {LBT, IDENT},
-	LBT:           {LBT, IDENT},
+	LITERAL_BEGIN: {LBT, IDENT, FUNC, QUANT, NOT},
+	LBT:           {LBT, IDENT, FUNC, QUANT, NOT},
 	RBT:           {OPERATOR, RBT, LITERAL_END},
-	OPERATOR:      {LBT, IDENT},
-	CONDITION:     {NUM, BOOL, STRING},
-	IDENT:         {CONDITION},
+	OPERATOR:      {LBT, IDENT, FUNC, QUANT, NOT},
+	NOT:           {LBT, IDENT, FUNC, QUANT, NOT},
+	CONDITION:     {NUM, BOOL, STRING, DATE, ARRAY, NULL, FIELD, FUNC},
+	UNARY:         {OPERATOR, RBT, LITERAL_END},
+	IDENT:         {CONDITION, UNARY},
+	FUNC:          {CONDITION, UNARY},
+	FUNC_VALUE:    {OPERATOR, RBT, LITERAL_END},
 	NUM:           {OPERATOR, RBT, LITERAL_END},
+	DATE:          {OPERATOR, RBT, LITERAL_END},
 	BOOL:          {OPERATOR, RBT, LITERAL_END},
+	NULL:          {OPERATOR, RBT, LITERAL_END},
+	FIELD:         {OPERATOR, RBT, LITERAL_END},
 	STRING:        {OPERATOR, RBT, LITERAL_END},
+	ARRAY:         {OPERATOR, RBT, LITERAL_END},
+	QUANT:         {OPERATOR, RBT, LITERAL_END},
 	ILLEGAL:       {LITERAL_END},
 }
+
+// IsUnary 是否为一元条件，一元条件没有值，语法树中值为 NULL
+func IsUnary(condition string) bool {
+	return condition == "exists" || condition == "notExists"
+}
+
+// IsQuant 是否为数组元素条件，any：任意一个元素满足，all：所有元素都满足
+func IsQuant(name string) bool {
+	return name == "any" || name == "all"
+}
+
+// ArrayStateMatrix 数组内部的状态转移矩阵
+//
+//	LBT 为数组开始 '['，RBT 为数组结束 ']'
+var ArrayStateMatrix = map[Token][]Token{
+	LBT:    {NUM, BOOL, STRING, DATE, RBT},
+	COMMA:  {NUM, BOOL, STRING, DATE},
+	NUM:    {COMMA, RBT},
+	DATE:   {COMMA, RBT},
+	BOOL:   {COMMA, RBT},
+	STRING: {COMMA, RBT},
+}
+
+// CallStateMatrix 函数调用参数的状态转移矩阵
+//
+//	LBT 为参数开始 'name('，RBT 为参数结束 ')'
+var CallStateMatrix = map[Token][]Token{
+	LBT:    {IDENT, NUM, BOOL, STRING, NULL, FUNC, RBT},
+	COMMA:  {IDENT, NUM, BOOL, STRING, NULL, FUNC},
+	IDENT:  {COMMA, RBT},
+	NUM:    {COMMA, RBT},
+	BOOL:   {COMMA, RBT},
+	STRING: {COMMA, RBT},
+	NULL:   {COMMA, RBT},
+	FUNC:   {COMMA, RBT},
+}
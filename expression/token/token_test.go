@@ -2,6 +2,7 @@ package token
 
 import (
 	"testing"
+	"time"
 )
 
 func TestToken(t *testing.T) {
@@ -35,6 +36,36 @@ func TestToken(t *testing.T) {
 			TokenParser: operatorParser,
 			Result:      "||",
 		},
+		{
+			Token:       "!",
+			TokenParser: notParser,
+			Result:      "!",
+		},
+		{
+			Token:       "not (",
+			TokenParser: notParser,
+			Result:      "not",
+		},
+		{
+			Token:       "nothing",
+			TokenParser: notParser,
+			Result:      "",
+		},
+		{
+			Token:       "not!x",
+			TokenParser: notParser,
+			Result:      "not",
+		},
+		{
+			Token:       "not.x == 1",
+			TokenParser: notParser,
+			Result:      "",
+		},
+		{
+			Token:       "not-found == 1",
+			TokenParser: notParser,
+			Result:      "",
+		},
 		{
 			Token:       "==",
 			TokenParser: conditionParser,
@@ -65,6 +96,56 @@ func TestToken(t *testing.T) {
 			TokenParser: stringParser,
 			Result:      "hello world",
 		},
+		{
+			Token:       `'it\'s "a\\b"\n\u4e2d\ud83d\ude00' && a`,
+			TokenParser: stringParser,
+			Result:      "it's \"a\\b\"\n中😀",
+		},
+		{
+			Token:       `"^web-\d+\.\"x\""`,
+			TokenParser: stringParser,
+			Result:      `^web-\d+\."x"`,
+		},
+		{
+			Token:       "`^web-\\d+$` || b",
+			TokenParser: stringParser,
+			Result:      `^web-\d+$`,
+		},
+		{
+			Token:       `'abc\'`,
+			TokenParser: stringParser,
+			Result:      "",
+		},
+		{
+			Token:       "$host.ip",
+			TokenParser: fieldParser,
+			Result:      "host.ip",
+		},
+		{
+			Token:       "lower (user)",
+			TokenParser: funcParser,
+			Result:      "lower",
+		},
+		{
+			Token:       "null",
+			TokenParser: nullParser,
+			Result:      nil,
+		},
+		{
+			Token:       "notExists",
+			TokenParser: unaryParser,
+			Result:      "notExists",
+		},
+		{
+			Token:       "existsFlag",
+			TokenParser: unaryParser,
+			Result:      "",
+		},
+		{
+			Token:       ", 1",
+			TokenParser: commaParser,
+			Result:      ",",
+		},
 		{
 			Token:       "asdfc",
 			TokenParser: stringParser,
@@ -73,10 +154,74 @@ func TestToken(t *testing.T) {
 	}
 
 	for _, testCase := range testCases {
-		result := testCase.TokenParser.Reg.FindString(testCase.Token)
+		result := testCase.TokenParser.Find(testCase.Token)
 		resultAny := testCase.TokenParser.Decode(result)
 		if resultAny != testCase.Result {
 			t.Fatalf("testCase %s need (%v) but got (%v)", testCase.TokenParser.Token, testCase.Result, resultAny)
 		}
 	}
 }
+
+func FuzzStringEncoder(f *testing.F) {
+	for _, seed := range []string{"", "a", "it's", `say "hi"`, `'"`, `^web-\d+`, "a`b\\c", "line\nbreak\t\x00", "\xff\xfe", "中文😀"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, val string) {
+		encoded := StringEncoder.Encode(val)
+		if result := stringParser.Reg.FindString(encoded + " && a"); result != encoded {
+			t.Fatalf("string %q encoded %s but matched %s", val, encoded, result)
+		}
+		if decoded := StringEncoder.Decode(encoded); decoded != val {
+			t.Fatalf("string %q encoded %s but decoded %q", val, encoded, decoded)
+		}
+	})
+}
+
+// TestStringDecodeMigration 固定旧写法的字符串在支持转义后的解码结果，见 CHANGELOG.md
+func TestStringDecodeMigration(t *testing.T) {
+	testCases := []struct {
+		Literal string
+		Result  string
+	}{
+		// 旧版本不处理转义，原样保留
+		{Literal: `'\\d'`, Result: `\d`},
+		{Literal: `'C:\new'`, Result: "C:\new"},
+		{Literal: `'C:\temp'`, Result: "C:\temp"},
+		{Literal: `'a\'b'`, Result: `a'b`},
+		{Literal: `'\u0041'`, Result: "A"},
+		// 未知的转义保留 \
+		{Literal: `'^web-\d+$'`, Result: `^web-\d+$`},
+		// 原始字符串与旧版本一致
+		{Literal: "`C:\\new`", Result: `C:\new`},
+		{Literal: "`^\\\\d$`", Result: `^\\d$`},
+	}
+	for _, testCase := range testCases {
+		result := stringParser.Reg.FindString(testCase.Literal)
+		if result != testCase.Literal {
+			t.Fatalf("testCase %s matched %s", testCase.Literal, result)
+		}
+		if decoded := StringEncoder.Decode(result); decoded != testCase.Result {
+			t.Fatalf("testCase %s need %q but got %q", testCase.Literal, testCase.Result, decoded)
+		}
+	}
+}
+
+func TestDateTime(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.Local)
+	testCases := []struct {
+		Date    Date
+		RoundUp bool
+		Result  time.Time
+	}{
+		{Date: "2024-01-15", Result: time.Date(2024, 1, 15, 0, 0, 0, 0, time.Local)},
+		{Date: "2024-01-15", RoundUp: true, Result: time.Date(2024, 1, 15, 23, 59, 59, 999e6, time.Local)},
+		{Date: "2024-01-15T08:00", RoundUp: true, Result: time.Date(2024, 1, 15, 8, 0, 0, 0, time.Local)},
+		{Date: "now-100d/d", RoundUp: true, Result: time.Date(2023, 10, 7, 23, 59, 59, 999e6, time.Local)},
+	}
+	for _, testCase := range testCases {
+		result, err := testCase.Date.Time(now, testCase.RoundUp)
+		if err != nil || !result.Equal(testCase.Result) {
+			t.Fatalf("testCase %s need %s but got %s, %v", testCase.Date, testCase.Result, result, err)
+		}
+	}
+}
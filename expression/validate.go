@@ -0,0 +1,252 @@
+package expression
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jummyliu/pkg/expression/token"
+	"github.com/jummyliu/pkg/utils"
+)
+
+// Schema 字段定义，用于静态校验表达式
+type Schema struct {
+	// Fields 字段定义，key 为表达式中的字段名
+	// 	为空则不检查字段
+	Fields map[string]*FieldSchema
+
+	// Targets 目标执行器支持的条件，key 为执行器名称
+	// 	如：{"mysql_expr": expression.NewSupport(mysql_expr.DefaultFnMap)}
+	Targets map[string]Support
+}
+
+// FieldSchema 字段定义
+type FieldSchema struct {
+	Type token.Token // 字段类型：NUM | BOOL | STRING | DATE，为空则不检查类型
+	Ops  []string    // 允许的条件，为空则不限制
+}
+
+// Support 执行器支持的条件 op => 值的类型
+type Support map[string]map[token.Token]bool
+
+// NewSupport 从执行器的 FnMap 生成支持的条件
+func NewSupport[T any](fnMap map[string]map[token.Token]T) Support {
+	support := Support{}
+	for op, fns := range fnMap {
+		support[op] = map[token.Token]bool{}
+		for t := range fns {
+			support[op][t] = true
+		}
+	}
+	return support
+}
+
+// ValidateError 校验错误
+type ValidateError struct {
+	Msg    string   // 错误信息
+	Node   *AstNode // 出错的条件
+	Target string   // 不支持该条件的执行器，为空表示与执行器无关
+}
+
+func (e *ValidateError) Error() string {
+	var b strings.Builder
+	if e.Node != nil {
+		b.WriteString(Format(e.Node))
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Msg)
+	if len(e.Target) != 0 {
+		b.WriteString(fmt.Sprintf(" (%s)", e.Target))
+	}
+	return b.String()
+}
+
+// Validate 根据字段定义校验语法树，返回所有错误
+//
+//	未知字段、字段不允许的条件、值与字段类型不匹配、目标执行器不支持的条件
+func Validate(ast *AstNode, schema *Schema) (errs []*ValidateError) {
+	if err := CheckAst(ast); err != nil {
+		return []*ValidateError{{Msg: err.Error()}}
+	}
+	if schema == nil {
+		return nil
+	}
+	v := &validator{schema: schema}
+	v.walk(ast)
+	return v.errs
+}
+
+// validator 校验器
+type validator struct {
+	schema *Schema
+	errs   []*ValidateError
+	path   string // 数组元素条件中，字段名的前缀，如：processes.
+}
+
+func (v *validator) fail(node *AstNode, target string, format string, args ...any) {
+	v.errs = append(v.errs, &ValidateError{
+		Msg:    fmt.Sprintf(format, args...),
+		Node:   node,
+		Target: target,
+	})
+}
+
+// walk 遍历语法树
+func (v *validator) walk(ast *AstNode) {
+	switch ast.Type {
+	case token.OPERATOR:
+		v.walk(ast.Left)
+		v.walk(ast.Right)
+	case token.NOT:
+		v.walk(ast.Left)
+	case token.CONDITION:
+		v.term(ast)
+	case token.QUANT:
+		// 子表达式中的字段相对于数组元素，使用 path.key 查找字段定义
+		path := v.path
+		v.path = path + ast.Left.Value.(string) + "."
+		v.walk(ast.Right)
+		v.path = path
+	}
+}
+
+// field 查找字段定义，字段不存在时返回 nil
+func (v *validator) field(term *AstNode, key string) (field *FieldSchema, ok bool) {
+	if v.schema.Fields == nil {
+		return nil, true
+	}
+	key = v.path + key
+	field, ok = v.schema.Fields[key]
+	if !ok {
+		v.fail(term, "", "unknown field: %s", key)
+	}
+	return field, ok
+}
+
+// args 检查函数调用参数中的字段
+func (v *validator) args(term *AstNode, call *AstNode) {
+	for _, arg := range call.Args {
+		switch arg.Type {
+		case token.IDENT:
+			v.field(term, arg.Value.(string))
+		case token.FUNC:
+			v.args(term, arg)
+		}
+	}
+}
+
+// term 校验单个条件
+func (v *validator) term(term *AstNode) {
+	op := term.Value.(string)
+	var field *FieldSchema
+	switch term.Left.Type {
+	case token.IDENT:
+		var ok bool
+		if field, ok = v.field(term, term.Left.Value.(string)); !ok {
+			return
+		}
+	case token.FUNC:
+		// 函数调用的返回值类型未知，只检查参数
+		v.args(term, term.Left)
+	}
+	if term.Right.Type == token.FUNC {
+		v.args(term, term.Right)
+	}
+	if op == "between" {
+		if arr, ok := term.Right.Value.([]any); term.Right.Type != token.ARRAY || !ok || len(arr) != 2 {
+			v.fail(term, "", "between need an array of 2 items")
+		}
+	}
+	if field != nil {
+		if len(field.Ops) != 0 && utils.FindIndex(field.Ops, op) == -1 {
+			v.fail(term, "", "operator %s is not allowed on %s", op, term.Left.Value)
+		}
+		v.typ(term, field)
+	}
+	// 与执行器使用相同的 FnMap[op][token] 查找
+	targets := make([]string, 0, len(v.schema.Targets))
+	for name := range v.schema.Targets {
+		targets = append(targets, name)
+	}
+	sort.Strings(targets)
+	for _, name := range targets {
+		if !v.schema.Targets[name][op][term.Right.Type] {
+			v.fail(term, name, "unsupported condition: %s %s", op, term.Right.Type)
+		}
+	}
+}
+
+// typ 检查值与字段类型是否匹配
+func (v *validator) typ(term *AstNode, field *FieldSchema) {
+	if len(field.Type) == 0 {
+		return
+	}
+	op := term.Value.(string)
+	switch term.Right.Type {
+	case token.NULL, token.FUNC:
+		return
+	case token.FIELD:
+		key := term.Right.Value.(string)
+		ref, ok := v.field(term, key)
+		if ok && ref != nil && len(ref.Type) != 0 && ref.Type != field.Type {
+			v.fail(term, "", "cannot compare %s field with %s field %s", field.Type, ref.Type, key)
+		}
+		return
+	case token.ARRAY:
+		for _, item := range term.Right.Value.([]any) {
+			if t := literalType(item); !typeMatch(field.Type, t) {
+				v.fail(term, "", "cannot compare %s field with %s item %v", field.Type, t, item)
+				return
+			}
+		}
+		return
+	case token.STRING:
+		// in、notIn 使用逗号分隔的字符串
+		if (op == "in" || op == "notIn") && field.Type != token.STRING {
+			for _, item := range strings.Split(term.Right.Value.(string), ",") {
+				if !isLiteralOf(item, field.Type) {
+					v.fail(term, "", "cannot compare %s field with item %q", field.Type, item)
+					return
+				}
+			}
+			return
+		}
+	}
+	if !typeMatch(field.Type, term.Right.Type) {
+		v.fail(term, "", "cannot compare %s field with %s", field.Type, term.Right.Type)
+	}
+}
+
+// typeMatch 值的类型是否与字段类型匹配，DATE 字段可以与字符串比较
+func typeMatch(fieldType token.Token, t token.Token) bool {
+	return t == fieldType || fieldType == token.DATE && t == token.STRING
+}
+
+// literalType 字面量的类型
+func literalType(val any) token.Token {
+	switch val.(type) {
+	case float64:
+		return token.NUM
+	case bool:
+		return token.BOOL
+	case string:
+		return token.STRING
+	case token.Date:
+		return token.DATE
+	}
+	return token.ILLEGAL
+}
+
+// isLiteralOf 判断字符串是否可以转换为指定类型
+func isLiteralOf(val string, t token.Token) bool {
+	val = strings.TrimSpace(val)
+	switch t {
+	case token.NUM:
+		_, err := strconv.ParseFloat(val, 64)
+		return err == nil
+	case token.BOOL:
+		return val == "true" || val == "false"
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package expression
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 通配符，用于 like、notLike 条件，匹配整个字符串
+//
+//	* 匹配任意个字符，? 匹配一个字符，\ 转义下一个字符，如：'web-*'、'a\*b'
+//	末尾单独的 \ 作为普通字符
+
+// ScanWildcard 依次处理通配符中的字面值和通配符
+//
+//	literal 处理连续的字面值（已去掉转义），wildcard 处理 '*' 或 '?'
+func ScanWildcard(pattern string, literal func(s string), wildcard func(c byte)) {
+	var b strings.Builder
+	flush := func() {
+		if b.Len() > 0 {
+			literal(b.String())
+			b.Reset()
+		}
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			b.WriteByte(pattern[i])
+		case '*', '?':
+			flush()
+			wildcard(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	flush()
+}
+
+// WildcardToRegexp 把通配符转换为正则表达式，匹配整个字符串，? 匹配一个 unicode 字符
+func WildcardToRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString(`(?s)^`)
+	ScanWildcard(pattern, func(s string) {
+		b.WriteString(regexp.QuoteMeta(s))
+	}, func(c byte) {
+		if c == '*' {
+			b.WriteString(".*")
+		} else {
+			b.WriteByte('.')
+		}
+	})
+	b.WriteByte('$')
+	return b.String()
+}
+
+// likeEscaper 转义 LIKE 中的特殊字符，转义字符为 \
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike 转义 LIKE 中的特殊字符，用于把字面值拼接到 LIKE 的匹配模式中
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// WildcardToLike 把通配符转换为 LIKE 的匹配模式，* => %，? => _，转义字符为 \
+func WildcardToLike(pattern string) string {
+	var b strings.Builder
+	ScanWildcard(pattern, func(s string) {
+		b.WriteString(EscapeLike(s))
+	}, func(c byte) {
+		if c == '*' {
+			b.WriteByte('%')
+		} else {
+			b.WriteByte('_')
+		}
+	})
+	return b.String()
+}
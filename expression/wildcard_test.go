@@ -0,0 +1,31 @@
+package expression
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestWildcard(t *testing.T) {
+	testCases := []struct {
+		Pattern string
+		Regexp  string
+		Like    string
+	}{
+		{Pattern: "web-*", Regexp: `(?s)^web-.*$`, Like: `web-%`},
+		{Pattern: "a?c.d", Regexp: `(?s)^a.c\.d$`, Like: `a_c.d`},
+		{Pattern: `50%_\*\?`, Regexp: `(?s)^50%_\*\?$`, Like: `50\%\_*?`},
+		{Pattern: `a\\b\`, Regexp: `(?s)^a\\b\\$`, Like: `a\\b\\`},
+		{Pattern: "", Regexp: `(?s)^$`, Like: ``},
+	}
+	for _, testCase := range testCases {
+		if result := WildcardToRegexp(testCase.Pattern); result != testCase.Regexp {
+			t.Fatalf("testCase %s need regexp %s but got %s", testCase.Pattern, testCase.Regexp, result)
+		}
+		if _, err := regexp.Compile(testCase.Regexp); err != nil {
+			t.Fatalf("testCase %s compile regexp failure: %s", testCase.Pattern, err)
+		}
+		if result := WildcardToLike(testCase.Pattern); result != testCase.Like {
+			t.Fatalf("testCase %s need like %s but got %s", testCase.Pattern, testCase.Like, result)
+		}
+	}
+}